@@ -27,7 +27,8 @@ const (
 	ClickhouseBackend = DBBackendName("Clickhouse")
 	// SQLite is the backend name of Sqlite3
 	SQLiteBackend = DBBackendName("SQLite")
-	// PostgreSQLBackend = DBBackendName("PostgreSQL")
+	// PostgreSQL is the backend name of PostgreSQL
+	PostgreSQLBackend = DBBackendName("PostgreSQL")
 
 	// DAMENG is the backend name of DAMENG
 	DamengBackend = DBBackendName("DAMENG")
@@ -46,6 +47,15 @@ type IBackend interface {
 	//     Sqlite: true
 	//     Clickhouse: false
 	IsSupportIndexAndContraints() bool
+	// IsSupportIndexFeature returns whether the backend supports an optional feature of index definitions,
+	// indexes are created without the unsupported prefixes, orders and types, or skipped for
+	// unsupported expressions and predicates
+	// ts is the table to be indexed, whose database tells the version of the server
+	//     MySQL: prefix, fulltext, spatial, desc requiring 8.0+ and expression requiring 8.0.13+
+	//     Sqlite: desc, expression, partial
+	//     PostgreSQL: desc, expression, partial
+	//     Clickhouse: none
+	IsSupportIndexFeature(ts ITableSpec, feature TIndexFeature) bool
 	// FetchTableColumnSpecs parse the table definition in database to extract columns' specification of a table
 	FetchTableColumnSpecs(ts ITableSpec) ([]IColumnSpec, error)
 	// FetchIndexesAndConstraints parse the table defintion in database to extract index and constraints information of a table
@@ -66,6 +76,10 @@ type IBackend interface {
 	UnionDistinctString() string
 	// support mixed insert vars
 	SupportMixedInsertVariables() bool
+	// LiteralStyle returns the rules of quoting values as SQL literals
+	LiteralStyle() SLiteralStyle
+	// ClassifyError returns the Error constant, e.g. ErrDuplicateEntry, that a driver error falls into, nil if unknown
+	ClassifyError(err error) error
 	// Drop table
 	DropTableSQL(table string) string
 
@@ -82,6 +96,8 @@ type IBackend interface {
 	InsertSQLTemplate() string
 	// UpdateSQLTemplate returns the template of update SQL
 	UpdateSQLTemplate() string
+	// DeleteSQLTemplate returns the template of delete SQL
+	DeleteSQLTemplate() string
 	// InsertOrUpdateSQLTemplate returns the template of insert or update SQL
 	InsertOrUpdateSQLTemplate() string
 	// prepare insert or update sql
@@ -93,15 +109,59 @@ type IBackend interface {
 	// values: insert values
 	// updateupdateValues: update values
 	PrepareInsertOrUpdateSQL(ts ITableSpec, insertColNames []string, insertFields []string, onPrimaryCols []string, updateSetCols []string, insertValues []interface{}, updateValues []interface{}) (string, []interface{})
+	// InsertOrUpdateBatchSQLTemplate returns the template of multi-row insert or update SQL,
+	// empty if the backend does not support it
+	// template variables:
+	//     Table: table name
+	//     Columns: quoted insert column names
+	//     Values: rows of insert values, e.g. (?, ?), (?, ?)
+	//     PrimaryKeys: quoted primary key column names
+	//     UpdateColumns: names of columns that are updated with the inserting values
+	//     VersionColumns: names of auto_version columns that are increased
+	InsertOrUpdateBatchSQLTemplate() string
+	// MaxPlaceholders returns the maximal number of placeholders in a statement
+	MaxPlaceholders() int
+	// CanSupportMultiRowInsert returns wether the backend accepts multiple rows in INSERT ... VALUES
+	//     MySQL: true
+	//     Sqlite: true
+	//     Clickhouse: false, the driver prepares INSERT in block mode and binds one row at a time
+	CanSupportMultiRowInsert() bool
 
 	// CanSupportRowAffected returns wether the backend support RowAffected method after update
 	//     MySQL: true
 	//     Sqlite: false
 	//     Clickhouse: false
 	CanSupportRowAffected() bool
+	// CanSupportLastInsertId returns wether the backend support LastInsertId method after insert
+	//     MySQL: true
+	//     PostgreSQL: false, use INSERT ... RETURNING instead
+	CanSupportLastInsertId() bool
+	// CanSupportSavepoint returns wether the backend supports SAVEPOINT for nested transactions
+	//     MySQL: true
+	//     Sqlite: true
+	//     Clickhouse: false
+	CanSupportSavepoint() bool
+	// CanSupportCTE returns wether the backend supports common table expressions, e.g. WITH [RECURSIVE] ... AS (...)
+	//     MySQL: true, requires 8.0+
+	//     Sqlite: true
+	//     PostgreSQL: true
+	//     Clickhouse: false
+	CanSupportCTE() bool
+	// CanSupportTransactionalDDL returns wether DDL statements can be rollbacked in a transaction
+	//     MySQL: false, DDL commits implicitly
+	//     Sqlite: true
+	//     PostgreSQL: true
+	CanSupportTransactionalDDL() bool
+
+	// ReplacePlaceholders rewrites the ? placeholders in SQL into the native placeholders of the backend
+	//     PostgreSQL: $1, $2, ...
+	ReplacePlaceholders(sqlstr string) string
 
 	// CommitTableChangeSQL outputs the SQLs to alter a table
 	CommitTableChangeSQL(ts ITableSpec, changes STableChanges) []string
+	// CommitTableChanges applies the changes to a table with options, by executing the SQLs of CommitTableChangeSQL by default
+	//     MySQL: alters with ALGORITHM and LOCK hints or copies to a shadow table with SYNC_STRATEGY_ONLINE
+	CommitTableChanges(ts ITableSpec, changes STableChanges, opts SSyncOptions) error
 
 	QuoteChar() string
 
@@ -158,6 +218,23 @@ type IBackend interface {
 	// DATEDIFF
 	DATEDIFF(unit string, field1, field2 IQueryField) IQueryField
 
+	// ROW_NUMBER
+	ROW_NUMBER(name string, window *SWindow) IQueryField
+	// RANK
+	RANK(name string, window *SWindow) IQueryField
+	// DENSE_RANK
+	DENSE_RANK(name string, window *SWindow) IQueryField
+	// LAG
+	LAG(name string, field IQueryField, offset int, window *SWindow) IQueryField
+	// LEAD
+	LEAD(name string, field IQueryField, offset int, window *SWindow) IQueryField
+	// FIRST_VALUE
+	FIRST_VALUE(name string, field IQueryField, window *SWindow) IQueryField
+	// SUM_OVER
+	SUM_OVER(name string, field IQueryField, window *SWindow) IQueryField
+	// AVG_OVER
+	AVG_OVER(name string, field IQueryField, window *SWindow) IQueryField
+
 	/////////////////////////////////////////////////////////////////////////////
 	///////////////////// Filters ///////////////////////////////////////////////
 	/////////////////////////////////////////////////////////////////////////////
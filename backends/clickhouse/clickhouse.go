@@ -72,6 +72,11 @@ func (click *SClickhouseBackend) CanInsertOrUpdate() bool {
 	return false
 }
 
+// CanSupportMultiRowInsert returns false, clickhouse-go binds the rows of a block one by one
+func (click *SClickhouseBackend) CanSupportMultiRowInsert() bool {
+	return false
+}
+
 func (click *SClickhouseBackend) IsSupportIndexAndContraints() bool {
 	return false
 }
@@ -100,10 +105,18 @@ func (click *SClickhouseBackend) SupportMixedInsertVariables() bool {
 	return false
 }
 
+func (click *SClickhouseBackend) LiteralStyle() sqlchemy.SLiteralStyle {
+	return sqlchemy.SLiteralStyle{BackslashEscape: true}
+}
+
 func (click *SClickhouseBackend) UpdateSQLTemplate() string {
 	return "ALTER TABLE `{{ .Table }}` UPDATE {{ .Columns }} WHERE {{ .Conditions }}"
 }
 
+func (click *SClickhouseBackend) DeleteSQLTemplate() string {
+	return "ALTER TABLE `{{ .Table }}` DELETE WHERE {{ .Conditions }}"
+}
+
 func MySQLExtraOptions(hostport, database, table, user, passwd string) sqlchemy.TableExtraOptions {
 	return sqlchemy.TableExtraOptions{
 		EXTRA_OPTION_ENGINE_KEY:                    EXTRA_OPTION_ENGINE_VALUE_MYSQL,
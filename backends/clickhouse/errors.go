@@ -0,0 +1,40 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"github.com/ClickHouse/clickhouse-go"
+
+	"yunion.io/x/sqlchemy"
+)
+
+var clickhouseErrorClasses = map[int32]error{
+	60:  sqlchemy.ErrTableNotExists, // UNKNOWN_TABLE
+	131: sqlchemy.ErrDataTooLong,    // TOO_LARGE_STRING_SIZE
+	159: sqlchemy.ErrLockTimeout,    // TIMEOUT_EXCEEDED
+	209: sqlchemy.ErrConnectionLost, // SOCKET_TIMEOUT
+	210: sqlchemy.ErrConnectionLost, // NETWORK_ERROR
+	473: sqlchemy.ErrDeadlock,       // DEADLOCK_AVOIDED
+}
+
+// ClassifyError translates a Clickhouse exception into an Error constant of sqlchemy,
+// Clickhouse enforces neither unique keys nor foreign keys
+func (click *SClickhouseBackend) ClassifyError(err error) error {
+	chErr, ok := err.(*clickhouse.Exception)
+	if !ok {
+		return click.SBaseBackend.ClassifyError(err)
+	}
+	return clickhouseErrorClasses[chErr.Code]
+}
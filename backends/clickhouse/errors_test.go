@@ -0,0 +1,41 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestClassifyError(t *testing.T) {
+	backend := &SClickhouseBackend{}
+	cases := []struct {
+		err  error
+		want error
+	}{
+		{&clickhouse.Exception{Code: 60, Message: "Table default.t1 doesn't exist"}, sqlchemy.ErrTableNotExists},
+		{&clickhouse.Exception{Code: 473, Message: "Deadlock avoided"}, sqlchemy.ErrDeadlock},
+		{&clickhouse.Exception{Code: 62, Message: "Syntax error"}, nil},
+	}
+	for _, c := range cases {
+		got := backend.ClassifyError(c.err)
+		if got != c.want {
+			t.Errorf("%s: want %v got %v", c.err, c.want, got)
+		}
+	}
+}
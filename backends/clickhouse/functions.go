@@ -16,6 +16,7 @@ package clickhouse
 
 import (
 	"fmt"
+	"strings"
 
 	"yunion.io/x/sqlchemy"
 )
@@ -39,3 +40,56 @@ func (click *SClickhouseBackend) CASTInt(field sqlchemy.IQueryField, fieldname s
 func (click *SClickhouseBackend) CASTFloat(field sqlchemy.IQueryField, fieldname string) sqlchemy.IQueryField {
 	return sqlchemy.NewFunctionField(fieldname, false, `CAST(%s, 'Float64')`, field)
 }
+
+// ROW_NUMBER represents the SQL window function row_number
+func (click *SClickhouseBackend) ROW_NUMBER(name string, window *sqlchemy.SWindow) sqlchemy.IQueryField {
+	return sqlchemy.NewWindowFunctionField(name, "row_number()", window)
+}
+
+// RANK represents the SQL window function rank
+func (click *SClickhouseBackend) RANK(name string, window *sqlchemy.SWindow) sqlchemy.IQueryField {
+	return sqlchemy.NewWindowFunctionField(name, "rank()", window)
+}
+
+// DENSE_RANK represents the SQL window function dense_rank
+func (click *SClickhouseBackend) DENSE_RANK(name string, window *sqlchemy.SWindow) sqlchemy.IQueryField {
+	return sqlchemy.NewWindowFunctionField(name, "dense_rank()", window)
+}
+
+// LAG represents the SQL window function LAG, which is lagInFrame over the whole partition in clickhouse
+func (click *SClickhouseBackend) LAG(name string, field sqlchemy.IQueryField, offset int, window *sqlchemy.SWindow) sqlchemy.IQueryField {
+	return sqlchemy.NewWindowFunctionField(name, fmt.Sprintf("lagInFrame(%%s, %d)", offset), wholePartitionWindow(window), field)
+}
+
+// LEAD represents the SQL window function LEAD, which is leadInFrame over the whole partition in clickhouse
+func (click *SClickhouseBackend) LEAD(name string, field sqlchemy.IQueryField, offset int, window *sqlchemy.SWindow) sqlchemy.IQueryField {
+	return sqlchemy.NewWindowFunctionField(name, fmt.Sprintf("leadInFrame(%%s, %d)", offset), wholePartitionWindow(window), field)
+}
+
+// FIRST_VALUE represents the SQL window function first_value
+func (click *SClickhouseBackend) FIRST_VALUE(name string, field sqlchemy.IQueryField, window *sqlchemy.SWindow) sqlchemy.IQueryField {
+	return sqlchemy.NewWindowFunctionField(name, "first_value(%s)", window, field)
+}
+
+// lagInFrame and leadInFrame respect the window frame, unlike LAG and LEAD in standard SQL,
+// so the frame defaults to the whole partition
+func wholePartitionWindow(window *sqlchemy.SWindow) *sqlchemy.SWindow {
+	if window == nil {
+		window = sqlchemy.NewWindow()
+	}
+	if window.HasFrame() {
+		return window
+	}
+	return window.Copy().Rows(sqlchemy.WINDOW_UNBOUNDED_PRECEDING, sqlchemy.WINDOW_UNBOUNDED_FOLLOWING)
+}
+
+// WINDOW_FUNNEL represents the clickhouse aggregate function windowFunnel, which searches for event chains
+// in a sliding time window of windowSeconds, e.g. windowFunnel(3600)(timestamp, cond1, cond2)
+func (click *SClickhouseBackend) WINDOW_FUNNEL(name string, windowSeconds int, timestamp sqlchemy.IQueryField, events ...sqlchemy.IQueryField) sqlchemy.IQueryField {
+	params := []string{"%s"}
+	for range events {
+		params = append(params, "%s")
+	}
+	fields := append([]sqlchemy.IQueryField{timestamp}, events...)
+	return sqlchemy.NewFunctionField(name, true, fmt.Sprintf("windowFunnel(%d)(%s)", windowSeconds, strings.Join(params, ", ")), fields...)
+}
@@ -15,6 +15,7 @@
 package clickhouse
 
 import (
+	"fmt"
 	"testing"
 
 	"yunion.io/x/pkg/errors"
@@ -91,3 +92,30 @@ func TestInsertWithPointerValue(t *testing.T) {
 	}
 	t.Logf("%s values: %v", sql, vals)
 }
+
+func TestInsertBatchSqlPrep(t *testing.T) {
+	type TableStruct struct {
+		RowId int    `primary:"true"`
+		Name  string `width:"24"`
+	}
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.ClickhouseBackend)
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "vv")
+	results, err := ts.InsertBatchSqlPrep([]interface{}{
+		&TableStruct{RowId: 1, Name: "a"},
+		&TableStruct{RowId: 2, Name: "b"},
+		&TableStruct{RowId: 3, Name: "c"},
+	}, false)
+	if err != nil {
+		t.Fatalf("InsertBatchSqlPrep fail: %s", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("want 1 statement got %d", len(results))
+	}
+	// the driver binds the rows of a block one by one, so the single-row statement is executed for each row
+	if want := "INSERT INTO `vv` (`row_id`, `name`) VALUES (?, ?)"; results[0].Sql != want {
+		t.Errorf("sql want %s got %s", want, results[0].Sql)
+	}
+	if got := fmt.Sprintf("%v", results[0].ValuesList); got != "[[1 a] [2 b] [3 c]]" {
+		t.Errorf("unexpected values %s", got)
+	}
+}
@@ -22,4 +22,25 @@ func TestQuery(t *testing.T) {
 		want := "SELECT `t1`.`col0` AS `col0` FROM `test` AS `t1` WHERE match(`t1`.`col1`,  ? )"
 		tests.AssertGotWant(t, q.String(), want)
 	})
+
+	t.Run("query window funcs", func(t *testing.T) {
+		tests.BackendTestReset(sqlchemy.ClickhouseBackend)
+		testTable := tests.GetTestTable()
+		w := sqlchemy.NewWindow().PartitionBy(testTable.Field("col0")).Asc(testTable.Field("col1"))
+		q := testTable.Query(sqlchemy.ROW_NUMBER("rn", w), sqlchemy.LEAD("next", testTable.Field("col1"), 1, w))
+		want := "SELECT row_number() OVER (PARTITION BY `t1`.`col0` ORDER BY `t1`.`col1` ASC) AS `rn`, leadInFrame(`t1`.`col1`, 1) OVER (PARTITION BY `t1`.`col0` ORDER BY `t1`.`col1` ASC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS `next` FROM `test` AS `t1`"
+		tests.AssertGotWant(t, q.String(), want)
+	})
+
+	t.Run("query windowFunnel func", func(t *testing.T) {
+		tests.BackendTestReset(sqlchemy.ClickhouseBackend)
+		testTable := tests.GetTestTable()
+		click := &SClickhouseBackend{}
+		q := testTable.Query(testTable.Field("col0"), click.WINDOW_FUNNEL("level", 3600, testTable.Field("col1"),
+			sqlchemy.NewFunctionField("", false, "%s = 'login'", testTable.Field("col2")),
+			sqlchemy.NewFunctionField("", false, "%s = 'buy'", testTable.Field("col2")),
+		)).GroupBy(testTable.Field("col0"))
+		want := "SELECT `t1`.`col0` AS `col0`, windowFunnel(3600)(`t1`.`col1`, `t1`.`col2` = 'login', `t1`.`col2` = 'buy') AS `level` FROM `test` AS `t1` GROUP BY `t1`.`col0`"
+		tests.AssertGotWant(t, q.String(), want)
+	})
 }
@@ -89,7 +89,7 @@ func (clickhouse *SClickhouseBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpe
 	// first check if primary key is modifed
 	changePrimary := false
 
-	for _, col := range changes.RemoveColumns {
+	for _, col := range append(changes.RemoveColumns, changes.DropColumns...) {
 		if col.IsPrimary() {
 			changePrimary = true
 		}
@@ -140,6 +140,11 @@ func (clickhouse *SClickhouseBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpe
 		}
 	}
 
+	for _, col := range changes.DropColumns {
+		sql := fmt.Sprintf("DROP COLUMN `%s`", col.Name())
+		alters = append(alters, sql)
+	}
+
 	oldPartitions := findPartitions(changes.OldColumns)
 	for _, cols := range changes.UpdatedColumns {
 		if cols.OldCol.Name() != cols.NewCol.Name() {
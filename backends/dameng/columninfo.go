@@ -88,6 +88,7 @@ func fetchTableColInfo(ts sqlchemy.ITableSpec) (map[string]*sSqlColumnInfo, erro
 
 type sDamengTableIndex struct {
 	isPrimary bool
+	isUnique  bool
 	indexName string
 	colnames  []string
 }
@@ -97,9 +98,10 @@ func fetchTableIndexes(ts sqlchemy.ITableSpec) (map[string]sDamengTableIndex, er
 		ColumnName     string `json:"COLUMN_NAME"`
 		IndexName      string `json:"INDEX_NAME"`
 		ConstraintType string `json:"CONSTRAINT_TYPE"`
+		Uniqueness     string `json:"UNIQUENESS"`
 	}
-	sqlStr := fmt.Sprintf("SELECT a.COLUMN_NAME, a.INDEX_NAME, b.CONSTRAINT_TYPE FROM USER_IND_COLUMNS a LEFT JOIN USER_CONSTRAINTS b ON a.INDEX_NAME=b.INDEX_NAME WHERE a.TABLE_NAME='%s'", ts.Name())
-	query := ts.Database().NewRawQuery(sqlStr, "column_name", "index_name", "constraint_type")
+	sqlStr := fmt.Sprintf("SELECT a.COLUMN_NAME, a.INDEX_NAME, b.CONSTRAINT_TYPE, c.UNIQUENESS FROM USER_IND_COLUMNS a LEFT JOIN USER_CONSTRAINTS b ON a.INDEX_NAME=b.INDEX_NAME LEFT JOIN USER_INDEXES c ON a.INDEX_NAME=c.INDEX_NAME WHERE a.TABLE_NAME='%s'", ts.Name())
+	query := ts.Database().NewRawQuery(sqlStr, "column_name", "index_name", "constraint_type", "uniqueness")
 	infos := make([]sIndexInfo, 0)
 	err := query.All(&infos)
 	if err != nil {
@@ -115,6 +117,7 @@ func fetchTableIndexes(ts sqlchemy.ITableSpec) (map[string]sDamengTableIndex, er
 		} else {
 			ret[info.IndexName] = sDamengTableIndex{
 				isPrimary: info.ConstraintType == "P",
+				isUnique:  info.Uniqueness == "UNIQUE",
 				indexName: info.IndexName,
 				colnames:  []string{info.ColumnName},
 			}
@@ -123,6 +126,47 @@ func fetchTableIndexes(ts sqlchemy.ITableSpec) (map[string]sDamengTableIndex, er
 	return ret, nil
 }
 
+func fetchTableForeignKeys(ts sqlchemy.ITableSpec) ([]sqlchemy.STableConstraint, error) {
+	type sForeignKeyInfo struct {
+		ConstraintName string `json:"CONSTRAINT_NAME"`
+		ColumnName     string `json:"COLUMN_NAME"`
+		RTableName     string `json:"R_TABLE_NAME"`
+		RColumnName    string `json:"R_COLUMN_NAME"`
+		DeleteRule     string `json:"DELETE_RULE"`
+	}
+	sqlStr := fmt.Sprintf("SELECT c.CONSTRAINT_NAME, a.COLUMN_NAME, r.TABLE_NAME AS R_TABLE_NAME, b.COLUMN_NAME AS R_COLUMN_NAME, c.DELETE_RULE FROM USER_CONSTRAINTS c JOIN USER_CONS_COLUMNS a ON a.CONSTRAINT_NAME=c.CONSTRAINT_NAME JOIN USER_CONSTRAINTS r ON r.CONSTRAINT_NAME=c.R_CONSTRAINT_NAME JOIN USER_CONS_COLUMNS b ON b.CONSTRAINT_NAME=c.R_CONSTRAINT_NAME AND b.POSITION=a.POSITION WHERE c.CONSTRAINT_TYPE='R' AND c.TABLE_NAME='%s' ORDER BY c.CONSTRAINT_NAME, a.POSITION", ts.Name())
+	query := ts.Database().NewRawQuery(sqlStr, "constraint_name", "column_name", "r_table_name", "r_column_name", "delete_rule")
+	infos := make([]sForeignKeyInfo, 0)
+	err := query.All(&infos)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]sqlchemy.STableConstraint, 0)
+	for i := 0; i < len(infos); {
+		cols := make([]string, 0)
+		fcols := make([]string, 0)
+		j := i
+		for ; j < len(infos) && infos[j].ConstraintName == infos[i].ConstraintName; j++ {
+			cols = append(cols, strings.ToLower(infos[j].ColumnName))
+			fcols = append(fcols, strings.ToLower(infos[j].RColumnName))
+		}
+		name := strings.ToLower(infos[i].ConstraintName)
+		ftable := strings.ToLower(infos[i].RTableName)
+		fk := sqlchemy.NewTableForeignKey(nil, name, cols, ftable, fcols, infos[i].DeleteRule, "")
+		// USER_CONSTRAINTS keeps no update rule, take it from the declared foreign key
+		for _, declared := range ts.ForeignKeys() {
+			probe := sqlchemy.NewTableForeignKey(nil, name, cols, ftable, fcols, infos[i].DeleteRule, declared.OnUpdate())
+			if declared.IsIdentical(probe) {
+				fk = probe
+				break
+			}
+		}
+		ret = append(ret, fk)
+		i = j
+	}
+	return ret, nil
+}
+
 func fetchTableAutoIncrementCol(ts sqlchemy.ITableSpec) (*sDamengAutoIncrementInfo, error) {
 	sqlStr := fmt.Sprintf("SELECT a.NAME, c.INFO6 from SYSCOLUMNS a, SYSOBJECTS c WHERE a.INFO2 & 0x01 = 0x01 AND a.ID=c.ID and c.NAME='%s' AND c.SCHID=CURRENT_SCHID", ts.Name())
 	query := ts.Database().NewRawQuery(sqlStr, "name", "info6")
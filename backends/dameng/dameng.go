@@ -60,6 +60,13 @@ func (dameng *SDamengBackend) CanSupportRowAffected() bool {
 	return true
 }
 
+// CanSupportCTE returns wether the backend supports common table expressions,
+// the recursive WITH of Dameng follows the Oracle syntax without the RECURSIVE keyword, so CTE is
+// off: plain CTEs are inlined as subqueries and recursive ones fail with ErrCTENotSupported
+func (dameng *SDamengBackend) CanSupportCTE() bool {
+	return false
+}
+
 // CanInsertOrUpdate returns weather the backend supports InsertOrUpdate
 func (dameng *SDamengBackend) CanInsertOrUpdate() bool {
 	return true
@@ -82,6 +89,10 @@ func (dameng *SDamengBackend) UpdateSQLTemplate() string {
 	return `UPDATE "{{ .Table }}" SET {{ .Columns }} WHERE {{ .Conditions }}`
 }
 
+func (dameng *SDamengBackend) DeleteSQLTemplate() string {
+	return `DELETE FROM "{{ .Table }}" WHERE {{ .Conditions }}`
+}
+
 func (dameng *SDamengBackend) PrepareInsertOrUpdateSQL(ts sqlchemy.ITableSpec, insertColNames []string, insertFields []string, onPrimaryCols []string, updateSetCols []string, insertValues []interface{}, updateValues []interface{}) (string, []interface{}) {
 	sqlTemp := `MERGE INTO "{{ .Table }}" T1 USING (SELECT {{ .SelectValues }} FROM DUAL) T2 ON ({{ .OnConditions }}) WHEN NOT MATCHED THEN INSERT({{ .Columns }}) VALUES ({{ .Values }}) WHEN MATCHED THEN UPDATE SET {{ .SetValues }}`
 	selectValues := make([]string, 0, len(insertColNames))
@@ -156,6 +167,9 @@ func (dameng *SDamengBackend) GetCreateSQLs(ts sqlchemy.ITableSpec) []string {
 	if len(primaries) > 0 {
 		cols = append(cols, fmt.Sprintf("NOT CLUSTER PRIMARY KEY (%s)", strings.Join(primaries, ", ")))
 	}
+	for _, fk := range ts.ForeignKeys() {
+		cols = append(cols, fk.DefinitionString(`"`))
+	}
 	sqls := []string{
 		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (%s);`, ts.Name(), strings.Join(cols, ", ")),
 	}
@@ -191,9 +205,13 @@ func (dameng *SDamengBackend) FetchIndexesAndConstraints(ts sqlchemy.ITableSpec)
 		if indexes[k].isPrimary {
 			continue
 		}
-		retIdxes = append(retIdxes, sqlchemy.NewTableIndex(ts, indexes[k].indexName, indexes[k].colnames, false))
+		retIdxes = append(retIdxes, sqlchemy.NewTableIndex(ts, indexes[k].indexName, indexes[k].colnames, indexes[k].isUnique))
+	}
+	constraints, err := fetchTableForeignKeys(ts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "fetchTableForeignKeys")
 	}
-	return retIdxes, nil, nil
+	return retIdxes, constraints, nil
 }
 
 func getTextSqlType(tagmap map[string]string) (string, map[string]string) {
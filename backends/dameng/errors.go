@@ -0,0 +1,41 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dameng
+
+import (
+	"gitee.com/chunanyong/dm"
+
+	"yunion.io/x/sqlchemy"
+)
+
+var damengErrorClasses = map[int32]error{
+	-6602:  sqlchemy.ErrDuplicateEntry,      // 违反唯一性约束
+	-6607:  sqlchemy.ErrForeignKeyViolation, // 违反引用约束, parent key not found
+	-6608:  sqlchemy.ErrForeignKeyViolation, // 违反引用约束, child record found
+	-6403:  sqlchemy.ErrDeadlock,            // 死锁
+	-6405:  sqlchemy.ErrLockTimeout,         // 锁超时
+	-6108:  sqlchemy.ErrDataTooLong,         // 字符串截断
+	-2106:  sqlchemy.ErrTableNotExists,      // 无效的表或视图名
+	-70019: sqlchemy.ErrConnectionLost,      // 网络通信异常
+}
+
+// ClassifyError translates a Dameng driver error into an Error constant of sqlchemy
+func (dameng *SDamengBackend) ClassifyError(err error) error {
+	dmErr, ok := err.(*dm.DmError)
+	if !ok {
+		return dameng.SBaseBackend.ClassifyError(err)
+	}
+	return damengErrorClasses[dmErr.ErrCode]
+}
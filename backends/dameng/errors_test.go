@@ -0,0 +1,44 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dameng
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"gitee.com/chunanyong/dm"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestClassifyError(t *testing.T) {
+	backend := &SDamengBackend{}
+	cases := []struct {
+		err  error
+		want error
+	}{
+		{&dm.DmError{ErrCode: -6602, ErrText: "违反唯一性约束[INDEX33555470]"}, sqlchemy.ErrDuplicateEntry},
+		{&dm.DmError{ErrCode: -2106, ErrText: "无效的表或视图名[T1]"}, sqlchemy.ErrTableNotExists},
+		{&dm.DmError{ErrCode: -6108, ErrText: "字符串截断"}, sqlchemy.ErrDataTooLong},
+		{&dm.DmError{ErrCode: -2007, ErrText: "语法分析出错"}, nil},
+		{driver.ErrBadConn, sqlchemy.ErrConnectionLost},
+	}
+	for _, c := range cases {
+		got := backend.ClassifyError(c.err)
+		if got != c.want {
+			t.Errorf("%s: want %v got %v", c.err, c.want, got)
+		}
+	}
+}
@@ -17,6 +17,8 @@ package dameng
 import (
 	"testing"
 
+	"yunion.io/x/pkg/errors"
+
 	"yunion.io/x/sqlchemy"
 	"yunion.io/x/sqlchemy/backends/tests"
 )
@@ -133,3 +135,34 @@ func TestCountQuery(t *testing.T) {
 	want := `SELECT COUNT(*) AS "count" FROM (SELECT "t1"."col0" AS "col0", MAX("t1"."col1") AS "col1", MAX("t1"."col2") AS "col2" FROM "test" AS "t1" GROUP BY "t1"."col0") AS "t2"`
 	testGotWant(t, cq.String(), want)
 }
+
+func TestCTEQueryInline(t *testing.T) {
+	testReset()
+	cte := sqlchemy.NewCTE("big", testTable.Query(testTable.Field("col0"), testTable.Field("col1")).GT("col1", 100))
+	q := cte.Query(cte.Field("col0")).Equals("col0", "abc")
+	want := `SELECT "t2"."col0" AS "col0" FROM (SELECT "t1"."col0" AS "col0", "t1"."col1" AS "col1" FROM "test" AS "t1" WHERE "t1"."col1" >  ? ) AS "t2" WHERE "t2"."col0" =  ? `
+	testGotWant(t, q.String(), want)
+	if len(q.Variables()) != 2 {
+		t.Errorf("want 2 variables got %d", len(q.Variables()))
+	}
+
+	t.Run("query with inline cte", func(t *testing.T) {
+		testReset()
+		q := testTable.Query(testTable.Field("col0"))
+		cte := q.With("big", testTable.Query(testTable.Field("col0")).GT("col1", 100))
+		q = q.Join(cte, sqlchemy.Equals(testTable.Field("col0"), cte.Field("col0")))
+		want := `SELECT "t1"."col0" AS "col0" FROM "test" AS "t1" JOIN (SELECT "t1"."col0" AS "col0" FROM "test" AS "t1" WHERE "t1"."col1" >  ? ) AS "t2" ON "t1"."col0" = "t2"."col0"`
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query with recursive cte", func(t *testing.T) {
+		testReset()
+		anchor := testTable.Query(testTable.Field("col0"))
+		_, err := sqlchemy.NewRecursiveCTE("tree", anchor, func(self *sqlchemy.SCTE) sqlchemy.IQuery {
+			return anchor
+		})
+		if errors.Cause(err) != sqlchemy.ErrCTENotSupported {
+			t.Errorf("want ErrCTENotSupported got %v", err)
+		}
+	})
+}
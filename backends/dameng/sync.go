@@ -27,6 +27,12 @@ import (
 func (mysql *SDamengBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges) []string {
 	ret := make([]string, 0)
 
+	for _, fk := range changes.RemoveConstraints {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT "%s"`, ts.Name(), fk.Name())
+		ret = append(ret, sql)
+		log.Infof("%s;", sql)
+	}
+
 	for _, idx := range changes.RemoveIndexes {
 		sql := fmt.Sprintf(`DROP INDEX "%s"`, idx.Name())
 		ret = append(ret, sql)
@@ -37,7 +43,7 @@ func (mysql *SDamengBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, change
 
 	// first check if primary key is modifed
 	changePrimary := false
-	for _, col := range changes.RemoveColumns {
+	for _, col := range append(changes.RemoveColumns, changes.DropColumns...) {
 		if col.IsPrimary() {
 			changePrimary = true
 			break
@@ -102,6 +108,10 @@ func (mysql *SDamengBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, change
 			log.Errorf("column %s is not nullable but no default, drop not nullable attribute", col.Name())
 		}
 	}
+	for _, col := range changes.DropColumns {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "%s";`, ts.Name(), col.Name())
+		alters = append(alters, sql)
+	}
 	for _, cols := range changes.UpdatedColumns {
 		if cols.OldCol.Name() != cols.NewCol.Name() {
 			// rename
@@ -189,9 +199,19 @@ func (mysql *SDamengBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, change
 		log.Infof("%s", sql)
 	}
 
+	for _, fk := range changes.AddConstraints {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" ADD %s`, ts.Name(), fk.DefinitionString(`"`))
+		ret = append(ret, sql)
+		log.Infof("%s", sql)
+	}
+
 	return ret
 }
 
 func createIndexSQL(ts sqlchemy.ITableSpec, idx sqlchemy.STableIndex) string {
-	return fmt.Sprintf(`CREATE INDEX "%s" ON "%s" (%s);`, idx.Name(), ts.Name(), strings.Join(idx.QuotedColumns(`"`), ","))
+	unique := ""
+	if idx.IsUnique() {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf(`CREATE %sINDEX "%s" ON "%s" (%s);`, unique, idx.Name(), ts.Name(), strings.Join(idx.KeyParts(`"`), ","))
 }
@@ -18,5 +18,6 @@ import (
 	_ "yunion.io/x/sqlchemy/backends/clickhouse"
 	_ "yunion.io/x/sqlchemy/backends/dameng"
 	_ "yunion.io/x/sqlchemy/backends/mysql"
+	_ "yunion.io/x/sqlchemy/backends/postgres"
 	_ "yunion.io/x/sqlchemy/backends/sqlite"
 )
@@ -15,16 +15,58 @@
 package mysql
 
 import (
+	"database/sql/driver"
+
 	"github.com/go-sql-driver/mysql"
+
+	"yunion.io/x/sqlchemy"
 )
 
 const (
-	mysqlErrorTableNotExist = 0x47a
+	mysqlErrorDupEntry            = 1062
+	mysqlErrorDupEntryWithKeyName = 1586
+	mysqlErrorLockWaitTimeout     = 1205
+	mysqlErrorLockDeadlock        = 1213
+	mysqlErrorNoReferencedRow     = 1216
+	mysqlErrorRowIsReferenced     = 1217
+	mysqlErrorRowIsReferenced2    = 1451
+	mysqlErrorNoReferencedRow2    = 1452
+	mysqlErrorTableNotExist       = 1146
+	mysqlErrorDataTooLong         = 1406
+	mysqlErrorServerGone          = 2006
+	mysqlErrorServerLost          = 2013
 )
 
+var mysqlErrorClasses = map[uint16]error{
+	mysqlErrorDupEntry:            sqlchemy.ErrDuplicateEntry,
+	mysqlErrorDupEntryWithKeyName: sqlchemy.ErrDuplicateEntry,
+	mysqlErrorLockWaitTimeout:     sqlchemy.ErrLockTimeout,
+	mysqlErrorLockDeadlock:        sqlchemy.ErrDeadlock,
+	mysqlErrorNoReferencedRow:     sqlchemy.ErrForeignKeyViolation,
+	mysqlErrorRowIsReferenced:     sqlchemy.ErrForeignKeyViolation,
+	mysqlErrorRowIsReferenced2:    sqlchemy.ErrForeignKeyViolation,
+	mysqlErrorNoReferencedRow2:    sqlchemy.ErrForeignKeyViolation,
+	mysqlErrorTableNotExist:       sqlchemy.ErrTableNotExists,
+	mysqlErrorDataTooLong:         sqlchemy.ErrDataTooLong,
+	mysqlErrorServerGone:          sqlchemy.ErrConnectionLost,
+	mysqlErrorServerLost:          sqlchemy.ErrConnectionLost,
+}
+
 func isMysqlError(err error, code uint16) bool {
 	if myErr, ok := err.(*mysql.MySQLError); ok {
 		return myErr.Number == code
 	}
 	return false
 }
+
+// ClassifyError translates a MySQL driver error into an Error constant of sqlchemy
+func (mysqlBackend *SMySQLBackend) ClassifyError(err error) error {
+	switch err {
+	case mysql.ErrInvalidConn, driver.ErrBadConn:
+		return sqlchemy.ErrConnectionLost
+	}
+	if myErr, ok := err.(*mysql.MySQLError); ok {
+		return mysqlErrorClasses[myErr.Number]
+	}
+	return mysqlBackend.SBaseBackend.ClassifyError(err)
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+func TestClassifyError(t *testing.T) {
+	backend := &SMySQLBackend{}
+	cases := []struct {
+		err  error
+		want error
+	}{
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}, sqlchemy.ErrDuplicateEntry},
+		{&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}, sqlchemy.ErrForeignKeyViolation},
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, sqlchemy.ErrDeadlock},
+		{&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, sqlchemy.ErrLockTimeout},
+		{&mysql.MySQLError{Number: 1406, Message: "Data too long for column 'name'"}, sqlchemy.ErrDataTooLong},
+		{&mysql.MySQLError{Number: 1146, Message: "Table 'test.t1' doesn't exist"}, sqlchemy.ErrTableNotExists},
+		{mysql.ErrInvalidConn, sqlchemy.ErrConnectionLost},
+		{&mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, nil},
+		{errors.Error("other error"), nil},
+	}
+	for _, c := range cases {
+		got := backend.ClassifyError(c.err)
+		if got != c.want {
+			t.Errorf("%s: want %v got %v", c.err, c.want, got)
+		}
+	}
+}
@@ -56,10 +56,33 @@ func (mysql *SMySQLBackend) CanInsertOrUpdate() bool {
 	return true
 }
 
+// CanSupportSavepoint returns wether the backend supports SAVEPOINT for nested transactions
+func (mysql *SMySQLBackend) CanSupportSavepoint() bool {
+	return true
+}
+
+// CanSupportCTE returns wether the backend supports common table expressions
+func (mysql *SMySQLBackend) CanSupportCTE() bool {
+	return true
+}
+
 func (mysql *SMySQLBackend) InsertOrUpdateSQLTemplate() string {
 	return "INSERT INTO `{{ .Table }}` ({{ .Columns }}) VALUES ({{ .Values }}) ON DUPLICATE KEY UPDATE {{ .SetValues }}"
 }
 
+func (mysql *SMySQLBackend) InsertOrUpdateBatchSQLTemplate() string {
+	return "INSERT INTO `{{ .Table }}` ({{ .Columns }}) VALUES {{ .Values }} ON DUPLICATE KEY UPDATE {{ range $i, $c := .UpdateColumns }}{{ if $i }}, {{ end }}`{{ $c }}` = VALUES(`{{ $c }}`){{ end }}{{ range $i, $c := .VersionColumns }}{{ if or $i $.UpdateColumns }}, {{ end }}`{{ $c }}` = `{{ $c }}` + 1{{ end }}"
+}
+
+// MaxPlaceholders returns the maximal number of placeholders in a statement
+func (mysql *SMySQLBackend) MaxPlaceholders() int {
+	return 65535
+}
+
+func (mysql *SMySQLBackend) LiteralStyle() sqlchemy.SLiteralStyle {
+	return sqlchemy.SLiteralStyle{BackslashEscape: true}
+}
+
 func (mysql *SMySQLBackend) CurrentUTCTimeStampString() string {
 	return "UTC_TIMESTAMP()"
 }
@@ -84,6 +107,9 @@ func (mysql *SMySQLBackend) GetCreateSQLs(ts sqlchemy.ITableSpec) []string {
 	if len(primaries) > 0 {
 		cols = append(cols, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaries, ", ")))
 	}
+	for _, fk := range ts.ForeignKeys() {
+		cols = append(cols, fk.DefinitionString("`"))
+	}
 	sqls := []string{
 		fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n%s\n) ENGINE=InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci%s", ts.Name(), strings.Join(cols, ",\n"), autoInc),
 	}
@@ -97,6 +123,20 @@ func (msyql *SMySQLBackend) IsSupportIndexAndContraints() bool {
 	return true
 }
 
+// IsSupportIndexFeature returns whether the server supports an optional feature of index definitions,
+// MySQL before 8.0 parses but ignores DESC, and functional indexes require 8.0.13
+func (mysql *SMySQLBackend) IsSupportIndexFeature(ts sqlchemy.ITableSpec, feature sqlchemy.TIndexFeature) bool {
+	switch feature {
+	case sqlchemy.INDEX_FEATURE_PREFIX, sqlchemy.INDEX_FEATURE_FULLTEXT, sqlchemy.INDEX_FEATURE_SPATIAL:
+		return true
+	case sqlchemy.INDEX_FEATURE_DESC:
+		return fetchServerVersion(ts.Database()).isSupportDescIndex()
+	case sqlchemy.INDEX_FEATURE_EXPRESSION:
+		return fetchServerVersion(ts.Database()).isSupportExpressionIndex()
+	}
+	return false
+}
+
 func (mysql *SMySQLBackend) FetchTableColumnSpecs(ts sqlchemy.ITableSpec) ([]sqlchemy.IColumnSpec, error) {
 	sql := fmt.Sprintf("SHOW FULL COLUMNS IN `%s`", ts.Name())
 	query := ts.Database().NewRawQuery(sql, "field", "type", "collation", "null", "key", "default", "extra", "privileges", "comment")
@@ -126,7 +166,21 @@ func (mysql *SMySQLBackend) FetchIndexesAndConstraints(ts sqlchemy.ITableSpec) (
 	}
 	indexes := parseIndexes(ts, defStr)
 	constraints := parseConstraints(defStr)
-	return indexes, constraints, nil
+	// skip the indexes created implicitly by MySQL for the foreign keys, which are named after the constraints
+	ret := make([]sqlchemy.STableIndex, 0, len(indexes))
+	for i := range indexes {
+		implicit := false
+		for j := range constraints {
+			if indexes[i].Name() == constraints[j].Name() {
+				implicit = true
+				break
+			}
+		}
+		if !implicit {
+			ret = append(ret, indexes[i])
+		}
+	}
+	return ret, constraints, nil
 }
 
 func getTextSqlType(tagmap map[string]string) string {
@@ -0,0 +1,429 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/sqlchemy"
+)
+
+const (
+	mysqlErrorUnknownAlterAlgorithm   = 1800
+	mysqlErrorUnknownAlterLock        = 1801
+	mysqlErrorAlterNotSupported       = 1845
+	mysqlErrorAlterNotSupportedReason = 1846
+)
+
+// isAlterNotSupported returns whether err indicates the ALGORITHM or LOCK clause of an alter is not allowed
+func isAlterNotSupported(err error) bool {
+	var myErr *mysql.MySQLError
+	if stderrors.As(err, &myErr) {
+		switch myErr.Number {
+		case mysqlErrorUnknownAlterAlgorithm, mysqlErrorUnknownAlterLock, mysqlErrorAlterNotSupported, mysqlErrorAlterNotSupportedReason:
+			return true
+		}
+	}
+	return false
+}
+
+// CommitTableChanges applies the changes without blocking writes with SYNC_STRATEGY_ONLINE,
+// each alter is tried with ALGORITHM=INSTANT or ALGORITHM=INPLACE, LOCK=NONE, and if MySQL
+// rejects them, the table is copied to a shadow table kept in sync by triggers and swapped by RENAME
+func (mysqlBackend *SMySQLBackend) CommitTableChanges(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges, opts sqlchemy.SSyncOptions) error {
+	if opts.Strategy != sqlchemy.SYNC_STRATEGY_ONLINE {
+		return mysqlBackend.SBaseBackend.CommitTableChanges(ts, changes, opts)
+	}
+	osc := &sOnlineSchemaChange{
+		backend: mysqlBackend,
+		ts:      ts,
+		changes: changes,
+		opts:    opts,
+	}
+	return osc.run()
+}
+
+type sOnlineStep struct {
+	// variants are the statements tried in order until one is allowed
+	variants []string
+	// shadow indicates copying to a shadow table if none of the variants is allowed
+	shadow bool
+	// alterColumns is the pending alter of columns applied to the shadow table
+	alterColumns string
+	// indexes are the pending indexes created on the shadow table
+	indexes []sqlchemy.STableIndex
+}
+
+type sOnlineSchemaChange struct {
+	backend *SMySQLBackend
+	ts      sqlchemy.ITableSpec
+	changes sqlchemy.STableChanges
+	opts    sqlchemy.SSyncOptions
+}
+
+// withAlterHints appends the ALGORITHM and LOCK clauses to an ALTER TABLE, CREATE INDEX or DROP INDEX statement
+func withAlterHints(sql string, algorithm string, lock string) string {
+	sql = strings.TrimSuffix(strings.TrimSpace(sql), ";")
+	hints := []string{"ALGORITHM=" + algorithm}
+	if len(lock) > 0 {
+		hints = append(hints, "LOCK="+lock)
+	}
+	if strings.HasPrefix(sql, "ALTER TABLE ") {
+		return sql + ", " + strings.Join(hints, ", ")
+	}
+	return sql + " " + strings.Join(hints, " ")
+}
+
+func (osc *sOnlineSchemaChange) steps() []sOnlineStep {
+	steps := make([]sOnlineStep, 0)
+	drops := osc.backend.CommitTableChangeSQL(osc.ts, sqlchemy.STableChanges{
+		RemoveConstraints: osc.changes.RemoveConstraints,
+		RemoveIndexes:     osc.changes.RemoveIndexes,
+	})
+	for _, sql := range drops {
+		// dropping is a change of metadata, fall back to the plain statement on old versions
+		steps = append(steps, sOnlineStep{variants: []string{withAlterHints(sql, "INPLACE", "NONE"), sql}})
+	}
+	alters := osc.backend.CommitTableChangeSQL(osc.ts, sqlchemy.STableChanges{
+		RemoveColumns:  osc.changes.RemoveColumns,
+		DropColumns:    osc.changes.DropColumns,
+		UpdatedColumns: osc.changes.UpdatedColumns,
+		AddColumns:     osc.changes.AddColumns,
+		OldColumns:     osc.changes.OldColumns,
+	})
+	for _, sql := range alters {
+		steps = append(steps, sOnlineStep{
+			variants:     []string{withAlterHints(sql, "INSTANT", ""), withAlterHints(sql, "INPLACE", "NONE")},
+			shadow:       true,
+			alterColumns: sql,
+			indexes:      osc.changes.AddIndexes,
+		})
+	}
+	for i := range osc.changes.AddIndexes {
+		sql := createIndexSQL(osc.ts, osc.changes.AddIndexes[i])
+		steps = append(steps, sOnlineStep{
+			variants: []string{withAlterHints(sql, "INPLACE", "NONE")},
+			shadow:   true,
+			indexes:  osc.changes.AddIndexes[i:],
+		})
+	}
+	adds := osc.backend.CommitTableChangeSQL(osc.ts, sqlchemy.STableChanges{
+		AddConstraints: osc.changes.AddConstraints,
+	})
+	for _, sql := range adds {
+		steps = append(steps, sOnlineStep{
+			variants: []string{withAlterHints(sql, "INPLACE", "NONE")},
+			shadow:   true,
+		})
+	}
+	return steps
+}
+
+func (osc *sOnlineSchemaChange) run() error {
+	db := osc.ts.Database()
+	for _, step := range osc.steps() {
+		done := false
+		for _, sql := range step.variants {
+			osc.opts.Report(sqlchemy.SSyncProgress{Table: osc.ts.Name(), Stage: sqlchemy.SYNC_STAGE_ALTER, SQL: sql})
+			_, err := db.Exec(sql)
+			if err == nil {
+				done = true
+				break
+			}
+			if !isAlterNotSupported(err) {
+				return errors.Wrapf(err, "exec %s", sql)
+			}
+			log.Debugf("%s not supported: %s", sql, err)
+		}
+		if done {
+			continue
+		}
+		if !step.shadow {
+			return errors.Wrapf(sqlchemy.ErrOnlineChangeNotSupported, "%s", step.variants[len(step.variants)-1])
+		}
+		// the remaining changes are applied by the shadow table
+		return osc.copyToShadow(step.alterColumns, step.indexes)
+	}
+	return nil
+}
+
+// sColumnPair is a column of the table copied to the column of the shadow table
+type sColumnPair struct {
+	src string
+	dst string
+}
+
+func quoteNames(names []string, prefix string) string {
+	ret := make([]string, len(names))
+	for i := range names {
+		ret[i] = fmt.Sprintf("%s`%s`", prefix, names[i])
+	}
+	return strings.Join(ret, ", ")
+}
+
+func (osc *sOnlineSchemaChange) columnPairs(columnsPending bool) ([]sColumnPair, []sColumnPair) {
+	renames := make(map[string]string)
+	for _, cols := range osc.changes.UpdatedColumns {
+		renames[cols.OldCol.Name()] = cols.NewCol.Name()
+	}
+	dropped := make(map[string]bool)
+	for _, col := range osc.changes.DropColumns {
+		dropped[col.Name()] = true
+	}
+	pairs := make([]sColumnPair, 0)
+	primaries := make([]sColumnPair, 0)
+	for _, col := range osc.changes.OldColumns {
+		if dropped[col.Name()] {
+			continue
+		}
+		pair := sColumnPair{src: col.Name(), dst: col.Name()}
+		if name, ok := renames[col.Name()]; ok {
+			pair.dst = name
+			if !columnsPending {
+				// already renamed in place
+				pair.src = name
+			}
+		}
+		pairs = append(pairs, pair)
+		if col.IsPrimary() {
+			primaries = append(primaries, pair)
+		}
+	}
+	if !columnsPending {
+		for _, col := range osc.changes.AddColumns {
+			pairs = append(pairs, sColumnPair{src: col.Name(), dst: col.Name()})
+		}
+	}
+	return pairs, primaries
+}
+
+// shadowTriggerSQLs returns the triggers replaying the writes to table on the shadow table
+func shadowTriggerSQLs(table, shadow string, pairs []sColumnPair, primaries []sColumnPair) ([]string, []string) {
+	srcs := make([]string, len(pairs))
+	dsts := make([]string, len(pairs))
+	for i := range pairs {
+		srcs[i] = pairs[i].src
+		dsts[i] = pairs[i].dst
+	}
+	conds := make([]string, len(primaries))
+	for i := range primaries {
+		conds[i] = fmt.Sprintf("`%s` <=> OLD.`%s`", primaries[i].dst, primaries[i].src)
+	}
+	replace := fmt.Sprintf("REPLACE INTO `%s` (%s) VALUES (%s)", shadow, quoteNames(dsts, ""), quoteNames(srcs, "NEW."))
+	del := fmt.Sprintf("DELETE IGNORE FROM `%s` WHERE %s", shadow, strings.Join(conds, " AND "))
+	names := []string{shadow + "_ins", shadow + "_upd", shadow + "_del"}
+	return names, []string{
+		fmt.Sprintf("CREATE TRIGGER `%s` AFTER INSERT ON `%s` FOR EACH ROW %s", names[0], table, replace),
+		fmt.Sprintf("CREATE TRIGGER `%s` AFTER UPDATE ON `%s` FOR EACH ROW BEGIN %s; %s; END", names[1], table, del, replace),
+		fmt.Sprintf("CREATE TRIGGER `%s` AFTER DELETE ON `%s` FOR EACH ROW %s", names[2], table, del),
+	}
+}
+
+// chunkSQLs returns the query of the upper bound of a chunk and the statement copying the rows in a chunk,
+// whose bounds are bound to the placeholders of the primary keys
+func chunkSQLs(table, shadow string, pairs []sColumnPair, primaries []sColumnPair, chunkSize int, hasLower, hasUpper bool) (string, string) {
+	srcs := make([]string, len(pairs))
+	dsts := make([]string, len(pairs))
+	for i := range pairs {
+		srcs[i] = pairs[i].src
+		dsts[i] = pairs[i].dst
+	}
+	pks := make([]string, len(primaries))
+	for i := range primaries {
+		pks[i] = primaries[i].src
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(pks)), ", ")
+	lower := ""
+	if hasLower {
+		lower = fmt.Sprintf(" WHERE (%s) > (%s)", quoteNames(pks, ""), placeholders)
+	}
+	bound := fmt.Sprintf("SELECT %s FROM `%s`%s ORDER BY %s LIMIT 1 OFFSET %d", quoteNames(pks, ""), table, lower, quoteNames(pks, ""), chunkSize-1)
+	conds := make([]string, 0, 2)
+	if hasLower {
+		conds = append(conds, fmt.Sprintf("(%s) > (%s)", quoteNames(pks, ""), placeholders))
+	}
+	if hasUpper {
+		conds = append(conds, fmt.Sprintf("(%s) <= (%s)", quoteNames(pks, ""), placeholders))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+	// rows replayed by the triggers are newer than the rows being copied, so ignore the duplicates
+	copySQL := fmt.Sprintf("INSERT IGNORE INTO `%s` (%s) SELECT %s FROM `%s`%s LOCK IN SHARE MODE", shadow, quoteNames(dsts, ""), quoteNames(srcs, ""), table, where)
+	return bound, copySQL
+}
+
+func (osc *sOnlineSchemaChange) checkShadowCopy(alterColumns string) error {
+	db := osc.ts.Database().DB()
+	if len(alterColumns) > 0 && isPrimaryChanged(osc.changes) {
+		return errors.Wrap(sqlchemy.ErrOnlineChangeNotSupported, "primary key changed")
+	}
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM information_schema.KEY_COLUMN_USAGE WHERE REFERENCED_TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_NAME = ?", osc.ts.Name()).Scan(&count)
+	if err != nil {
+		return errors.Wrap(err, "query referencing foreign keys")
+	}
+	if count > 0 {
+		// the foreign keys would follow the renamed table
+		return errors.Wrap(sqlchemy.ErrOnlineChangeNotSupported, "table referenced by foreign keys")
+	}
+	err = db.QueryRow("SELECT COUNT(*) FROM information_schema.TRIGGERS WHERE EVENT_OBJECT_SCHEMA = DATABASE() AND EVENT_OBJECT_TABLE = ?", osc.ts.Name()).Scan(&count)
+	if err != nil {
+		return errors.Wrap(err, "query triggers")
+	}
+	if count > 0 {
+		return errors.Wrap(sqlchemy.ErrOnlineChangeNotSupported, "table has triggers")
+	}
+	return nil
+}
+
+// shadowTriggerSuffixLen is the length of the longest suffix appended to the shadow names, see shadowTriggerSQLs
+const shadowTriggerSuffixLen = len("_new_ins")
+
+// shadowTableNames returns the names of the shadow table and the swapped out table,
+// shadow tables of each run are named differently, so are the foreign keys named after them,
+// the name of a long table is shortened so that the names of the triggers fit the identifier limit
+func shadowTableNames(table string, stamp int64) (string, string) {
+	prefix := "_" + table
+	suffix := fmt.Sprintf("_%x", stamp)
+	if len(prefix)+len(suffix)+shadowTriggerSuffixLen > sqlchemy.IndexLimit {
+		// tables of the same prefix are distinguished by a checksum of the full name
+		sum := fmt.Sprintf("_%08x", crc32.ChecksumIEEE([]byte(table)))
+		prefix = prefix[:sqlchemy.IndexLimit-shadowTriggerSuffixLen-len(suffix)-len(sum)] + sum
+	}
+	return prefix + suffix + "_new", prefix + suffix + "_old"
+}
+
+func (osc *sOnlineSchemaChange) copyToShadow(alterColumns string, indexes []sqlchemy.STableIndex) error {
+	pairs, primaries := osc.columnPairs(len(alterColumns) > 0)
+	if len(primaries) == 0 {
+		return errors.Wrap(sqlchemy.ErrOnlineChangeNotSupported, "no primary key")
+	}
+	err := osc.checkShadowCopy(alterColumns)
+	if err != nil {
+		return err
+	}
+
+	table := osc.ts.Name()
+	shadow, old := shadowTableNames(table, time.Now().Unix())
+	clone := osc.ts.(*sqlchemy.STableSpec).Clone(shadow, 0)
+	sqls := []string{fmt.Sprintf("CREATE TABLE `%s` LIKE `%s`", shadow, table)}
+	if len(alterColumns) > 0 {
+		sqls = append(sqls, strings.Replace(alterColumns, fmt.Sprintf("ALTER TABLE `%s` ", table), fmt.Sprintf("ALTER TABLE `%s` ", shadow), 1))
+	}
+	// CREATE TABLE ... LIKE copies no foreign keys
+	sqls = append(sqls, osc.backend.CommitTableChangeSQL(clone, sqlchemy.STableChanges{
+		AddIndexes:     indexes,
+		AddConstraints: clone.ForeignKeys(),
+	})...)
+	triggers, triggerSQLs := shadowTriggerSQLs(table, shadow, pairs, primaries)
+	sqls = append(sqls, triggerSQLs...)
+
+	db := osc.ts.Database()
+	cleanup := func() {
+		for _, name := range triggers {
+			_, err := db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS `%s`", name))
+			if err != nil {
+				log.Errorf("drop shadow trigger %s fail: %s", name, err)
+			}
+		}
+		_, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s`", shadow))
+		if err != nil {
+			log.Errorf("drop shadow table %s fail: %s", shadow, err)
+		}
+	}
+	for _, sql := range sqls {
+		osc.opts.Report(sqlchemy.SSyncProgress{Table: table, Stage: sqlchemy.SYNC_STAGE_ALTER, SQL: sql})
+		_, err := db.Exec(sql)
+		if err != nil {
+			cleanup()
+			return errors.Wrapf(err, "exec %s", sql)
+		}
+	}
+
+	err = osc.copyRows(shadow, pairs, primaries)
+	if err != nil {
+		cleanup()
+		return errors.Wrap(err, "copyRows")
+	}
+
+	swap := fmt.Sprintf("RENAME TABLE `%s` TO `%s`, `%s` TO `%s`", table, old, shadow, table)
+	osc.opts.Report(sqlchemy.SSyncProgress{Table: table, Stage: sqlchemy.SYNC_STAGE_SWAP, SQL: swap})
+	_, err = db.Exec(swap)
+	if err != nil {
+		cleanup()
+		return errors.Wrapf(err, "exec %s", swap)
+	}
+	// the triggers are dropped along with the old table
+	drop := fmt.Sprintf("DROP TABLE `%s`", old)
+	osc.opts.Report(sqlchemy.SSyncProgress{Table: table, Stage: sqlchemy.SYNC_STAGE_SWAP, SQL: drop})
+	_, err = db.Exec(drop)
+	if err != nil {
+		return errors.Wrapf(err, "exec %s", drop)
+	}
+	return nil
+}
+
+func (osc *sOnlineSchemaChange) copyRows(shadow string, pairs []sColumnPair, primaries []sColumnPair) error {
+	db := osc.ts.Database()
+	table := osc.ts.Name()
+	var total sql.NullInt64
+	err := db.DB().QueryRow("SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", table).Scan(&total)
+	if err != nil {
+		return errors.Wrap(err, "query table rows")
+	}
+	progress := sqlchemy.SSyncProgress{Table: table, Stage: sqlchemy.SYNC_STAGE_COPY, TotalRows: total.Int64}
+	var lower []interface{}
+	for {
+		bound, _ := chunkSQLs(table, shadow, pairs, primaries, osc.opts.GetChunkSize(), lower != nil, false)
+		upper := make([]interface{}, len(primaries))
+		dests := make([]interface{}, len(primaries))
+		for i := range upper {
+			dests[i] = &upper[i]
+		}
+		err := db.DB().QueryRow(bound, lower...).Scan(dests...)
+		if err == sql.ErrNoRows {
+			upper = nil
+		} else if err != nil {
+			return errors.Wrapf(err, "query %s", bound)
+		}
+		_, copySQL := chunkSQLs(table, shadow, pairs, primaries, osc.opts.GetChunkSize(), lower != nil, upper != nil)
+		args := append(append([]interface{}{}, lower...), upper...)
+		progress.SQL = copySQL
+		result, err := db.Exec(copySQL, args...)
+		if err != nil {
+			return errors.Wrapf(err, "exec %s", copySQL)
+		}
+		if rows, err := result.RowsAffected(); err == nil {
+			progress.CopiedRows += rows
+		}
+		osc.opts.Report(progress)
+		if upper == nil {
+			return nil
+		}
+		lower = upper
+	}
+}
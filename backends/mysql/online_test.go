@@ -0,0 +1,127 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestOnlineSteps(t *testing.T) {
+	type TableStruct1 struct {
+		Id   int    `primary:"true"`
+		Name string `width:"64"`
+	}
+	type TableStruct2 struct {
+		Id    int    `primary:"true"`
+		Name  string `width:"128" index:"true"`
+		Email string `width:"64" nullable:"true"`
+	}
+
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.MySQLBackend)
+	ts1 := sqlchemy.NewTableSpecFromStruct(TableStruct1{}, "osc_tbl")
+	ts2 := sqlchemy.NewTableSpecFromStruct(TableStruct2{}, "osc_tbl")
+	changes := sqlchemy.STableChanges{
+		AddIndexes: ts2.Indexes(),
+		OldColumns: ts1.Columns(),
+	}
+	changes.RemoveColumns, changes.UpdatedColumns, changes.AddColumns = sqlchemy.DiffCols(ts2.Name(), ts1.Columns(), ts2.Columns())
+
+	osc := &sOnlineSchemaChange{backend: &SMySQLBackend{}, ts: ts2, changes: changes}
+	steps := osc.steps()
+	if len(steps) != 2 {
+		t.Fatalf("want 2 steps got %d", len(steps))
+	}
+	alter := "ALTER TABLE `osc_tbl` MODIFY COLUMN `name` VARCHAR(128) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci', ADD COLUMN `email` VARCHAR(64) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci'"
+	want := []string{
+		alter + ", ALGORITHM=INSTANT",
+		alter + ", ALGORITHM=INPLACE, LOCK=NONE",
+	}
+	if !reflect.DeepEqual(steps[0].variants, want) || !steps[0].shadow || len(steps[0].indexes) != 1 {
+		t.Errorf("Expect: %s", want)
+		t.Errorf("Got: %s", steps[0].variants)
+	}
+	want = []string{"CREATE INDEX `ix_osc_tbl_name` ON `osc_tbl` (`name`) ALGORITHM=INPLACE LOCK=NONE"}
+	if !reflect.DeepEqual(steps[1].variants, want) || len(steps[1].alterColumns) > 0 {
+		t.Errorf("Expect: %s", want)
+		t.Errorf("Got: %s", steps[1].variants)
+	}
+
+	pairs, primaries := osc.columnPairs(true)
+	if len(pairs) != 2 || len(primaries) != 1 {
+		t.Fatalf("unexpected pairs %v primaries %v", pairs, primaries)
+	}
+	_, triggers := shadowTriggerSQLs("osc_tbl", "_osc_tbl_new", pairs, primaries)
+	wantTriggers := []string{
+		"CREATE TRIGGER `_osc_tbl_new_ins` AFTER INSERT ON `osc_tbl` FOR EACH ROW REPLACE INTO `_osc_tbl_new` (`id`, `name`) VALUES (NEW.`id`, NEW.`name`)",
+		"CREATE TRIGGER `_osc_tbl_new_upd` AFTER UPDATE ON `osc_tbl` FOR EACH ROW BEGIN DELETE IGNORE FROM `_osc_tbl_new` WHERE `id` <=> OLD.`id`; REPLACE INTO `_osc_tbl_new` (`id`, `name`) VALUES (NEW.`id`, NEW.`name`); END",
+		"CREATE TRIGGER `_osc_tbl_new_del` AFTER DELETE ON `osc_tbl` FOR EACH ROW DELETE IGNORE FROM `_osc_tbl_new` WHERE `id` <=> OLD.`id`",
+	}
+	if !reflect.DeepEqual(triggers, wantTriggers) {
+		t.Errorf("Expect: %s", wantTriggers)
+		t.Errorf("Got: %s", triggers)
+	}
+
+	bound, copySQL := chunkSQLs("osc_tbl", "_osc_tbl_new", pairs, primaries, 500, true, true)
+	if want := "SELECT `id` FROM `osc_tbl` WHERE (`id`) > (?) ORDER BY `id` LIMIT 1 OFFSET 499"; bound != want {
+		t.Errorf("want %s got %s", want, bound)
+	}
+	if want := "INSERT IGNORE INTO `_osc_tbl_new` (`id`, `name`) SELECT `id`, `name` FROM `osc_tbl` WHERE (`id`) > (?) AND (`id`) <= (?) LOCK IN SHARE MODE"; copySQL != want {
+		t.Errorf("want %s got %s", want, copySQL)
+	}
+}
+
+func TestIsAlterNotSupported(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: mysqlErrorAlterNotSupportedReason}, true},
+		{errors.Wrap(&mysql.MySQLError{Number: mysqlErrorUnknownAlterAlgorithm}, "exec"), true},
+		{&mysql.MySQLError{Number: mysqlErrorDupEntry}, false},
+		{errors.ErrNotFound, false},
+	}
+	for _, c := range cases {
+		if got := isAlterNotSupported(c.err); got != c.want {
+			t.Errorf("%s want %v got %v", c.err, c.want, got)
+		}
+	}
+}
+
+func TestShadowTableNames(t *testing.T) {
+	shadow, old := shadowTableNames("osc_tbl", 0x5f5e1000)
+	if shadow != "_osc_tbl_5f5e1000_new" || old != "_osc_tbl_5f5e1000_old" {
+		t.Errorf("unexpected names %s %s", shadow, old)
+	}
+	long1 := strings.Repeat("t", 60) + "1"
+	long2 := strings.Repeat("t", 60) + "2"
+	shadow1, old1 := shadowTableNames(long1, 0x5f5e1000)
+	shadow2, _ := shadowTableNames(long2, 0x5f5e1000)
+	if shadow1 == shadow2 {
+		t.Errorf("shadow names of %s and %s collide: %s", long1, long2, shadow1)
+	}
+	for _, name := range []string{shadow1, old1, shadow1 + "_ins", shadow1 + "_upd", shadow1 + "_del"} {
+		if len(name) > sqlchemy.IndexLimit {
+			t.Errorf("%s exceeds %d chars", name, sqlchemy.IndexLimit)
+		}
+	}
+}
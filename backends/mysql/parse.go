@@ -16,13 +16,14 @@ package mysql
 
 import (
 	"regexp"
+	"strings"
 
 	"yunion.io/x/sqlchemy"
 )
 
 const (
-	indexPattern      = `(?P<unique>UNIQUE\s+)?KEY ` + "`" + `(?P<name>\w+)` + "`" + ` \((?P<cols>` + "`" + `\w+` + "`" + `(\(\d+\))?(,\s*` + "`" + `\w+` + "`" + `(\(\d+\))?)*)\)`
-	constraintPattern = `CONSTRAINT ` + "`" + `(?P<name>\w+)` + "`" + ` FOREIGN KEY \((?P<cols>` + "`" + `\w+` + "`" + `(,\s*` + "`" + `\w+` + "`" + `)*)\) REFERENCES ` + "`" + `(?P<table>\w+)` + "`" + ` \((?P<fcols>` + "`" + `\w+` + "`" + `(,\s*` + "`" + `\w+` + "`" + `)*)\)`
+	indexPattern      = `(?m)^\s*(?P<kind>UNIQUE\s+|FULLTEXT\s+|SPATIAL\s+)?KEY ` + "`" + `(?P<name>\w+)` + "`" + ` `
+	constraintPattern = `CONSTRAINT ` + "`" + `(?P<name>\w+)` + "`" + ` FOREIGN KEY \((?P<cols>` + "`" + `\w+` + "`" + `(,\s*` + "`" + `\w+` + "`" + `)*)\) REFERENCES ` + "`" + `(?P<table>\w+)` + "`" + ` \((?P<fcols>` + "`" + `\w+` + "`" + `(,\s*` + "`" + `\w+` + "`" + `)*)\)` + `( ON DELETE (?P<ondelete>RESTRICT|CASCADE|SET NULL|NO ACTION|SET DEFAULT))?( ON UPDATE (?P<onupdate>RESTRICT|CASCADE|SET NULL|NO ACTION|SET DEFAULT))?`
 )
 
 var (
@@ -38,26 +39,39 @@ func parseConstraints(defStr string) []sqlchemy.STableConstraint {
 	matches := constraintRegexp.FindAllStringSubmatch(defStr, -1)
 	tcs := make([]sqlchemy.STableConstraint, len(matches))
 	for i := range matches {
-		tcs[i] = sqlchemy.NewTableConstraint(
+		tcs[i] = sqlchemy.NewTableForeignKey(
+			nil,
 			matches[i][1],
 			fetchColumns(matches[i][2]),
 			matches[i][4],
 			fetchColumns(matches[i][5]),
+			matches[i][8],
+			matches[i][10],
 		)
 	}
 	return tcs
 }
 
 func parseIndexes(ts sqlchemy.ITableSpec, defStr string) []sqlchemy.STableIndex {
-	matches := indexRegexp.FindAllStringSubmatch(defStr, -1)
-	tcs := make([]sqlchemy.STableIndex, len(matches))
-	for i := range matches {
-		tcs[i] = sqlchemy.NewTableIndex(
-			ts,
-			matches[i][2],
-			fetchColumns(matches[i][3]),
-			len(matches[i][1]) > 0,
-		)
+	matches := indexRegexp.FindAllStringSubmatchIndex(defStr, -1)
+	tcs := make([]sqlchemy.STableIndex, 0, len(matches))
+	for _, m := range matches {
+		keyParts, _, ok := sqlchemy.SplitParenthesized(defStr[m[1]:])
+		if !ok {
+			continue
+		}
+		opts := sqlchemy.SIndexOptions{
+			Name: defStr[m[4]:m[5]],
+		}
+		if m[2] >= 0 {
+			kind := strings.TrimSpace(defStr[m[2]:m[3]])
+			if kind == "UNIQUE" {
+				opts.Unique = true
+			} else {
+				opts.Type = kind
+			}
+		}
+		tcs = append(tcs, sqlchemy.NewTableIndexFromKeyParts(ts, keyParts, opts))
 	}
 	return tcs
 }
@@ -16,6 +16,8 @@ package mysql
 
 import (
 	"testing"
+
+	"yunion.io/x/sqlchemy"
 )
 
 const tableDef = `CREATE TABLE ` + "`" + `image_properties` + "`" + ` (
@@ -89,3 +91,66 @@ func TestParseCreateTable(t *testing.T) {
 		}
 	}
 }
+
+func TestParseForeignKeyActions(t *testing.T) {
+	def := "CREATE TABLE `members` (\n" +
+		"  `id` int(11) NOT NULL,\n" +
+		"  `group_id` int(11) DEFAULT NULL,\n" +
+		"  `owner_id` int(11) DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `fk_members_group_id` (`group_id`),\n" +
+		"  CONSTRAINT `fk_members_group_id` FOREIGN KEY (`group_id`) REFERENCES `groups` (`id`) ON DELETE CASCADE ON UPDATE SET NULL,\n" +
+		"  CONSTRAINT `fk_members_owner_id` FOREIGN KEY (`owner_id`) REFERENCES `users` (`id`) ON UPDATE CASCADE\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8"
+	cons := parseConstraints(def)
+	if len(cons) != 2 {
+		t.Fatalf("want 2 constraints got %d", len(cons))
+	}
+	if cons[0].Name() != "fk_members_group_id" || cons[0].ForeignTable() != "groups" || cons[0].OnDelete() != "CASCADE" || cons[0].OnUpdate() != "SET NULL" {
+		t.Errorf("unexpected constraint %s", cons[0].DefinitionString("`"))
+	}
+	if cons[1].OnDelete() != "" || cons[1].OnUpdate() != "CASCADE" {
+		t.Errorf("unexpected constraint %s", cons[1].DefinitionString("`"))
+	}
+}
+
+func TestParseIndexOptions(t *testing.T) {
+	def := "CREATE TABLE `docs` (\n" +
+		"  `id` int(11) NOT NULL,\n" +
+		"  `title` varchar(256) DEFAULT NULL,\n" +
+		"  `body` text,\n" +
+		"  `email` varchar(64) DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `ix_docs_title` (`title`(16),`id` DESC),\n" +
+		"  KEY `ix_docs_email` ((lower(`email`))),\n" +
+		"  FULLTEXT KEY `ix_docs_body` (`body`) /*!50100 WITH PARSER `ngram` */ \n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	idxs := parseIndexes(nil, def)
+	if len(idxs) != 3 {
+		t.Fatalf("want 3 indexes got %d", len(idxs))
+	}
+	if !idxs[0].IsUnique() || idxs[0].Prefix("title") != 16 || !idxs[0].IsDescending("id") || idxs[0].IsDescending("title") {
+		t.Errorf("unexpected index %s", idxs[0].KeyParts("`"))
+	}
+	if idxs[1].Expression() != "lower(`email`)" {
+		t.Errorf("unexpected expression %s", idxs[1].Expression())
+	}
+	if idxs[2].Type() != sqlchemy.INDEX_TYPE_FULLTEXT || !idxs[2].IsIdentical("body") {
+		t.Errorf("unexpected index %s %s", idxs[2].Type(), idxs[2].KeyParts("`"))
+	}
+
+	wants := []string{
+		"CREATE UNIQUE INDEX `ix_docs_title` ON `docs` (`id` DESC,`title`(16))",
+		"CREATE INDEX `ix_docs_email` ON `docs` ((lower(`email`)))",
+		"CREATE FULLTEXT INDEX `ix_docs_body` ON `docs` (`body`)",
+	}
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.MySQLBackend)
+	ts := sqlchemy.NewTableSpecFromStruct(struct {
+		Id int `primary:"true"`
+	}{}, "docs")
+	for i := range idxs {
+		if got := createIndexSQL(ts, idxs[i]); got != wants[i] {
+			t.Errorf("want %s got %s", wants[i], got)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package mysql
 import (
 	"testing"
 
+	"yunion.io/x/pkg/errors"
 	"yunion.io/x/sqlchemy"
 	"yunion.io/x/sqlchemy/backends/tests"
 )
@@ -77,6 +78,47 @@ func TestQuery(t *testing.T) {
 		want := "SELECT SUM(`t1`.`col1`) AS `total`, `t1`.`col0` AS `col0` FROM `test` AS `t1` GROUP BY `t1`.`col0` ORDER BY `total` ASC"
 		testGotWant(t, q.String(), want)
 	})
+
+	t.Run("query ROW_NUMBER window func", func(t *testing.T) {
+		testReset()
+		w := sqlchemy.NewWindow().PartitionBy(testTable.Field("col0")).Desc(testTable.Field("col1"))
+		q := testTable.Query(testTable.Field("col0"), sqlchemy.ROW_NUMBER("rn", w))
+		want := "SELECT `t1`.`col0` AS `col0`, ROW_NUMBER() OVER (PARTITION BY `t1`.`col0` ORDER BY `t1`.`col1` DESC) AS `rn` FROM `test` AS `t1`"
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query running SUM window func in subquery", func(t *testing.T) {
+		testReset()
+		w := sqlchemy.NewWindow().Asc(testTable.Field("col1")).Rows(sqlchemy.WINDOW_UNBOUNDED_PRECEDING, sqlchemy.WINDOW_CURRENT_ROW)
+		sq := testTable.Query(testTable.Field("col0")).AppendField(sqlchemy.SUM_OVER("running", testTable.Field("col1"), w)).SubQuery()
+		q := sq.Query(sq.Field("col0")).GT("running", 10)
+		want := "SELECT `t2`.`col0` AS `col0` FROM (SELECT `t1`.`col0` AS `col0`, SUM(`t1`.`col1`) OVER (ORDER BY `t1`.`col1` ASC ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS `running` FROM `test` AS `t1`) AS `t2` WHERE `t2`.`running` >  ? "
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query LAG window func with group by", func(t *testing.T) {
+		testReset()
+		w := sqlchemy.NewWindow().Asc(testTable.Field("col0"))
+		q := testTable.Query(testTable.Field("col0"), sqlchemy.LAG("prev", testTable.Field("col0"), 1, w)).GroupBy(testTable.Field("col0"))
+		want := "SELECT `t1`.`col0` AS `col0`, LAG(`t1`.`col0`, 1) OVER (ORDER BY `t1`.`col0` ASC) AS `prev` FROM `test` AS `t1` GROUP BY `t1`.`col0`"
+		testGotWant(t, q.String(), want)
+	})
+}
+
+func TestPageAfter(t *testing.T) {
+	testReset()
+	q := testTable.Query(testTable.Field("col2")).Asc(testTable.Field("col0")).Desc(testTable.Field("col1")).Limit(10)
+	q, err := q.PageAfter(&sqlchemy.SPageCursor{Values: []string{"abc", "12"}})
+	if err != nil {
+		t.Fatalf("PageAfter fail %s", err)
+	}
+	want := "SELECT `t1`.`col2` AS `col2`, `t1`.`col0` AS `col0`, `t1`.`col1` AS `col1` FROM `test` AS `t1` WHERE (`t1`.`col0` >  ? ) OR ((`t1`.`col0` =  ? ) AND (`t1`.`col1` <  ? )) ORDER BY `t1`.`col0` ASC, `t1`.`col1` DESC LIMIT 10"
+	testGotWant(t, q.String(), want)
+
+	_, err = q.PageAfter(&sqlchemy.SPageCursor{Values: []string{"abc"}})
+	if errors.Cause(err) != sqlchemy.ErrInvalidPageCursor {
+		t.Errorf("want ErrInvalidPageCursor got %v", err)
+	}
 }
 
 func TestCountQuery(t *testing.T) {
@@ -90,3 +132,26 @@ func TestCountQuery(t *testing.T) {
 	want := "SELECT COUNT(*) AS `count` FROM (SELECT `t1`.`col0` AS `col0`, MAX(`t1`.`col1`) AS `col1`, MAX(`t1`.`col2`) AS `col2` FROM `test` AS `t1` GROUP BY `t1`.`col0`) AS `t2`"
 	testGotWant(t, cq.String(), want)
 }
+
+func TestDeleteQuery(t *testing.T) {
+	testReset()
+	dq := tests.GetTestTableSpec().DeleteQuery()
+	dq.Filter(sqlchemy.OR(sqlchemy.Between(dq.Field("col1"), 1, 10), sqlchemy.NOT(sqlchemy.Like(dq.Field("col0"), "abc%"))))
+	want := "DELETE FROM `test` WHERE (`test`.`col1` BETWEEN  ?  AND  ? ) OR (NOT (`test`.`col0` LIKE  ? ))"
+	testGotWant(t, dq.String(), want)
+	if len(dq.Variables()) != 3 {
+		t.Errorf("want 3 variables got %d", len(dq.Variables()))
+	}
+}
+
+func TestUpdateQuery(t *testing.T) {
+	testReset()
+	uq := tests.GetTestTableSpec().UpdateQuery()
+	sub := testTable.Query(testTable.Field("col0")).GT("col1", 100).SubQuery()
+	uq.Set("col2", "text").SetField("col1", sqlchemy.ADD("", uq.Field("col1"), sqlchemy.NewConstField(1))).Filter(sqlchemy.In(uq.Field("col0"), sub))
+	want := "UPDATE `test` SET `col2` = ?, `col1` = `test`.`col1` + 1 WHERE `test`.`col0` IN (SELECT `t1`.`col0` AS `col0` FROM `test` AS `t1` WHERE `t1`.`col1` >  ? )"
+	testGotWant(t, uq.String(), want)
+	if len(uq.Variables()) != 2 {
+		t.Errorf("want 2 variables got %d", len(uq.Variables()))
+	}
+}
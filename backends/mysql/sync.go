@@ -25,6 +25,12 @@ import (
 func (mysql *SMySQLBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges) []string {
 	ret := make([]string, 0)
 
+	for _, fk := range changes.RemoveConstraints {
+		sql := fmt.Sprintf("ALTER TABLE `%s` DROP FOREIGN KEY `%s`", ts.Name(), fk.Name())
+		ret = append(ret, sql)
+		log.Infof("%s;", sql)
+	}
+
 	for _, idx := range changes.RemoveIndexes {
 		sql := fmt.Sprintf("DROP INDEX `%s` ON `%s`", idx.Name(), ts.Name())
 		ret = append(ret, sql)
@@ -34,29 +40,7 @@ func (mysql *SMySQLBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, changes
 	alters := make([]string, 0)
 
 	// first check if primary key is modifed
-	changePrimary := false
-	for _, col := range changes.RemoveColumns {
-		if col.IsPrimary() {
-			changePrimary = true
-			break
-		}
-	}
-	if !changePrimary {
-		for _, cols := range changes.UpdatedColumns {
-			if cols.OldCol.IsPrimary() != cols.NewCol.IsPrimary() {
-				changePrimary = true
-				break
-			}
-		}
-	}
-	if !changePrimary {
-		for _, col := range changes.AddColumns {
-			if col.IsPrimary() {
-				changePrimary = true
-				break
-			}
-		}
-	}
+	changePrimary := isPrimaryChanged(changes)
 	// in case of a primary key change, we first need to drop primary key.
 	// BUT if a mysql table has no primary key at all,
 	// exec drop primary key will cause error
@@ -98,6 +82,10 @@ func (mysql *SMySQLBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, changes
 			log.Errorf("column %s is not nullable but no default, drop not nullable attribute", col.Name())
 		}
 	}
+	for _, col := range changes.DropColumns {
+		sql := fmt.Sprintf("DROP COLUMN `%s`", col.Name())
+		alters = append(alters, sql)
+	}
 	for _, cols := range changes.UpdatedColumns {
 		if cols.OldCol.Name() != cols.NewCol.Name() {
 			sql := fmt.Sprintf("CHANGE COLUMN `%s` %s", cols.OldCol.Name(), cols.NewCol.DefinitionString())
@@ -135,9 +123,46 @@ func (mysql *SMySQLBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, changes
 		log.Infof("%s;", sql)
 	}
 
+	for _, fk := range changes.AddConstraints {
+		sql := fmt.Sprintf("ALTER TABLE `%s` ADD %s", ts.Name(), fk.DefinitionString("`"))
+		ret = append(ret, sql)
+		log.Infof("%s;", sql)
+	}
+
 	return ret
 }
 
+// isPrimaryChanged returns whether the changes modify the primary key
+func isPrimaryChanged(changes sqlchemy.STableChanges) bool {
+	for _, col := range changes.RemoveColumns {
+		if col.IsPrimary() {
+			return true
+		}
+	}
+	for _, col := range changes.DropColumns {
+		if col.IsPrimary() {
+			return true
+		}
+	}
+	for _, cols := range changes.UpdatedColumns {
+		if cols.OldCol.IsPrimary() != cols.NewCol.IsPrimary() {
+			return true
+		}
+	}
+	for _, col := range changes.AddColumns {
+		if col.IsPrimary() {
+			return true
+		}
+	}
+	return false
+}
+
 func createIndexSQL(ts sqlchemy.ITableSpec, idx sqlchemy.STableIndex) string {
-	return fmt.Sprintf("CREATE INDEX `%s` ON `%s` (%s)", idx.Name(), ts.Name(), strings.Join(idx.QuotedColumns("`"), ","))
+	kind := ""
+	if len(idx.Type()) > 0 {
+		kind = idx.Type() + " "
+	} else if idx.IsUnique() {
+		kind = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX `%s` ON `%s` (%s)", kind, idx.Name(), ts.Name(), strings.Join(idx.KeyParts("`"), ","))
 }
@@ -51,3 +51,183 @@ func TestSync(t *testing.T) {
 		t.Errorf("Got: %s", sqls)
 	}
 }
+
+func TestSyncDropColumns(t *testing.T) {
+	type TableStruct1 struct {
+		Id   uint64 `auto_increment:"true"`
+		Name string `width:"64" charset:"ascii"`
+		Note string `width:"64" charset:"ascii"`
+	}
+	type TableStruct2 struct {
+		Id   uint64 `auto_increment:"true"`
+		Name string `width:"64" charset:"ascii"`
+	}
+
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.MySQLBackend)
+	ts1 := sqlchemy.NewTableSpecFromStruct(TableStruct1{}, "table1")
+	ts2 := sqlchemy.NewTableSpecFromStruct(TableStruct2{}, "table1")
+
+	changes := sqlchemy.STableChanges{}
+	changes.RemoveColumns, changes.UpdatedColumns, changes.AddColumns = sqlchemy.DiffCols(ts2.Name(), ts1.Columns(), ts2.Columns())
+	backend := &SMySQLBackend{}
+	if sqls := backend.CommitTableChangeSQL(ts2, changes); len(sqls) > 0 {
+		t.Errorf("removed columns should be kept, got %s", sqls)
+	}
+	changes.DropColumns, changes.RemoveColumns = changes.RemoveColumns, nil
+	sqls := backend.CommitTableChangeSQL(ts2, changes)
+	want := []string{
+		"ALTER TABLE `table1` DROP COLUMN `note`;",
+	}
+	if !reflect.DeepEqual(sqls, want) {
+		t.Errorf("Expect: %s", want)
+		t.Errorf("Got: %s", sqls)
+	}
+}
+
+func TestIsNarrowingChange(t *testing.T) {
+	cases := []struct {
+		oldCol      sqlchemy.IColumnSpec
+		newCol      sqlchemy.IColumnSpec
+		destructive bool
+	}{
+		{
+			oldCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "64"}, false)},
+			newCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "128"}, false)},
+			destructive: false,
+		},
+		{
+			oldCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "64"}, false)},
+			newCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "32"}, false)},
+			destructive: true,
+		},
+		{
+			oldCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "64"}, false)},
+			newCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "TEXT", map[string]string{}, false)},
+			destructive: false,
+		},
+		{
+			oldCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "TEXT", map[string]string{}, false)},
+			newCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "64"}, false)},
+			destructive: true,
+		},
+		{
+			// not null does not truncate data
+			oldCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "64", "nullable": "true"}, false)},
+			newCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "64", "nullable": "false"}, false)},
+			destructive: false,
+		},
+		{
+			oldCol:      &STextColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "VARCHAR", map[string]string{"width": "64"}, false)},
+			newCol:      &SIntegerColumn{SBaseWidthColumn: sqlchemy.NewBaseWidthColumn("name", "INT", map[string]string{}, false)},
+			destructive: true,
+		},
+	}
+	intCol := func(sqlType string, unsigned bool) sqlchemy.IColumnSpec {
+		col := NewIntegerColumn("num", sqlType, unsigned, map[string]string{}, false)
+		return &col
+	}
+	decimalCol := func(width, prec string) sqlchemy.IColumnSpec {
+		col := NewDecimalColumn("num", map[string]string{"width": width, "precision": prec}, false)
+		return &col
+	}
+	floatCol := func(sqlType string) sqlchemy.IColumnSpec {
+		col := NewFloatColumn("num", sqlType, map[string]string{}, false)
+		return &col
+	}
+	textCol := func(sqlType string, tags map[string]string) sqlchemy.IColumnSpec {
+		col := NewTextColumn("name", sqlType, tags, false)
+		return &col
+	}
+	timeCol := func(sqlType string) sqlchemy.IColumnSpec {
+		col := NewTimeTypeColumn("at", sqlType, map[string]string{}, false)
+		return &col
+	}
+	cases = append(cases, []struct {
+		oldCol      sqlchemy.IColumnSpec
+		newCol      sqlchemy.IColumnSpec
+		destructive bool
+	}{
+		{oldCol: intCol("INT", false), newCol: intCol("BIGINT", false), destructive: false},
+		{oldCol: intCol("INT", true), newCol: intCol("BIGINT", false), destructive: false},
+		{oldCol: intCol("BIGINT", false), newCol: intCol("INT", false), destructive: true},
+		{oldCol: intCol("INT", false), newCol: intCol("INT", true), destructive: true},
+		{oldCol: floatCol("FLOAT"), newCol: floatCol("DOUBLE"), destructive: false},
+		{oldCol: floatCol("DOUBLE"), newCol: intCol("INT", false), destructive: true},
+		{oldCol: decimalCol("10", "2"), newCol: decimalCol("12", "2"), destructive: false},
+		{oldCol: decimalCol("10", "2"), newCol: decimalCol("5", "2"), destructive: true},
+		{oldCol: timeCol("DATETIME"), newCol: timeCol("DATE"), destructive: true},
+		{oldCol: textCol("MEDIUMTEXT", map[string]string{}), newCol: textCol("TEXT", map[string]string{}), destructive: true},
+		{oldCol: textCol("TEXT", map[string]string{}), newCol: textCol("MEDIUMTEXT", map[string]string{}), destructive: false},
+		{oldCol: textCol("VARCHAR", map[string]string{"width": "64", "charset": "utf8"}), newCol: textCol("VARCHAR", map[string]string{"width": "64", "charset": "ascii"}), destructive: true},
+		{oldCol: textCol("VARCHAR", map[string]string{"width": "64", "charset": "ascii"}), newCol: textCol("VARCHAR", map[string]string{"width": "64", "charset": "utf8"}), destructive: false},
+		{oldCol: intCol("INT", false), newCol: textCol("VARCHAR", map[string]string{"width": "64"}), destructive: false},
+		{oldCol: intCol("BIGINT", false), newCol: textCol("VARCHAR", map[string]string{"width": "8"}), destructive: true},
+		{oldCol: decimalCol("10", "2"), newCol: textCol("TEXT", map[string]string{}), destructive: false},
+		{oldCol: floatCol("DOUBLE"), newCol: textCol("VARCHAR", map[string]string{"width": "32"}), destructive: false},
+		{oldCol: intCol("INT", false), newCol: decimalCol("12", "2"), destructive: false},
+		{oldCol: intCol("BIGINT", false), newCol: decimalCol("12", "2"), destructive: true},
+		{oldCol: intCol("INT", false), newCol: floatCol("DOUBLE"), destructive: false},
+		{oldCol: intCol("BIGINT", false), newCol: floatCol("DOUBLE"), destructive: true},
+		{oldCol: timeCol("DATE"), newCol: timeCol("DATETIME"), destructive: false},
+		{oldCol: timeCol("DATE"), newCol: timeCol("TIMESTAMP"), destructive: true},
+		{oldCol: timeCol("DATETIME"), newCol: textCol("VARCHAR", map[string]string{"width": "32"}), destructive: false},
+		// conversions not known to be lossless are reported
+		{oldCol: timeCol("DATETIME"), newCol: intCol("BIGINT", false), destructive: true},
+	}...)
+	for i, c := range cases {
+		got, reason := sqlchemy.IsNarrowingChange(c.oldCol, c.newCol)
+		if got != c.destructive {
+			t.Errorf("case %d: want %v got %v (%s)", i, c.destructive, got, reason)
+		}
+	}
+}
+
+func TestSyncForeignKeys(t *testing.T) {
+	type MemberStruct struct {
+		Id      int `primary:"true"`
+		GroupId int `nullable:"true" foreign_key:"groups_tbl(id)" on_delete:"cascade"`
+		OwnerId int `nullable:"true"`
+	}
+
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.MySQLBackend)
+	ts := sqlchemy.NewTableSpecFromStruct(MemberStruct{}, "members_tbl")
+	ts.AddForeignKey([]string{"owner_id"}, "users_tbl", []string{"id"}, "", "")
+
+	backend := &SMySQLBackend{}
+	sqls := backend.GetCreateSQLs(ts)
+	want := "CREATE TABLE IF NOT EXISTS `members_tbl` (\n" +
+		"`id` INT(11) NOT NULL,\n" +
+		"`group_id` INT(11),\n" +
+		"`owner_id` INT(11),\n" +
+		"PRIMARY KEY (`id`),\n" +
+		"CONSTRAINT `fk_members_tbl_owner_id` FOREIGN KEY (`owner_id`) REFERENCES `users_tbl` (`id`),\n" +
+		"CONSTRAINT `fk_members_tbl_group_id` FOREIGN KEY (`group_id`) REFERENCES `groups_tbl` (`id`) ON DELETE CASCADE\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_unicode_ci"
+	if len(sqls) != 1 || sqls[0] != want {
+		t.Errorf("Expect: %s", want)
+		t.Errorf("Got: %s", sqls)
+	}
+
+	fks := ts.ForeignKeys()
+	exists := parseConstraints("CONSTRAINT `members_tbl_ibfk_1` FOREIGN KEY (`owner_id`) REFERENCES `users_tbl` (`id`) ON DELETE RESTRICT,\n" +
+		"CONSTRAINT `fk_members_tbl_group_id` FOREIGN KEY (`group_id`) REFERENCES `groups_tbl` (`id`)")
+	if !fks[0].IsIdentical(exists[0]) {
+		t.Errorf("%s should be identical to %s", fks[0].DefinitionString("`"), exists[0].DefinitionString("`"))
+	}
+	if fks[1].IsIdentical(exists[1]) {
+		t.Errorf("%s should differ from %s", fks[1].DefinitionString("`"), exists[1].DefinitionString("`"))
+	}
+	changes := sqlchemy.STableChanges{
+		RemoveConstraints: exists[1:],
+		AddConstraints:    fks[1:],
+	}
+	sqls = backend.CommitTableChangeSQL(ts, changes)
+	wantSqls := []string{
+		"ALTER TABLE `members_tbl` DROP FOREIGN KEY `fk_members_tbl_group_id`",
+		"ALTER TABLE `members_tbl` ADD CONSTRAINT `fk_members_tbl_group_id` FOREIGN KEY (`group_id`) REFERENCES `groups_tbl` (`id`) ON DELETE CASCADE",
+	}
+	if !reflect.DeepEqual(sqls, wantSqls) {
+		t.Errorf("Expect: %s", wantSqls)
+		t.Errorf("Got: %s", sqls)
+	}
+}
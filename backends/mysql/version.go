@@ -0,0 +1,101 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/sqlchemy"
+)
+
+// sServerVersion is the version of a MySQL or MariaDB server
+type sServerVersion struct {
+	major   int
+	minor   int
+	patch   int
+	mariadb bool
+}
+
+// latestServerVersion is assumed when the database is not connected, e.g. generating SQLs
+var latestServerVersion = sServerVersion{major: math.MaxInt32}
+
+// serverVersions caches the versions of the servers by *sql.DB
+var serverVersions sync.Map
+
+func (v sServerVersion) atLeast(major, minor, patch int) bool {
+	if v.major != major {
+		return v.major > major
+	}
+	if v.minor != minor {
+		return v.minor > minor
+	}
+	return v.patch >= patch
+}
+
+// isSupportDescIndex returns whether the server stores descending indexes instead of ignoring DESC
+func (v sServerVersion) isSupportDescIndex() bool {
+	if v.mariadb {
+		return v.atLeast(10, 8, 0)
+	}
+	return v.atLeast(8, 0, 0)
+}
+
+// isSupportExpressionIndex returns whether the server supports functional key parts
+func (v sServerVersion) isSupportExpressionIndex() bool {
+	return !v.mariadb && v.atLeast(8, 0, 13)
+}
+
+// parseServerVersion parses the result of SELECT VERSION(), e.g. 8.0.13-log or 5.5.5-10.6.12-MariaDB
+func parseServerVersion(str string) sServerVersion {
+	v := sServerVersion{}
+	if strings.Contains(strings.ToLower(str), "mariadb") {
+		v.mariadb = true
+		// the replication protocol of MariaDB prefixes the version with 5.5.5-
+		str = strings.TrimPrefix(str, "5.5.5-")
+	}
+	if pos := strings.IndexAny(str, "-+~"); pos >= 0 {
+		str = str[:pos]
+	}
+	nums := []*int{&v.major, &v.minor, &v.patch}
+	for i, part := range strings.SplitN(str, ".", len(nums)) {
+		*nums[i], _ = strconv.Atoi(part)
+	}
+	return v
+}
+
+// fetchServerVersion returns the version of the server of db, which is queried once for each connection pool
+func fetchServerVersion(db *sqlchemy.SDatabase) sServerVersion {
+	if db == nil || db.DB() == nil {
+		return latestServerVersion
+	}
+	if v, ok := serverVersions.Load(db.DB()); ok {
+		return v.(sServerVersion)
+	}
+	var str string
+	err := db.DB().QueryRow("SELECT VERSION()").Scan(&str)
+	if err != nil {
+		// assume the oldest server, so that the features depending on the version are not used
+		log.Errorf("query server version fail %s", err)
+		return sServerVersion{}
+	}
+	v := parseServerVersion(str)
+	serverVersions.Store(db.DB(), v)
+	return v
+}
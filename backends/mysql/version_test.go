@@ -0,0 +1,46 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"testing"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	cases := []struct {
+		version string
+		want    sServerVersion
+		desc    bool
+		expr    bool
+	}{
+		{"5.7.44-log", sServerVersion{major: 5, minor: 7, patch: 44}, false, false},
+		{"8.0.12", sServerVersion{major: 8, minor: 0, patch: 12}, true, false},
+		{"8.0.35-0ubuntu0.22.04.1", sServerVersion{major: 8, minor: 0, patch: 35}, true, true},
+		{"5.5.5-10.6.12-MariaDB-1:10.6.12+maria~ubu2004", sServerVersion{major: 10, minor: 6, patch: 12, mariadb: true}, false, false},
+		{"10.11.2-MariaDB", sServerVersion{major: 10, minor: 11, patch: 2, mariadb: true}, true, false},
+	}
+	for _, c := range cases {
+		got := parseServerVersion(c.version)
+		if got != c.want {
+			t.Errorf("%s: want %#v got %#v", c.version, c.want, got)
+		}
+		if desc := got.isSupportDescIndex(); desc != c.desc {
+			t.Errorf("%s: desc want %v got %v", c.version, c.desc, desc)
+		}
+		if expr := got.isSupportExpressionIndex(); expr != c.expr {
+			t.Errorf("%s: expression want %v got %v", c.version, c.expr, expr)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/sqlchemy"
+)
+
+// column_name, data_type, is_nullable, character_maximum_length, numeric_precision, numeric_scale, datetime_precision, column_default, is_identity, identity_start
+type sSqlColumnInfo struct {
+	TableName              string `json:"table_name"`
+	ColumnName             string `json:"column_name"`
+	DataType               string `json:"data_type"`
+	IsNullable             string `json:"is_nullable"`
+	CharacterMaximumLength int    `json:"character_maximum_length"`
+	NumericPrecision       int    `json:"numeric_precision"`
+	NumericScale           int    `json:"numeric_scale"`
+	DatetimePrecision      int    `json:"datetime_precision"`
+	ColumnDefault          string `json:"column_default"`
+	IsIdentity             string `json:"is_identity"`
+	IdentityStart          string `json:"identity_start"`
+	IsPrimary              bool   `json:"is_primary"`
+}
+
+func fetchTableColInfo(ts sqlchemy.ITableSpec) ([]sSqlColumnInfo, error) {
+	sqlStr := fmt.Sprintf("SELECT column_name, data_type, is_nullable, character_maximum_length, numeric_precision, numeric_scale, datetime_precision, column_default, is_identity, identity_start FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = '%s' ORDER BY ordinal_position", ts.Name())
+	query := ts.Database().NewRawQuery(sqlStr, "column_name", "data_type", "is_nullable", "character_maximum_length", "numeric_precision", "numeric_scale", "datetime_precision", "column_default", "is_identity", "identity_start")
+	infos := make([]sSqlColumnInfo, 0)
+	err := query.All(&infos)
+	if err != nil {
+		return nil, errors.Wrap(err, "query")
+	}
+
+	indexes, err := fetchTableIndexes(ts)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetchTableIndexes")
+	}
+	primaries := make(map[string]bool)
+	for _, idx := range indexes {
+		if idx.isPrimary {
+			for _, col := range idx.colnames {
+				primaries[col] = true
+			}
+			break
+		}
+	}
+
+	for i := range infos {
+		infos[i].TableName = ts.Name()
+		infos[i].IsPrimary = primaries[infos[i].ColumnName]
+	}
+	return infos, nil
+}
+
+type sPostgresTableIndex struct {
+	isPrimary bool
+	isUnique  bool
+	indexName string
+	colnames  []string
+}
+
+func fetchTableIndexes(ts sqlchemy.ITableSpec) ([]sPostgresTableIndex, error) {
+	type sIndexInfo struct {
+		IndexName  string `json:"index_name"`
+		ColumnName string `json:"column_name"`
+		IsPrimary  string `json:"is_primary"`
+		IsUnique   string `json:"is_unique"`
+	}
+	// information_schema knows nothing about indexes other than constraints, so use pg_catalog instead
+	sqlStr := fmt.Sprintf("SELECT i.relname AS index_name, a.attname AS column_name, CASE WHEN ix.indisprimary THEN 'YES' ELSE 'NO' END AS is_primary, CASE WHEN ix.indisunique THEN 'YES' ELSE 'NO' END AS is_unique FROM pg_index ix JOIN pg_class t ON t.oid = ix.indrelid JOIN pg_class i ON i.oid = ix.indexrelid JOIN pg_namespace n ON n.oid = t.relnamespace JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey) WHERE n.nspname = current_schema() AND t.relname = '%s' ORDER BY i.relname", ts.Name())
+	query := ts.Database().NewRawQuery(sqlStr, "index_name", "column_name", "is_primary", "is_unique")
+	infos := make([]sIndexInfo, 0)
+	err := query.All(&infos)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]sPostgresTableIndex, 0)
+	for _, info := range infos {
+		if len(ret) > 0 && ret[len(ret)-1].indexName == info.IndexName {
+			ret[len(ret)-1].colnames = append(ret[len(ret)-1].colnames, info.ColumnName)
+		} else {
+			ret = append(ret, sPostgresTableIndex{
+				isPrimary: info.IsPrimary == "YES",
+				isUnique:  info.IsUnique == "YES",
+				indexName: info.IndexName,
+				colnames:  []string{info.ColumnName},
+			})
+		}
+	}
+	return ret, nil
+}
+
+// fetchTableIndexDefs returns the indexes other than the primary key, parsed from their definitions
+func fetchTableIndexDefs(ts sqlchemy.ITableSpec) ([]sqlchemy.STableIndex, error) {
+	type sIndexDef struct {
+		IndexName string `json:"index_name"`
+		IndexDef  string `json:"index_def"`
+	}
+	sqlStr := fmt.Sprintf("SELECT i.relname AS index_name, pg_get_indexdef(ix.indexrelid) AS index_def FROM pg_index ix JOIN pg_class t ON t.oid = ix.indrelid JOIN pg_class i ON i.oid = ix.indexrelid JOIN pg_namespace n ON n.oid = t.relnamespace WHERE n.nspname = current_schema() AND t.relname = '%s' AND NOT ix.indisprimary ORDER BY i.relname", ts.Name())
+	query := ts.Database().NewRawQuery(sqlStr, "index_name", "index_def")
+	infos := make([]sIndexDef, 0)
+	err := query.All(&infos)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]sqlchemy.STableIndex, 0, len(infos))
+	for _, info := range infos {
+		idx, err := sqlchemy.ParseCreateIndexSQL(ts, info.IndexDef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse index %s", info.IndexName)
+		}
+		ret = append(ret, idx)
+	}
+	return ret, nil
+}
+
+// foreignKeyAction converts the action code of pg_constraint to the referential action
+func foreignKeyAction(code string) string {
+	switch code {
+	case "c":
+		return sqlchemy.FK_ACTION_CASCADE
+	case "n":
+		return sqlchemy.FK_ACTION_SET_NULL
+	case "d":
+		return "SET DEFAULT"
+	}
+	return ""
+}
+
+func fetchTableForeignKeys(ts sqlchemy.ITableSpec) ([]sqlchemy.STableConstraint, error) {
+	type sForeignKeyInfo struct {
+		ConstraintName string `json:"constraint_name"`
+		ColumnName     string `json:"column_name"`
+		ForeignTable   string `json:"foreign_table"`
+		ForeignColumn  string `json:"foreign_column"`
+		OnDelete       string `json:"on_delete"`
+		OnUpdate       string `json:"on_update"`
+	}
+	sqlStr := fmt.Sprintf("SELECT c.conname AS constraint_name, a.attname AS column_name, f.relname AS foreign_table, fa.attname AS foreign_column, c.confdeltype AS on_delete, c.confupdtype AS on_update FROM pg_constraint c JOIN pg_class t ON t.oid = c.conrelid JOIN pg_namespace n ON n.oid = t.relnamespace JOIN pg_class f ON f.oid = c.confrelid CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, fattnum, seq) JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum JOIN pg_attribute fa ON fa.attrelid = c.confrelid AND fa.attnum = k.fattnum WHERE c.contype = 'f' AND n.nspname = current_schema() AND t.relname = '%s' ORDER BY c.conname, k.seq", ts.Name())
+	query := ts.Database().NewRawQuery(sqlStr, "constraint_name", "column_name", "foreign_table", "foreign_column", "on_delete", "on_update")
+	infos := make([]sForeignKeyInfo, 0)
+	err := query.All(&infos)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]sqlchemy.STableConstraint, 0)
+	for i := 0; i < len(infos); {
+		cols := make([]string, 0)
+		fcols := make([]string, 0)
+		j := i
+		for ; j < len(infos) && infos[j].ConstraintName == infos[i].ConstraintName; j++ {
+			cols = append(cols, infos[j].ColumnName)
+			fcols = append(fcols, infos[j].ForeignColumn)
+		}
+		ret = append(ret, sqlchemy.NewTableForeignKey(nil, infos[i].ConstraintName, cols, infos[i].ForeignTable, fcols, foreignKeyAction(infos[i].OnDelete), foreignKeyAction(infos[i].OnUpdate)))
+		i = j
+	}
+	return ret, nil
+}
+
+var defaultCastRegexp = regexp.MustCompile(`^(.*)::[a-z ]+(\[\])?$`)
+
+// parseDefault strips type casts and quotes of a column_default, e.g. 'male'::character varying
+func parseDefault(def string) string {
+	def = strings.TrimSpace(def)
+	if m := defaultCastRegexp.FindStringSubmatch(def); m != nil {
+		def = m[1]
+	}
+	def = strings.Trim(def, "()")
+	if strings.ToUpper(def) == "NULL" {
+		return ""
+	}
+	if len(def) >= 2 && def[0] == '\'' && def[len(def)-1] == '\'' {
+		def = strings.ReplaceAll(def[1:len(def)-1], "''", "'")
+	}
+	return def
+}
+
+func (info *sSqlColumnInfo) toColumnSpec() sqlchemy.IColumnSpec {
+	tagmap := make(map[string]string)
+
+	typeStr := strings.ToLower(info.DataType)
+	if info.IsNullable == "YES" {
+		tagmap[sqlchemy.TAG_NULLABLE] = "true"
+	} else {
+		tagmap[sqlchemy.TAG_NULLABLE] = "false"
+	}
+	if info.IsPrimary {
+		tagmap[sqlchemy.TAG_PRIMARY] = "true"
+	} else {
+		tagmap[sqlchemy.TAG_PRIMARY] = "false"
+	}
+	if info.IsIdentity == "YES" {
+		if len(info.IdentityStart) > 0 && info.IdentityStart != "1" {
+			tagmap[sqlchemy.TAG_AUTOINCREMENT] = info.IdentityStart
+		} else {
+			tagmap[sqlchemy.TAG_AUTOINCREMENT] = "true"
+		}
+	} else if strings.HasPrefix(info.ColumnDefault, "nextval(") {
+		// SERIAL column
+		tagmap[sqlchemy.TAG_AUTOINCREMENT] = "true"
+	} else if def := parseDefault(info.ColumnDefault); len(def) > 0 {
+		tagmap[sqlchemy.TAG_DEFAULT] = def
+	}
+	switch typeStr {
+	case "character varying", "character":
+		sqlType := "VARCHAR"
+		if typeStr == "character" {
+			sqlType = "CHAR"
+		}
+		tagmap[sqlchemy.TAG_WIDTH] = fmt.Sprintf("%d", info.CharacterMaximumLength)
+		c := NewTextColumn(info.ColumnName, sqlType, tagmap, false)
+		return &c
+	case "text":
+		c := NewTextColumn(info.ColumnName, "TEXT", tagmap, false)
+		return &c
+	case "smallint", "integer", "bigint":
+		c := NewIntegerColumn(info.ColumnName, strings.ToUpper(typeStr), tagmap, false)
+		return &c
+	case "real", "double precision":
+		c := NewFloatColumn(info.ColumnName, strings.ToUpper(typeStr), tagmap, false)
+		return &c
+	case "numeric":
+		tagmap[sqlchemy.TAG_WIDTH] = fmt.Sprintf("%d", info.NumericPrecision)
+		tagmap[sqlchemy.TAG_PRECISION] = fmt.Sprintf("%d", info.NumericScale)
+		c := NewDecimalColumn(info.ColumnName, tagmap, false)
+		return &c
+	case "timestamp without time zone", "timestamp with time zone":
+		tagmap[sqlchemy.TAG_PRECISION] = fmt.Sprintf("%d", info.DatetimePrecision)
+		c := NewDateTimeColumn(info.ColumnName, tagmap, false)
+		return &c
+	case "time without time zone", "time with time zone":
+		tagmap[sqlchemy.TAG_PRECISION] = fmt.Sprintf("%d", info.DatetimePrecision)
+		c := NewTimeTypeColumn(info.ColumnName, "TIME", tagmap, false)
+		return &c
+	case "date":
+		c := NewTimeTypeColumn(info.ColumnName, "DATE", tagmap, false)
+		return &c
+	default:
+		log.Errorf("unsupported column data type %s", typeStr)
+		return nil
+	}
+}
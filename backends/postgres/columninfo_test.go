@@ -0,0 +1,133 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"testing"
+)
+
+func TestParseDefault(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   "12",
+			want: "12",
+		},
+		{
+			in:   "'male'::character varying",
+			want: "male",
+		},
+		{
+			in:   "'it''s'::text",
+			want: "it's",
+		},
+		{
+			in:   "'-1'::integer",
+			want: "-1",
+		},
+		{
+			in:   "NULL::character varying",
+			want: "",
+		},
+		{
+			in:   "",
+			want: "",
+		},
+	}
+	for _, c := range cases {
+		got := parseDefault(c.in)
+		if got != c.want {
+			t.Errorf("parseDefault(%q) want %q got %q", c.in, c.want, got)
+		}
+	}
+}
+
+func TestToColumnSpec(t *testing.T) {
+	cases := []struct {
+		info sSqlColumnInfo
+		want string
+	}{
+		{
+			info: sSqlColumnInfo{
+				ColumnName:        "created_at",
+				DataType:          "timestamp without time zone",
+				IsNullable:        "NO",
+				DatetimePrecision: 0,
+			},
+			want: `"created_at" TIMESTAMP(0) NOT NULL`,
+		},
+		{
+			info: sSqlColumnInfo{
+				ColumnName:    "id",
+				DataType:      "bigint",
+				IsNullable:    "NO",
+				IsPrimary:     true,
+				IsIdentity:    "YES",
+				IdentityStart: "1",
+			},
+			want: `"id" BIGINT GENERATED BY DEFAULT AS IDENTITY (START WITH 1) NOT NULL`,
+		},
+		{
+			info: sSqlColumnInfo{
+				ColumnName:    "update_version",
+				DataType:      "integer",
+				IsNullable:    "NO",
+				ColumnDefault: "0",
+			},
+			want: `"update_version" INTEGER DEFAULT 0 NOT NULL`,
+		},
+		{
+			info: sSqlColumnInfo{
+				ColumnName:             "name",
+				DataType:               "character varying",
+				IsNullable:             "YES",
+				CharacterMaximumLength: 64,
+				ColumnDefault:          "'male'::character varying",
+			},
+			want: `"name" VARCHAR(64) DEFAULT 'male'`,
+		},
+		{
+			info: sSqlColumnInfo{
+				ColumnName:       "amount",
+				DataType:         "numeric",
+				IsNullable:       "YES",
+				NumericPrecision: 10,
+				NumericScale:     2,
+			},
+			want: `"amount" NUMERIC(10, 2)`,
+		},
+		{
+			info: sSqlColumnInfo{
+				ColumnName: "ratio",
+				DataType:   "double precision",
+				IsNullable: "YES",
+			},
+			want: `"ratio" DOUBLE PRECISION`,
+		},
+	}
+	for _, c := range cases {
+		col := c.info.toColumnSpec()
+		if col == nil {
+			t.Errorf("%s: nil column spec", c.info.ColumnName)
+			continue
+		}
+		got := col.DefinitionString()
+		if got != c.want {
+			t.Errorf("want %s got %s", c.want, got)
+		}
+	}
+}
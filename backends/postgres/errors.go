@@ -0,0 +1,48 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"github.com/lib/pq"
+
+	"yunion.io/x/sqlchemy"
+)
+
+var postgresErrorClasses = map[pq.ErrorCode]error{
+	"23505": sqlchemy.ErrDuplicateEntry,      // unique_violation
+	"23503": sqlchemy.ErrForeignKeyViolation, // foreign_key_violation
+	"40001": sqlchemy.ErrDeadlock,            // serialization_failure
+	"40P01": sqlchemy.ErrDeadlock,            // deadlock_detected
+	"55P03": sqlchemy.ErrLockTimeout,         // lock_not_available
+	"22001": sqlchemy.ErrDataTooLong,         // string_data_right_truncation
+	"42P01": sqlchemy.ErrTableNotExists,      // undefined_table
+	"57P01": sqlchemy.ErrConnectionLost,      // admin_shutdown
+}
+
+// ClassifyError translates a PostgreSQL driver error into an Error constant of sqlchemy
+func (postgres *SPostgreSQLBackend) ClassifyError(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if !ok {
+		return postgres.SBaseBackend.ClassifyError(err)
+	}
+	if class, ok := postgresErrorClasses[pqErr.Code]; ok {
+		return class
+	}
+	if pqErr.Code.Class() == "08" {
+		// connection_exception
+		return sqlchemy.ErrConnectionLost
+	}
+	return nil
+}
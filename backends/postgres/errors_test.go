@@ -0,0 +1,47 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestClassifyError(t *testing.T) {
+	backend := &SPostgreSQLBackend{}
+	cases := []struct {
+		err  error
+		want error
+	}{
+		{&pq.Error{Code: "23505"}, sqlchemy.ErrDuplicateEntry},
+		{&pq.Error{Code: "23503"}, sqlchemy.ErrForeignKeyViolation},
+		{&pq.Error{Code: "40001"}, sqlchemy.ErrDeadlock},
+		{&pq.Error{Code: "40P01"}, sqlchemy.ErrDeadlock},
+		{&pq.Error{Code: "55P03"}, sqlchemy.ErrLockTimeout},
+		{&pq.Error{Code: "22001"}, sqlchemy.ErrDataTooLong},
+		{&pq.Error{Code: "42P01"}, sqlchemy.ErrTableNotExists},
+		{&pq.Error{Code: "08006"}, sqlchemy.ErrConnectionLost},
+		{&pq.Error{Code: "42601"}, nil},
+	}
+	for _, c := range cases {
+		got := backend.ClassifyError(c.err)
+		if got != c.want {
+			t.Errorf("%s: want %v got %v", c.err.(*pq.Error).Code, c.want, got)
+		}
+	}
+}
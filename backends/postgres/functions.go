@@ -0,0 +1,64 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"yunion.io/x/sqlchemy"
+)
+
+// GROUP_CONCAT2 represents the SQL function GROUP_CONCAT
+func (postgres *SPostgreSQLBackend) GROUP_CONCAT2(name string, sep string, field sqlchemy.IQueryField) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(name, true, fmt.Sprintf("STRING_AGG(CAST(%%s AS TEXT), '%s')", sep), field)
+}
+
+// cast field to string
+func (postgres *SPostgreSQLBackend) CASTString(field sqlchemy.IQueryField, fieldname string) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(fieldname, false, `CAST(%s AS TEXT)`, field)
+}
+
+// cast field to integer
+func (postgres *SPostgreSQLBackend) CASTInt(field sqlchemy.IQueryField, fieldname string) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(fieldname, false, `CAST(%s AS INTEGER)`, field)
+}
+
+// cast field to float
+func (postgres *SPostgreSQLBackend) CASTFloat(field sqlchemy.IQueryField, fieldname string) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(fieldname, false, `CAST(%s AS DOUBLE PRECISION)`, field)
+}
+
+// TIMESTAMPADD represents a SQL function TimestampAdd
+func (postgres *SPostgreSQLBackend) TIMESTAMPADD(name string, field sqlchemy.IQueryField, offsetSeconds int) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(name, false, `(%s + INTERVAL '`+fmt.Sprintf("%d", offsetSeconds)+` seconds')`, field)
+}
+
+// REPLACE represents a SQL function REPLACE
+func (postgres *SPostgreSQLBackend) REPLACE(name string, field sqlchemy.IQueryField, old string, new string) sqlchemy.IQueryField {
+	old = strings.ReplaceAll(strings.ReplaceAll(old, "%", "%%"), "'", "''")
+	new = strings.ReplaceAll(strings.ReplaceAll(new, "%", "%%"), "'", "''")
+	return sqlchemy.NewFunctionField(name, false, `REPLACE(%s, '`+old+`', '`+new+`')`, field)
+}
+
+// INET_ATON represents the SQL function INET_ATON
+func (postgres *SPostgreSQLBackend) INET_ATON(field sqlchemy.IQueryField) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField("", false, `(CAST(%s AS INET) - '0.0.0.0'::INET)`, field)
+}
+
+// INET6_ATON represents the SQL function INET6_ATON
+func (postgres *SPostgreSQLBackend) INET6_ATON(field sqlchemy.IQueryField) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField("", false, `CAST(%s AS INET)`, field)
+}
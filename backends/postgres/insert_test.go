@@ -0,0 +1,131 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"testing"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+func insertSqlPrep(v interface{}, update bool) (string, []interface{}, error) {
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.PostgreSQLBackend)
+	ts := sqlchemy.NewTableSpecFromStruct(v, "vv")
+	results, err := ts.InsertSqlPrep(v, update)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "InsertSqlPrep")
+	}
+	return results.Sql, results.Values, err
+}
+
+func TestInsertAutoIncrement(t *testing.T) {
+	cases := []struct {
+		value   interface{}
+		update  bool
+		wantSQL string
+		wantVar int
+	}{
+		{
+			value: &struct {
+				RowId int `auto_increment:"true"`
+			}{},
+			update:  false,
+			wantSQL: `INSERT INTO "vv" DEFAULT VALUES`,
+			wantVar: 0,
+		},
+		{
+			value: &struct {
+				RowId int    `auto_increment:"true"`
+				Name  string `width:"24"`
+			}{
+				Name: "a",
+			},
+			update:  false,
+			wantSQL: `INSERT INTO "vv" ("name") VALUES (?)`,
+			wantVar: 1,
+		},
+		{
+			value: &struct {
+				RowId int    `primary:"true"`
+				Name  string `width:"24"`
+			}{
+				RowId: 1,
+				Name:  "a",
+			},
+			update:  true,
+			wantSQL: `INSERT INTO "vv" ("row_id", "name") VALUES (?, ?) ON CONFLICT ("row_id") DO UPDATE SET "name" = ?`,
+			wantVar: 3,
+		},
+		{
+			value: &struct {
+				RowId   int    `primary:"true"`
+				Name    string `width:"24"`
+				Version int    `auto_version:"true"`
+			}{
+				RowId: 1,
+				Name:  "a",
+			},
+			update:  true,
+			wantSQL: `INSERT INTO "vv" ("row_id", "name") VALUES (?, ?) ON CONFLICT ("row_id") DO UPDATE SET "name" = ?, "version" = "vv"."version" + 1`,
+			wantVar: 3,
+		},
+	}
+	for i, c := range cases {
+		sql, vals, err := insertSqlPrep(c.value, c.update)
+		if err != nil {
+			t.Errorf("case [%d] prepare sql failed: %s", i, err)
+		} else {
+			if sql != c.wantSQL {
+				t.Errorf("sql want %s got %s", c.wantSQL, sql)
+			} else {
+				if len(vals) != c.wantVar {
+					t.Errorf("vars want %d got %d", c.wantVar, len(vals))
+				}
+			}
+		}
+	}
+}
+
+func TestInsertMultiAutoIncrement(t *testing.T) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			t.Errorf("should panic with multiple auto_increment fields")
+		}
+	}()
+	_, _, err := insertSqlPrep(&struct {
+		RowId  int `auto_increment:"true"`
+		RowId2 int `auto_increment:"true"`
+	}{}, false)
+	t.Errorf("should panic but it continues: err: %s", err)
+}
+
+func TestPrepareInsertOrUpdateSQL(t *testing.T) {
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.PostgreSQLBackend)
+	ts := sqlchemy.NewTableSpecFromStruct(&struct {
+		Id   int    `primary:"true"`
+		Guid string `width:"36"`
+	}{}, "vv")
+	setCols := []string{`"id" = "id" + 1`, `"guid" = ?`, `"id" = '"id"'`}
+	sql, _ := (&SPostgreSQLBackend{}).PrepareInsertOrUpdateSQL(ts, []string{`"id"`, `"guid"`}, []string{"?", "?"}, []string{`"id"`}, setCols, []interface{}{1, "a"}, []interface{}{"b"})
+	want := `INSERT INTO "vv" ("id", "guid") VALUES (?, ?) ON CONFLICT ("id") DO UPDATE SET "id" = "vv"."id" + 1, "guid" = ?, "id" = '"id"'`
+	if sql != want {
+		t.Errorf("want: %s got: %s", want, sql)
+	}
+	if setCols[0] != `"id" = "id" + 1` {
+		t.Errorf("updateSetCols modified: %s", setCols[0])
+	}
+}
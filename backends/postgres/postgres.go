@@ -0,0 +1,374 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/util/regutils"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func init() {
+	sqlchemy.RegisterBackend(&SPostgreSQLBackend{})
+}
+
+type SPostgreSQLBackend struct {
+	sqlchemy.SBaseBackend
+}
+
+func (postgres *SPostgreSQLBackend) Name() sqlchemy.DBBackendName {
+	return sqlchemy.PostgreSQLBackend
+}
+
+// CanUpdate returns wether the backend supports update
+func (postgres *SPostgreSQLBackend) CanUpdate() bool {
+	return true
+}
+
+// CanInsert returns wether the backend supports Insert
+func (postgres *SPostgreSQLBackend) CanInsert() bool {
+	return true
+}
+
+func (postgres *SPostgreSQLBackend) CanSupportRowAffected() bool {
+	return true
+}
+
+// CanSupportLastInsertId returns false, lib/pq does not support LastInsertId, use INSERT ... RETURNING instead
+func (postgres *SPostgreSQLBackend) CanSupportLastInsertId() bool {
+	return false
+}
+
+// CanInsertOrUpdate returns weather the backend supports InsertOrUpdate
+func (postgres *SPostgreSQLBackend) CanInsertOrUpdate() bool {
+	return true
+}
+
+// CanSupportSavepoint returns wether the backend supports SAVEPOINT for nested transactions
+func (postgres *SPostgreSQLBackend) CanSupportSavepoint() bool {
+	return true
+}
+
+// CanSupportTransactionalDDL returns wether DDL statements can be rollbacked in a transaction
+func (postgres *SPostgreSQLBackend) CanSupportTransactionalDDL() bool {
+	return true
+}
+
+// CanSupportCTE returns wether the backend supports common table expressions
+func (postgres *SPostgreSQLBackend) CanSupportCTE() bool {
+	return true
+}
+
+// ReplacePlaceholders converts ? placeholders into $1, $2, ..., skipping quoted literals and identifiers
+func (postgres *SPostgreSQLBackend) ReplacePlaceholders(sqlstr string) string {
+	var buf bytes.Buffer
+	var quote byte
+	idx := 0
+	for i := 0; i < len(sqlstr); i++ {
+		c := sqlstr[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			idx++
+			buf.WriteString("$")
+			buf.WriteString(strconv.Itoa(idx))
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+func (postgres *SPostgreSQLBackend) InsertOrUpdateSQLTemplate() string {
+	// use PrepareInsertOrUpdateSQL instead
+	return ""
+}
+
+func (postgres *SPostgreSQLBackend) InsertOrUpdateBatchSQLTemplate() string {
+	return `INSERT INTO "{{ .Table }}" ({{ .Columns }}) VALUES {{ .Values }} ON CONFLICT ({{ .PrimaryKeys }}) DO UPDATE SET {{ range $i, $c := .UpdateColumns }}{{ if $i }}, {{ end }}"{{ $c }}" = EXCLUDED."{{ $c }}"{{ end }}{{ range $i, $c := .VersionColumns }}{{ if or $i $.UpdateColumns }}, {{ end }}"{{ $c }}" = "{{ $.Table }}"."{{ $c }}" + 1{{ end }}`
+}
+
+// MaxPlaceholders returns the maximal number of placeholders in a statement
+func (postgres *SPostgreSQLBackend) MaxPlaceholders() int {
+	return 65535
+}
+
+func (postgres *SPostgreSQLBackend) LiteralStyle() sqlchemy.SLiteralStyle {
+	return sqlchemy.SLiteralStyle{BooleanKeyword: true, ByteaHex: true}
+}
+
+func (postgres *SPostgreSQLBackend) DropIndexSQLTemplate() string {
+	return `DROP INDEX IF EXISTS "{{ .Index }}"`
+}
+
+func (postgres *SPostgreSQLBackend) DropTableSQL(table string) string {
+	return fmt.Sprintf(`DROP TABLE "%s"`, table)
+}
+
+func (postgres *SPostgreSQLBackend) InsertSQLTemplate() string {
+	return `INSERT INTO "{{ .Table }}" {{ if .Columns }}({{ .Columns }}) VALUES ({{ .Values }}){{ else }}DEFAULT VALUES{{ end }}`
+}
+
+func (postgres *SPostgreSQLBackend) UpdateSQLTemplate() string {
+	return `UPDATE "{{ .Table }}" SET {{ .Columns }} WHERE {{ .Conditions }}`
+}
+
+func (postgres *SPostgreSQLBackend) DeleteSQLTemplate() string {
+	return `DELETE FROM "{{ .Table }}" WHERE {{ .Conditions }}`
+}
+
+func (postgres *SPostgreSQLBackend) PrepareInsertOrUpdateSQL(ts sqlchemy.ITableSpec, insertColNames []string, insertFields []string, onPrimaryCols []string, updateSetCols []string, insertValues []interface{}, updateValues []interface{}) (string, []interface{}) {
+	sqlTemp := `INSERT INTO "{{ .Table }}" ({{ .Columns }}) VALUES ({{ .Values }}) ON CONFLICT ({{ .PrimaryKeys }}) DO UPDATE SET {{ .SetValues }}`
+	setValues := make([]string, len(updateSetCols))
+	for i := range updateSetCols {
+		// column references in SET values are ambiguous with EXCLUDED, qualify them with table name
+		setCol := updateSetCols[i]
+		equalPos := strings.Index(setCol, "=")
+		key := strings.TrimSpace(setCol[0:equalPos])
+		val := strings.TrimSpace(setCol[equalPos+1:])
+		setValues[i] = fmt.Sprintf("%s = %s", key, qualifyIdentifier(val, key, ts.Name()))
+	}
+	values := make([]interface{}, 0, len(insertValues)+len(updateValues))
+	values = append(values, insertValues...)
+	values = append(values, updateValues...)
+	sql := sqlchemy.TemplateEval(sqlTemp, struct {
+		Table       string
+		Columns     string
+		Values      string
+		PrimaryKeys string
+		SetValues   string
+	}{
+		Table:       ts.Name(),
+		Columns:     strings.Join(insertColNames, ", "),
+		Values:      strings.Join(insertFields, ", "),
+		PrimaryKeys: strings.Join(onPrimaryCols, ", "),
+		SetValues:   strings.Join(setValues, ", "),
+	})
+	return sql, values
+}
+
+// qualifyIdentifier prefixes the unqualified occurrences of the quoted identifier ident in expr with the table,
+// only whole quoted identifiers outside string literals are matched
+func qualifyIdentifier(expr string, ident string, table string) string {
+	var buf strings.Builder
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		if c != '"' && c != '\'' {
+			buf.WriteByte(c)
+			continue
+		}
+		// a doubled quote is an escaped quote
+		end := i + 1
+		for end < len(expr) {
+			if expr[end] == c {
+				if end+1 < len(expr) && expr[end+1] == c {
+					end += 2
+					continue
+				}
+				end++
+				break
+			}
+			end++
+		}
+		token := expr[i:end]
+		if c == '"' && token == ident && (i == 0 || expr[i-1] != '.') {
+			buf.WriteString(fmt.Sprintf(`"%s".`, table))
+		}
+		buf.WriteString(token)
+		i = end - 1
+	}
+	return buf.String()
+}
+
+func (postgres *SPostgreSQLBackend) GetTableSQL() string {
+	return `SELECT table_name AS "name" FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'`
+}
+
+func (postgres *SPostgreSQLBackend) CurrentUTCTimeStampString() string {
+	return "(NOW() AT TIME ZONE 'UTC')"
+}
+
+func (postgres *SPostgreSQLBackend) CurrentTimeStampString() string {
+	return "LOCALTIMESTAMP"
+}
+
+func (postgres *SPostgreSQLBackend) CaseInsensitiveLikeString() string {
+	return "ILIKE"
+}
+
+func (postgres *SPostgreSQLBackend) GetCreateSQLs(ts sqlchemy.ITableSpec) []string {
+	cols := make([]string, 0)
+	primaries := make([]string, 0)
+	for _, c := range ts.Columns() {
+		cols = append(cols, c.DefinitionString())
+		if c.IsPrimary() {
+			primaries = append(primaries, fmt.Sprintf(`"%s"`, c.Name()))
+		}
+	}
+	if len(primaries) > 0 {
+		cols = append(cols, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaries, ", ")))
+	}
+	for _, fk := range ts.ForeignKeys() {
+		cols = append(cols, fk.DefinitionString(`"`))
+	}
+	sqls := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (%s)`, ts.Name(), strings.Join(cols, ", ")),
+	}
+	for _, idx := range ts.Indexes() {
+		sqls = append(sqls, createIndexSQL(ts, idx))
+	}
+	return sqls
+}
+
+func (postgres *SPostgreSQLBackend) IsSupportIndexAndContraints() bool {
+	return true
+}
+
+func (postgres *SPostgreSQLBackend) IsSupportIndexFeature(ts sqlchemy.ITableSpec, feature sqlchemy.TIndexFeature) bool {
+	switch feature {
+	case sqlchemy.INDEX_FEATURE_DESC, sqlchemy.INDEX_FEATURE_EXPRESSION, sqlchemy.INDEX_FEATURE_PARTIAL:
+		return true
+	}
+	return false
+}
+
+func (postgres *SPostgreSQLBackend) FetchTableColumnSpecs(ts sqlchemy.ITableSpec) ([]sqlchemy.IColumnSpec, error) {
+	infos, err := fetchTableColInfo(ts)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetchTableColInfo")
+	}
+	specs := make([]sqlchemy.IColumnSpec, 0)
+	for _, info := range infos {
+		spec := info.toColumnSpec()
+		if spec != nil {
+			specs = append(specs, spec)
+		}
+	}
+	return specs, nil
+}
+
+func (postgres *SPostgreSQLBackend) FetchIndexesAndConstraints(ts sqlchemy.ITableSpec) ([]sqlchemy.STableIndex, []sqlchemy.STableConstraint, error) {
+	retIdxes, err := fetchTableIndexDefs(ts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "fetchTableIndexDefs")
+	}
+	constraints, err := fetchTableForeignKeys(ts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "fetchTableForeignKeys")
+	}
+	return retIdxes, constraints, nil
+}
+
+func getTextSqlType(tagmap map[string]string) (string, map[string]string) {
+	var width int
+	var sqltype string
+	tagmap, widthStr, _ := utils.TagPop(tagmap, sqlchemy.TAG_WIDTH)
+	if len(widthStr) > 0 && regutils.MatchInteger(widthStr) {
+		width, _ = strconv.Atoi(widthStr)
+	}
+	if width == 0 {
+		sqltype = "TEXT"
+	} else {
+		tagmap[sqlchemy.TAG_WIDTH] = widthStr
+		sqltype = "VARCHAR"
+	}
+	return sqltype, tagmap
+}
+
+func (postgres *SPostgreSQLBackend) GetColumnSpecByFieldType(table *sqlchemy.STableSpec, fieldType reflect.Type, fieldname string, tagmap map[string]string, isPointer bool) sqlchemy.IColumnSpec {
+	switch fieldType {
+	case tristate.TriStateType:
+		col := NewTristateColumn(table.Name(), fieldname, tagmap, isPointer)
+		return &col
+	case gotypes.TimeType:
+		col := NewDateTimeColumn(fieldname, tagmap, isPointer)
+		return &col
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		sqltype, tagmap := getTextSqlType(tagmap)
+		col := NewTextColumn(fieldname, sqltype, tagmap, isPointer)
+		return &col
+	case reflect.Int, reflect.Int32:
+		col := NewIntegerColumn(fieldname, "INTEGER", tagmap, isPointer)
+		return &col
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		col := NewIntegerColumn(fieldname, "SMALLINT", tagmap, isPointer)
+		return &col
+	case reflect.Int64:
+		col := NewIntegerColumn(fieldname, "BIGINT", tagmap, isPointer)
+		return &col
+	case reflect.Uint16:
+		// postgresql has no unsigned integers, use a wider type instead
+		col := NewIntegerColumn(fieldname, "INTEGER", tagmap, isPointer)
+		return &col
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		col := NewIntegerColumn(fieldname, "BIGINT", tagmap, isPointer)
+		return &col
+	case reflect.Bool:
+		col := NewBooleanColumn(fieldname, tagmap, isPointer)
+		return &col
+	case reflect.Float32, reflect.Float64:
+		if _, ok := tagmap[sqlchemy.TAG_WIDTH]; ok {
+			col := NewDecimalColumn(fieldname, tagmap, isPointer)
+			return &col
+		}
+		colType := "REAL"
+		if fieldType == gotypes.Float64Type {
+			colType = "DOUBLE PRECISION"
+		}
+		col := NewFloatColumn(fieldname, colType, tagmap, isPointer)
+		return &col
+	case reflect.Map, reflect.Slice:
+		sqltype, tagmap := getTextSqlType(tagmap)
+		col := NewCompoundColumn(fieldname, sqltype, tagmap, isPointer)
+		return &col
+	}
+	if fieldType.Implements(gotypes.ISerializableType) {
+		sqltype, tagmap := getTextSqlType(tagmap)
+		col := NewCompoundColumn(fieldname, sqltype, tagmap, isPointer)
+		return &col
+	}
+	return nil
+}
+
+func (postgres *SPostgreSQLBackend) QuoteChar() string {
+	return "\""
+}
+
+func (postgres *SPostgreSQLBackend) RegexpWhereClause(cond *sqlchemy.SRegexpConition) string {
+	var buf bytes.Buffer
+	buf.WriteString(cond.GetLeft().Reference())
+	buf.WriteString(" ~ ")
+	buf.WriteString(sqlchemy.VarConditionWhereClause(cond.GetRight()))
+	return buf.String()
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"testing"
+
+	"yunion.io/x/sqlchemy"
+	"yunion.io/x/sqlchemy/backends/tests"
+)
+
+var (
+	testTable   *sqlchemy.STable
+	testGotWant = tests.AssertGotWant
+)
+
+func testReset() {
+	tests.BackendTestReset(sqlchemy.PostgreSQLBackend)
+	testTable = tests.GetTestTable()
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("query all fields", func(t *testing.T) {
+		testReset()
+		q := testTable.Query()
+		want := `SELECT "t1"."col0" AS "col0", "t1"."col1" AS "col1", "t1"."col2" AS "col2" FROM "test" AS "t1"`
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query selected fields", func(t *testing.T) {
+		testReset()
+		q := testTable.Query(testTable.Field("col0")).Equals("col1", 100)
+		want := `SELECT "t1"."col0" AS "col0" FROM "test" AS "t1" WHERE "t1"."col1" =  ? `
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query like field", func(t *testing.T) {
+		testReset()
+		q := testTable.Query(testTable.Field("col0")).Contains("col0", "abc")
+		want := `SELECT "t1"."col0" AS "col0" FROM "test" AS "t1" WHERE "t1"."col0" ILIKE  ? `
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query regexp field", func(t *testing.T) {
+		testReset()
+		q := testTable.Query(testTable.Field("col0")).Regexp("col1", "^ab$")
+		want := `SELECT "t1"."col0" AS "col0" FROM "test" AS "t1" WHERE "t1"."col1" ~  ? `
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query selected fields from subquery", func(t *testing.T) {
+		testReset()
+		q := testTable.Query().SubQuery().Query(testTable.Field("col0")).Equals("col1", 100)
+		want := `SELECT "t1"."col0" AS "col0" FROM (SELECT "t1"."col1" AS "col1" FROM "test" AS "t1") AS "t2" WHERE "t2"."col1" =  ? `
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query GROUP_CONCAT2 func", func(t *testing.T) {
+		testReset()
+		q := testTable.Query(sqlchemy.SUM("total", testTable.Field("col1")), sqlchemy.GROUP_CONCAT("all_col2", testTable.Field("col2")), testTable.Field("col0")).GroupBy(testTable.Field("col0"))
+		q = q.Asc(q.Field("total"))
+		want := `SELECT SUM("t1"."col1") AS "total", STRING_AGG(CAST("t1"."col2" AS TEXT), ',') AS "all_col2", "t1"."col0" AS "col0" FROM "test" AS "t1" GROUP BY "t1"."col0" ORDER BY "total" ASC`
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query INET_ATON func", func(t *testing.T) {
+		testReset()
+		q := testTable.Query(testTable.Field("col1"), sqlchemy.INET_ATON(testTable.Field("col0")).Label("ipaddr"))
+		want := `SELECT "t1"."col1" AS "col1", (CAST("t1"."col0" AS INET) - '0.0.0.0'::INET) AS "ipaddr" FROM "test" AS "t1"`
+		testGotWant(t, q.String(), want)
+	})
+
+	t.Run("query REPLACE func", func(t *testing.T) {
+		testReset()
+		q := testTable.Query(sqlchemy.REPLACE("col0", testTable.Field("col0"), "a", "b"))
+		want := `SELECT REPLACE("t1"."col0", 'a', 'b') AS "col0" FROM "test" AS "t1"`
+		testGotWant(t, q.String(), want)
+	})
+}
+
+func TestReplacePlaceholders(t *testing.T) {
+	backend := &SPostgreSQLBackend{}
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   `SELECT "t1"."col0" AS "col0" FROM "test" AS "t1" WHERE "t1"."col1" =  ? `,
+			want: `SELECT "t1"."col0" AS "col0" FROM "test" AS "t1" WHERE "t1"."col1" =  $1 `,
+		},
+		{
+			in:   `SELECT * FROM "test" WHERE "col0" IN ( ?, ?, ? ) AND "col1" = ?`,
+			want: `SELECT * FROM "test" WHERE "col0" IN ( $1, $2, $3 ) AND "col1" = $4`,
+		},
+		{
+			in:   `SELECT * FROM "test" WHERE "col0" = 'what?' AND "col?" = ?`,
+			want: `SELECT * FROM "test" WHERE "col0" = 'what?' AND "col?" = $1`,
+		},
+		{
+			in:   `SELECT * FROM "test" WHERE "col0" = 'it''s?' AND "col1" = ?`,
+			want: `SELECT * FROM "test" WHERE "col0" = 'it''s?' AND "col1" = $1`,
+		},
+	}
+	for _, c := range cases {
+		testGotWant(t, backend.ReplacePlaceholders(c.in), c.want)
+	}
+}
+
+func TestCountQuery(t *testing.T) {
+	testReset()
+
+	q := testTable.Query()
+	q.GroupBy("col0")
+	q.Limit(8)
+	q.Offset(10)
+	cq := q.CountQuery()
+	want := `SELECT COUNT(*) AS "count" FROM (SELECT "t1"."col0" AS "col0", MAX("t1"."col1") AS "col1", MAX("t1"."col2") AS "col2" FROM "test" AS "t1" GROUP BY "t1"."col0") AS "t2"`
+	testGotWant(t, cq.String(), want)
+}
+
+func TestCTEQuery(t *testing.T) {
+	t.Run("query with", func(t *testing.T) {
+		testReset()
+		cte := sqlchemy.NewCTE("big", testTable.Query(testTable.Field("col0"), testTable.Field("col1")).GT("col1", 100))
+		q := cte.Query(cte.Field("col0")).Equals("col0", "abc")
+		want := `WITH "big" AS (SELECT "t1"."col0" AS "col0", "t1"."col1" AS "col1" FROM "test" AS "t1" WHERE "t1"."col1" >  ? ) SELECT "t2"."col0" AS "col0" FROM "big" AS "t2" WHERE "t2"."col0" =  ? `
+		testGotWant(t, q.String(), want)
+		testGotWant(t, fmt.Sprintf("%v", q.Variables()), "[100 abc]")
+	})
+
+	t.Run("query with join", func(t *testing.T) {
+		testReset()
+		q := testTable.Query(testTable.Field("col0"))
+		cte := q.With("big", testTable.Query(testTable.Field("col0"), testTable.Field("col1")).GT("col1", 100))
+		q = q.Join(cte, sqlchemy.Equals(testTable.Field("col0"), cte.Field("col0"))).Equals("col2", "x")
+		want := `WITH "big" AS (SELECT "t1"."col0" AS "col0", "t1"."col1" AS "col1" FROM "test" AS "t1" WHERE "t1"."col1" >  ? ) SELECT "t1"."col0" AS "col0" FROM "test" AS "t1" JOIN "big" AS "t2" ON "t1"."col0" = "t2"."col0" WHERE "t1"."col2" =  ? `
+		testGotWant(t, q.String(), want)
+		testGotWant(t, fmt.Sprintf("%v", q.Variables()), "[100 x]")
+	})
+
+	t.Run("query with recursive", func(t *testing.T) {
+		testReset()
+		anchor := testTable.Query(testTable.Field("col0"), testTable.Field("col1")).Equals("col0", "root")
+		cte, err := sqlchemy.NewRecursiveCTE("tree", anchor, func(self *sqlchemy.SCTE) sqlchemy.IQuery {
+			child := tests.GetTestTableSpec().Instance()
+			return child.Query(child.Field("col0"), child.Field("col1")).Join(self, sqlchemy.Equals(child.Field("col2"), self.Field("col0")))
+		})
+		if err != nil {
+			t.Fatalf("NewRecursiveCTE fail: %s", err)
+		}
+		q := cte.Query(cte.Field("col0"))
+		want := `WITH RECURSIVE "tree"("col0", "col1") AS (SELECT "t1"."col0" AS "col0", "t1"."col1" AS "col1" FROM "test" AS "t1" WHERE "t1"."col0" =  ?  UNION ALL SELECT "t3"."col0" AS "col0", "t3"."col1" AS "col1" FROM "test" AS "t3" JOIN "tree" AS "t2" ON "t3"."col2" = "t2"."col0") SELECT "t2"."col0" AS "col0" FROM "tree" AS "t2"`
+		testGotWant(t, q.String(), want)
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func (postgres *SPostgreSQLBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges) []string {
+	ret := make([]string, 0)
+
+	for _, fk := range changes.RemoveConstraints {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT "%s"`, ts.Name(), fk.Name())
+		ret = append(ret, sql)
+		log.Infof("%s;", sql)
+	}
+
+	for _, idx := range changes.RemoveIndexes {
+		sql := fmt.Sprintf(`DROP INDEX "%s"`, idx.Name())
+		ret = append(ret, sql)
+		log.Infof("%s;", sql)
+	}
+
+	alters := make([]string, 0)
+
+	// first check if primary key is modifed
+	changePrimary := false
+	for _, col := range append(changes.RemoveColumns, changes.DropColumns...) {
+		if col.IsPrimary() {
+			changePrimary = true
+			break
+		}
+	}
+	if !changePrimary {
+		for _, cols := range changes.UpdatedColumns {
+			if cols.OldCol.IsPrimary() != cols.NewCol.IsPrimary() {
+				changePrimary = true
+				break
+			}
+		}
+	}
+	if !changePrimary {
+		for _, col := range changes.AddColumns {
+			if col.IsPrimary() {
+				changePrimary = true
+				break
+			}
+		}
+	}
+	// in case of a primary key change, we first need to drop primary key,
+	// which is named as <table>_pkey by default
+	if changePrimary {
+		oldHasPrimary := false
+		for _, col := range changes.OldColumns {
+			if col.IsPrimary() {
+				oldHasPrimary = true
+				break
+			}
+		}
+		if oldHasPrimary {
+			alters = append(alters, fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT IF EXISTS "%s_pkey"`, ts.Name(), ts.Name()))
+		}
+	}
+
+	/* IGNORE DROP STATEMENT */
+	for _, col := range changes.RemoveColumns {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "%s"`, ts.Name(), col.Name())
+		log.Debugf("skip %s", sql)
+		// ignore drop statement
+		// if the column is auto_increment integer column,
+		// then need to drop auto_increment attribute
+		if col.IsAutoIncrement() {
+			log.Errorf("column %s is IDENTITY, drop IDENTITY attribute", col.Name())
+			col.SetAutoIncrement(false)
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP IDENTITY IF EXISTS`, ts.Name(), col.Name())
+			alters = append(alters, sql)
+		}
+		// if the column is not nullable but no default
+		// then need to drop the not-nullable attribute
+		if !col.IsNullable() && col.Default() == "" {
+			col.SetNullable(true)
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP NOT NULL`, ts.Name(), col.Name())
+			alters = append(alters, sql)
+			log.Errorf("column %s is not nullable but no default, drop not nullable attribute", col.Name())
+		}
+	}
+	for _, col := range changes.DropColumns {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "%s"`, ts.Name(), col.Name())
+		alters = append(alters, sql)
+	}
+	for _, cols := range changes.UpdatedColumns {
+		if cols.OldCol.Name() != cols.NewCol.Name() {
+			// rename
+			sql := fmt.Sprintf(`ALTER TABLE "%s" RENAME COLUMN "%s" TO "%s"`, ts.Name(), cols.OldCol.Name(), cols.NewCol.Name())
+			alters = append(alters, sql)
+			continue
+		}
+		if cols.OldCol.IsAutoIncrement() && !cols.NewCol.IsAutoIncrement() {
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP IDENTITY IF EXISTS`, ts.Name(), cols.NewCol.Name())
+			alters = append(alters, sql)
+		}
+		if cols.OldCol.ColType() != cols.NewCol.ColType() {
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" TYPE %s USING "%s"::%s`, ts.Name(), cols.NewCol.Name(), cols.NewCol.ColType(), cols.NewCol.Name(), cols.NewCol.ColType())
+			alters = append(alters, sql)
+		}
+		if !cols.OldCol.IsAutoIncrement() && cols.NewCol.IsAutoIncrement() {
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" ADD %s`, ts.Name(), cols.NewCol.Name(), cols.NewCol.ExtraDefs())
+			alters = append(alters, sql)
+		}
+
+		if hasDefault(cols.NewCol) && cols.OldCol.Default() != cols.NewCol.Default() {
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" SET DEFAULT %s`, ts.Name(), cols.NewCol.Name(), defaultString(cols.NewCol))
+			alters = append(alters, sql)
+		} else if !hasDefault(cols.NewCol) && hasDefault(cols.OldCol) {
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP DEFAULT`, ts.Name(), cols.NewCol.Name())
+			alters = append(alters, sql)
+		}
+
+		if cols.NewCol.IsNullable() != cols.OldCol.IsNullable() {
+			if cols.NewCol.IsNullable() {
+				sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP NOT NULL`, ts.Name(), cols.NewCol.Name())
+				alters = append(alters, sql)
+			} else {
+				sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" SET NOT NULL`, ts.Name(), cols.NewCol.Name())
+				alters = append(alters, sql)
+			}
+		}
+	}
+	for _, col := range changes.AddColumns {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN %s`, ts.Name(), col.DefinitionString())
+		alters = append(alters, sql)
+	}
+	if changePrimary {
+		primaries := make([]string, 0)
+		for _, c := range ts.Columns() {
+			if c.IsPrimary() {
+				primaries = append(primaries, fmt.Sprintf(`"%s"`, c.Name()))
+			}
+		}
+		if len(primaries) > 0 {
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ADD PRIMARY KEY (%s)`, ts.Name(), strings.Join(primaries, ", "))
+			alters = append(alters, sql)
+		}
+	}
+
+	if len(alters) > 0 {
+		ret = append(ret, alters...)
+		log.Infof("%s", strings.Join(alters, "\n"))
+	}
+
+	for _, idx := range changes.AddIndexes {
+		sql := createIndexSQL(ts, idx)
+		ret = append(ret, sql)
+		log.Infof("%s", sql)
+	}
+
+	for _, fk := range changes.AddConstraints {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" ADD %s`, ts.Name(), fk.DefinitionString(`"`))
+		ret = append(ret, sql)
+		log.Infof("%s", sql)
+	}
+
+	return ret
+}
+
+func defaultString(c sqlchemy.IColumnSpec) string {
+	defStr := sqlchemy.GetStringValue(c.ConvertFromString(c.Default()))
+	if c.IsText() {
+		defStr = "'" + strings.ReplaceAll(defStr, "'", "''") + "'"
+	}
+	return defStr
+}
+
+func createIndexSQL(ts sqlchemy.ITableSpec, idx sqlchemy.STableIndex) string {
+	unique := ""
+	if idx.IsUnique() {
+		unique = "UNIQUE "
+	}
+	sql := fmt.Sprintf(`CREATE %sINDEX "%s" ON "%s" (%s)`, unique, idx.Name(), ts.Name(), strings.Join(idx.KeyParts(`"`), ","))
+	if len(idx.Where()) > 0 {
+		sql += " WHERE " + idx.Where()
+	}
+	return sql
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"reflect"
+	"testing"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestSync(t *testing.T) {
+	type TableStruct1 struct {
+		Id     uint64 `auto_increment:"true"`
+		Name   string `width:"64" charset:"utf8"`
+		Age    int    `nullable:"true" default:"12"`
+		IsMale *bool  `nullable:"false" default:"true"`
+	}
+	type TableStruct2 struct {
+		Id     uint64 `auto_increment:"true"`
+		Name   string `width:"128" charset:"utf8"`
+		Age    int    `nullable:"true" default:"12"`
+		Gender string `width:"8" nullable:"false" default:"male"`
+	}
+
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.PostgreSQLBackend)
+	ts1 := sqlchemy.NewTableSpecFromStruct(TableStruct1{}, "table1")
+	ts2 := sqlchemy.NewTableSpecFromStruct(TableStruct2{}, "table1")
+
+	changes := sqlchemy.STableChanges{}
+	changes.RemoveColumns, changes.UpdatedColumns, changes.AddColumns = sqlchemy.DiffCols(ts2.Name(), ts1.Columns(), ts2.Columns())
+	backend := &SPostgreSQLBackend{}
+	sqls := backend.CommitTableChangeSQL(ts2, changes)
+	want := []string{
+		`ALTER TABLE "table1" ALTER COLUMN "name" TYPE VARCHAR(128) USING "name"::VARCHAR(128)`,
+		`ALTER TABLE "table1" ADD COLUMN "gender" VARCHAR(8) DEFAULT 'male' NOT NULL`,
+	}
+	if !reflect.DeepEqual(sqls, want) {
+		t.Errorf("Expect: %s", want)
+		t.Errorf("Got: %s", sqls)
+	}
+}
+
+func TestSyncForeignKeys(t *testing.T) {
+	type MemberStruct struct {
+		Id      int `primary:"true"`
+		GroupId int `nullable:"true" foreign_key:"groups_tbl(id)" on_delete:"set_null"`
+	}
+
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.PostgreSQLBackend)
+	ts := sqlchemy.NewTableSpecFromStruct(MemberStruct{}, "members_tbl")
+
+	backend := &SPostgreSQLBackend{}
+	sqls := backend.GetCreateSQLs(ts)
+	want := []string{
+		`CREATE TABLE IF NOT EXISTS "members_tbl" ("id" INTEGER DEFAULT 0 NOT NULL, "group_id" INTEGER, PRIMARY KEY ("id"), CONSTRAINT "fk_members_tbl_group_id" FOREIGN KEY ("group_id") REFERENCES "groups_tbl" ("id") ON DELETE SET NULL)`,
+	}
+	if !reflect.DeepEqual(sqls, want) {
+		t.Errorf("Expect: %s", want)
+		t.Errorf("Got: %s", sqls)
+	}
+
+	changes := sqlchemy.STableChanges{
+		RemoveConstraints: []sqlchemy.STableConstraint{
+			sqlchemy.NewTableForeignKey(nil, "members_tbl_group_id_fkey", []string{"group_id"}, "groups_tbl", []string{"id"}, foreignKeyAction("a"), foreignKeyAction("a")),
+		},
+		AddConstraints: ts.ForeignKeys(),
+	}
+	sqls = backend.CommitTableChangeSQL(ts, changes)
+	want = []string{
+		`ALTER TABLE "members_tbl" DROP CONSTRAINT "members_tbl_group_id_fkey"`,
+		`ALTER TABLE "members_tbl" ADD CONSTRAINT "fk_members_tbl_group_id" FOREIGN KEY ("group_id") REFERENCES "groups_tbl" ("id") ON DELETE SET NULL`,
+	}
+	if !reflect.DeepEqual(sqls, want) {
+		t.Errorf("Expect: %s", want)
+		t.Errorf("Got: %s", sqls)
+	}
+}
+
+func TestCreateIndexOptions(t *testing.T) {
+	type AccountStruct struct {
+		Id    int    `primary:"true"`
+		Email string `width:"64"`
+	}
+
+	sqlchemy.SetDBWithNameBackend(nil, sqlchemy.DefaultDB, sqlchemy.PostgreSQLBackend)
+	ts := sqlchemy.NewTableSpecFromStruct(AccountStruct{}, "accounts")
+	ts.AddIndexWithOptions(sqlchemy.SIndexOptions{Name: "ix_accounts_lower_email", Unique: true, Expression: "lower(email)"})
+	ts.AddIndexWithOptions(sqlchemy.SIndexOptions{Descending: []string{"id"}, Prefixes: map[string]int{"email": 8}, Where: "email IS NOT NULL"}, "email", "id")
+	ts.AddIndexWithOptions(sqlchemy.SIndexOptions{Type: sqlchemy.INDEX_TYPE_FULLTEXT}, "email")
+
+	want := []string{
+		`CREATE UNIQUE INDEX "ix_accounts_lower_email" ON "accounts" ((lower(email)))`,
+		`CREATE INDEX "ix_accounts_email_id_7e870908" ON "accounts" ("email","id" DESC) WHERE email IS NOT NULL`,
+		`CREATE INDEX "ix_accounts_email" ON "accounts" ("email")`,
+	}
+	indexes := ts.Indexes()
+	got := make([]string, len(indexes))
+	for i := range indexes {
+		got[i] = createIndexSQL(ts, indexes[i])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expect: %s", want)
+		t.Errorf("Got: %s", got)
+	}
+
+	def := `CREATE INDEX ix_accounts_email_id_7e870908 ON public.accounts USING btree (email, id DESC) WHERE (email IS NOT NULL)`
+	idx, err := sqlchemy.ParseCreateIndexSQL(ts, def)
+	if err != nil {
+		t.Fatalf("ParseCreateIndexSQL fail %s", err)
+	}
+	if !idx.IsEquivalent(indexes[1]) {
+		t.Errorf("%s should be equivalent to %s", def, want[1])
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestContextCancel(t *testing.T) {
+	type TableStruct struct {
+		Id   uint64 `auto_increment:"true"`
+		Name string `width:"64" charset:"utf8"`
+	}
+	openTestDB(t, "ctxtest")
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "ctx_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+
+	row := TableStruct{Name: "a"}
+	err = ts.InsertContext(context.Background(), &row)
+	if err != nil {
+		t.Fatalf("insert fail: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rows := make([]TableStruct, 0)
+	err = ts.Query().AllContext(ctx, &rows)
+	if errors.Cause(err) != sqlchemy.ErrContextCanceled {
+		t.Errorf("AllContext want ErrContextCanceled got %v", err)
+	}
+	if !stderrors.Is(err, context.Canceled) || !stderrors.Is(err, sqlchemy.ErrContextCanceled) {
+		t.Errorf("AllContext want context.Canceled got %v", err)
+	}
+	_, err = ts.Query().CountWithErrorContext(ctx)
+	if errors.Cause(err) != sqlchemy.ErrContextCanceled {
+		t.Errorf("CountWithErrorContext want ErrContextCanceled got %v", err)
+	}
+	err = ts.InsertContext(ctx, &TableStruct{Name: "b"})
+	if errors.Cause(err) != sqlchemy.ErrContextCanceled {
+		t.Errorf("InsertContext want ErrContextCanceled got %v", err)
+	}
+	if !stderrors.Is(err, context.Canceled) {
+		t.Errorf("InsertContext want context.Canceled got %v", err)
+	}
+
+	deadlineCtx, cancelDeadline := context.WithTimeout(context.Background(), 0)
+	defer cancelDeadline()
+	_, err = ts.Query().CountWithErrorContext(deadlineCtx)
+	if !stderrors.Is(err, context.DeadlineExceeded) || !stderrors.Is(err, sqlchemy.ErrContextCanceled) {
+		t.Errorf("CountWithErrorContext want context.DeadlineExceeded got %v", err)
+	}
+	_, err = ts.Database().ExecContext(ctx, "DELETE FROM `ctx_table`")
+	if errors.Cause(err) != sqlchemy.ErrContextCanceled {
+		t.Errorf("ExecContext want ErrContextCanceled got %v", err)
+	}
+
+	cnt, err := ts.Query().CountWithErrorContext(context.Background())
+	if err != nil {
+		t.Errorf("CountWithErrorContext fail: %s", err)
+	} else if cnt != 1 {
+		t.Errorf("want 1 row got %d", cnt)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"sort"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestRecursiveCTE(t *testing.T) {
+	type TableStruct struct {
+		Id       string `width:"36" primary:"true"`
+		ParentId string `width:"36"`
+	}
+	openTestDB(t, "ctetest")
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "cte_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	for _, row := range []TableStruct{
+		{Id: "root"},
+		{Id: "a", ParentId: "root"},
+		{Id: "b", ParentId: "root"},
+		{Id: "a1", ParentId: "a"},
+		{Id: "c", ParentId: "other"},
+	} {
+		err := ts.Insert(&row)
+		if err != nil {
+			t.Fatalf("insert fail: %s", err)
+		}
+	}
+
+	anchor := ts.Query().Equals("id", "root")
+	tree, err := sqlchemy.NewRecursiveCTE("tree", anchor, func(self *sqlchemy.SCTE) sqlchemy.IQuery {
+		child := ts.Instance()
+		return child.Query().Join(self, sqlchemy.Equals(child.Field("parent_id"), self.Field("id")))
+	})
+	if err != nil {
+		t.Fatalf("NewRecursiveCTE fail: %s", err)
+	}
+	rows := make([]TableStruct, 0)
+	err = tree.Query().All(&rows)
+	if err != nil {
+		t.Fatalf("query tree fail: %s", err)
+	}
+	ids := make([]string, 0, len(rows))
+	for i := range rows {
+		ids = append(ids, rows[i].Id)
+	}
+	sort.Strings(ids)
+	want := []string{"a", "a1", "b", "root"}
+	if len(ids) != len(want) {
+		t.Fatalf("want %v got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("want %v got %v", want, ids)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+func TestIterate(t *testing.T) {
+	type TableStruct struct {
+		Id   int    `primary:"true"`
+		Name string `width:"16"`
+	}
+	dbConn := openTestDB(t, "cursortest").DB()
+	// a leaked sql.Rows would hold the only connection and block the following queries
+	dbConn.SetMaxOpenConns(1)
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "cursor_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	for i := 1; i <= 5; i++ {
+		row := TableStruct{Id: i}
+		if i%2 == 1 {
+			row.Name = "odd"
+		}
+		err := ts.Insert(&row)
+		if err != nil {
+			t.Fatalf("insert fail: %s", err)
+		}
+	}
+
+	t.Run("iterate all", func(t *testing.T) {
+		row := TableStruct{}
+		ids := 0
+		names := 0
+		err := ts.Query().Asc("id").Iterate(&row, func(dest interface{}) error {
+			ids += dest.(*TableStruct).Id
+			if len(row.Name) > 0 {
+				names++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("iterate fail: %s", err)
+		}
+		if ids != 15 || names != 3 {
+			t.Errorf("want ids sum 15 and 3 names, got %d and %d", ids, names)
+		}
+	})
+
+	t.Run("stop early", func(t *testing.T) {
+		row := TableStruct{}
+		count := 0
+		err := ts.Query().Asc("id").Iterate(&row, func(dest interface{}) error {
+			count++
+			if row.Id == 2 {
+				return sqlchemy.ErrStopIteration
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("iterate fail: %s", err)
+		}
+		if count != 2 {
+			t.Errorf("want 2 rows got %d", count)
+		}
+	})
+
+	t.Run("callback error", func(t *testing.T) {
+		abort := errors.Error("abort")
+		err := ts.Query().Iterate(&TableStruct{}, func(dest interface{}) error {
+			return abort
+		})
+		if err != abort {
+			t.Errorf("want abort got %v", err)
+		}
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		cursor, err := ts.Query().Desc("id").Cursor()
+		if err != nil {
+			t.Fatalf("cursor fail: %s", err)
+		}
+		defer cursor.Close()
+		if !cursor.Next() {
+			t.Fatalf("cursor has no rows: %v", cursor.Err())
+		}
+		row := TableStruct{}
+		err = cursor.Scan(&row)
+		if err != nil {
+			t.Fatalf("scan fail: %s", err)
+		}
+		if row.Id != 5 {
+			t.Errorf("want id 5 got %d", row.Id)
+		}
+		err = cursor.Close()
+		if err != nil {
+			t.Fatalf("close fail: %s", err)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cnt, err := ts.Query().CountWithErrorContext(ctx)
+	if err != nil {
+		t.Fatalf("count after iterations fail, rows leaked? %s", err)
+	}
+	if cnt != 5 {
+		t.Errorf("want 5 rows got %d", cnt)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+// openTestDB opens the shared in-memory database name as the default database,
+// it is closed when the test finishes
+func openTestDB(t *testing.T, name string) *sqlchemy.SDatabase {
+	t.Helper()
+	dbConn, err := sql.Open("sqlite3", "file:"+name+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("open sqlite memory db fail: %s", err)
+	}
+	t.Cleanup(func() {
+		dbConn.Close()
+	})
+	sqlchemy.SetDBWithNameBackend(dbConn, sqlchemy.DefaultDB, sqlchemy.SQLiteBackend)
+	return sqlchemy.GetDefaultDB()
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestDebugStringExecutable(t *testing.T) {
+	type TableStruct struct {
+		Id   int    `primary:"true"`
+		Name string `width:"32"`
+		Data []byte `nullable:"true"`
+	}
+	dbConn := openTestDB(t, "debugstringtest").DB()
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "debugstring_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	for i, name := range []string{"it's ?", "plain"} {
+		err := ts.Insert(&TableStruct{Id: i + 1, Name: name})
+		if err != nil {
+			t.Fatalf("insert fail: %s", err)
+		}
+	}
+
+	tbl := ts.Instance()
+	q := tbl.Query(tbl.Field("id")).Equals("name", "it's ?").IsNull("data")
+	debugSql := q.DebugString()
+	want := "(`t1`.`name` =  'it''s ?' ) AND (`t1`.`data` IS NULL)"
+	if !strings.HasSuffix(debugSql, strings.ReplaceAll(want, "t1", tbl.Alias())) {
+		t.Errorf("want %s got %s", want, debugSql)
+	}
+	var id int
+	err = dbConn.QueryRow(debugSql).Scan(&id)
+	if err != nil {
+		t.Fatalf("execute debug string fail: %s", err)
+	}
+	if id != 1 {
+		t.Errorf("want id 1 got %d", id)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+// ClassifyError translates a SQLite driver error into an Error constant of sqlchemy
+func (sqlite *SSqliteBackend) ClassifyError(err error) error {
+	sqliteErr, ok := err.(sqlite3.Error)
+	if !ok {
+		return sqlite.SBaseBackend.ClassifyError(err)
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return sqlchemy.ErrDuplicateEntry
+	case sqlite3.ErrConstraintForeignKey:
+		return sqlchemy.ErrForeignKeyViolation
+	case sqlite3.ErrBusySnapshot:
+		return sqlchemy.ErrDeadlock
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return sqlchemy.ErrLockTimeout
+	case sqlite3.ErrTooBig:
+		return sqlchemy.ErrDataTooLong
+	case sqlite3.ErrError:
+		if strings.HasPrefix(sqliteErr.Error(), "no such table") {
+			return sqlchemy.ErrTableNotExists
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+func TestClassifyError(t *testing.T) {
+	type ErrorStruct struct {
+		Id   int    `primary:"true"`
+		Name string `width:"16"`
+	}
+	openTestDB(t, "errortest")
+	ts := sqlchemy.NewTableSpecFromStruct(ErrorStruct{}, "error_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	err = ts.Insert(&ErrorStruct{Id: 1, Name: "first"})
+	if err != nil {
+		t.Fatalf("Insert fail: %s", err)
+	}
+
+	err = ts.Insert(&ErrorStruct{Id: 1, Name: "second"})
+	if !stderrors.Is(err, sqlchemy.ErrDuplicateEntry) {
+		t.Errorf("duplicate insert want ErrDuplicateEntry got %v", err)
+	}
+	// callers matching the driver error by the cause still work
+	if sqliteErr, ok := errors.Cause(err).(sqlite3.Error); !ok || sqliteErr.Code != sqlite3.ErrConstraint {
+		t.Errorf("cause of duplicate insert want sqlite3.Error got %#v", errors.Cause(err))
+	}
+	if stderrors.Is(err, sqlchemy.ErrForeignKeyViolation) {
+		t.Errorf("duplicate insert should not be ErrForeignKeyViolation")
+	}
+
+	_, err = sqlchemy.GetDefaultDB().Exec("SELECT * FROM no_such_table")
+	if !stderrors.Is(err, sqlchemy.ErrTableNotExists) {
+		t.Errorf("query missing table want ErrTableNotExists got %v", err)
+	}
+	if class := sqlchemy.GetDefaultDB().ClassifyError(errors.Wrap(err, "exec")); class != sqlchemy.ErrTableNotExists {
+		t.Errorf("ClassifyError want ErrTableNotExists got %v", class)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestSyncForeignKeys(t *testing.T) {
+	type GroupStruct struct {
+		Id int `primary:"true"`
+	}
+	type MemberStructV1 struct {
+		Id      int `primary:"true"`
+		GroupId int `nullable:"true"`
+	}
+	type MemberStructV2 struct {
+		Id      int `primary:"true"`
+		GroupId int `nullable:"true" foreign_key:"fk_groups(id)" on_delete:"cascade"`
+	}
+	dbConn := openTestDB(t, "fktest").DB()
+	// foreign key enforcement is a per-connection setting of sqlite
+	dbConn.SetMaxOpenConns(1)
+	var err error
+
+	groups := sqlchemy.NewTableSpecFromStruct(GroupStruct{}, "fk_groups")
+	members := sqlchemy.NewTableSpecFromStruct(MemberStructV1{}, "fk_members")
+	for _, ts := range []*sqlchemy.STableSpec{groups, members} {
+		err = ts.Sync()
+		if err != nil {
+			t.Fatalf("Sync %s fail: %s", ts.Name(), err)
+		}
+	}
+	for i := 1; i <= 2; i++ {
+		err = groups.Insert(&GroupStruct{Id: i})
+		if err != nil {
+			t.Fatalf("Insert group fail: %s", err)
+		}
+		err = members.Insert(&MemberStructV1{Id: i, GroupId: i})
+		if err != nil {
+			t.Fatalf("Insert member fail: %s", err)
+		}
+	}
+
+	members = sqlchemy.NewTableSpecFromStruct(MemberStructV2{}, "fk_members")
+	if len(members.SyncSQL()) == 0 {
+		t.Fatalf("expect sync sqls to add the foreign key")
+	}
+	err = members.Sync()
+	if err != nil {
+		t.Fatalf("Sync with foreign key fail: %s", err)
+	}
+	if sqls := members.SyncSQL(); len(sqls) > 0 {
+		t.Errorf("expect no sync sqls after sync, got %s", sqls)
+	}
+
+	_, err = dbConn.Exec("PRAGMA foreign_keys = ON")
+	if err != nil {
+		t.Fatalf("enable foreign keys fail: %s", err)
+	}
+	_, err = dbConn.Exec("DELETE FROM `fk_groups` WHERE `id` = 1")
+	if err != nil {
+		t.Fatalf("delete group fail: %s", err)
+	}
+	cnt, err := members.Query().CountWithError()
+	if err != nil {
+		t.Fatalf("count members fail: %s", err)
+	}
+	if cnt != 1 {
+		t.Errorf("members after cascade delete want 1 got %d", cnt)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+var hookCalls []string
+
+type HookStruct struct {
+	Id   int    `primary:"true"`
+	Name string `width:"16"`
+}
+
+func (h *HookStruct) BeforeInsert() error {
+	hookCalls = append(hookCalls, "BeforeInsert")
+	if h.Name == "invalid" {
+		return errors.Error("invalid name")
+	}
+	return nil
+}
+
+func (h *HookStruct) AfterInsert() {
+	hookCalls = append(hookCalls, "AfterInsert")
+}
+
+func (h *HookStruct) BeforeUpdate() error {
+	hookCalls = append(hookCalls, "BeforeUpdate")
+	if h.Name == "invalid" {
+		return errors.Error("invalid name")
+	}
+	return nil
+}
+
+func (h *HookStruct) AfterUpdate(diffs sqlchemy.UpdateDiffs) {
+	if _, ok := diffs["name"]; ok {
+		hookCalls = append(hookCalls, "AfterUpdate")
+	}
+}
+
+func (h *HookStruct) BeforeDelete() error {
+	hookCalls = append(hookCalls, "BeforeDelete")
+	if h.Name == "locked" {
+		return errors.Error("record locked")
+	}
+	return nil
+}
+
+func (h *HookStruct) AfterDelete() {
+	hookCalls = append(hookCalls, "AfterDelete")
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	openTestDB(t, "hooktest")
+	ts := sqlchemy.NewTableSpecFromStruct(HookStruct{}, "hook_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+
+	checkCalls := func(want ...string) {
+		t.Helper()
+		if fmt.Sprint(hookCalls) != fmt.Sprint(want) {
+			t.Errorf("want hooks %v got %v", want, hookCalls)
+		}
+		hookCalls = nil
+	}
+
+	row := HookStruct{Id: 1, Name: "first"}
+	err = ts.Insert(&row)
+	if err != nil {
+		t.Fatalf("insert fail: %s", err)
+	}
+	checkCalls("BeforeInsert", "AfterInsert")
+
+	err = ts.Insert(&HookStruct{Id: 2, Name: "invalid"})
+	if err == nil {
+		t.Errorf("insert should be aborted by BeforeInsert")
+	}
+	checkCalls("BeforeInsert")
+
+	err = ts.InsertOrUpdate(&HookStruct{Id: 2, Name: "locked"})
+	if err != nil {
+		t.Fatalf("insert or update fail: %s", err)
+	}
+	checkCalls("BeforeInsert", "AfterInsert")
+
+	_, err = ts.Update(&row, func() error {
+		row.Name = "second"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("update fail: %s", err)
+	}
+	checkCalls("BeforeUpdate", "AfterUpdate")
+
+	_, err = ts.Update(&row, func() error {
+		row.Name = "invalid"
+		return nil
+	})
+	if err == nil {
+		t.Errorf("update should be aborted by BeforeUpdate")
+	}
+	checkCalls("BeforeUpdate")
+
+	row = HookStruct{Id: 1, Name: "second"}
+	err = ts.UpdateFields(&row, map[string]interface{}{"name": "third"})
+	if err != nil {
+		t.Fatalf("update fields fail: %s", err)
+	}
+	if row.Name != "third" {
+		t.Errorf("want name third got %s", row.Name)
+	}
+	checkCalls("BeforeUpdate", "AfterUpdate")
+
+	err = ts.DeleteFrom(map[string]interface{}{"id": 2})
+	if err == nil {
+		t.Errorf("delete should be aborted by BeforeDelete")
+	}
+	checkCalls("BeforeDelete")
+
+	err = ts.DeleteFrom(map[string]interface{}{"id": []int{1, 2, 3}})
+	if err == nil {
+		t.Errorf("delete should be aborted by BeforeDelete")
+	}
+	hookCalls = nil
+
+	tx, err := sqlchemy.GetDefaultDB().Begin()
+	if err != nil {
+		t.Fatalf("begin fail: %s", err)
+	}
+	err = ts.InTx(tx).DeleteFrom(map[string]interface{}{"id": 1})
+	if err != nil {
+		t.Fatalf("delete in tx fail: %s", err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("rollback fail: %s", err)
+	}
+	checkCalls("BeforeDelete", "AfterDelete")
+	cnt, err := ts.Query().Equals("id", 1).CountWithError()
+	if err != nil || cnt != 1 {
+		t.Errorf("delete should be rolled back with the outer transaction, count %d: %v", cnt, err)
+	}
+
+	err = ts.DeleteFrom(map[string]interface{}{"id": 1})
+	if err != nil {
+		t.Fatalf("delete fail: %s", err)
+	}
+	checkCalls("BeforeDelete", "AfterDelete")
+
+	cnt, err = ts.Query().CountWithError()
+	if err != nil {
+		t.Fatalf("count fail: %s", err)
+	}
+	if cnt != 1 {
+		t.Errorf("want 1 row got %d", cnt)
+	}
+}
+
+type MismatchHookStruct struct {
+	Id   int    `primary:"true"`
+	Name string `width:"16"`
+}
+
+// AfterUpdate has the name of a hook but not its signature, so it is not a hook
+func (h *MismatchHookStruct) AfterUpdate(diffs map[string]int) {
+	hookCalls = append(hookCalls, "AfterUpdate")
+}
+
+func TestMismatchHook(t *testing.T) {
+	openTestDB(t, "mismatchhooktest")
+	ts := sqlchemy.NewTableSpecFromStruct(MismatchHookStruct{}, "mismatch_hook_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	hookCalls = nil
+	row := MismatchHookStruct{Id: 1, Name: "first"}
+	err = ts.Insert(&row)
+	if err != nil {
+		t.Fatalf("insert fail: %s", err)
+	}
+	_, err = ts.Update(&row, func() error {
+		row.Name = "second"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("update fail: %s", err)
+	}
+	if len(hookCalls) > 0 {
+		t.Errorf("want no hooks called got %v", hookCalls)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestSyncIndexOptions(t *testing.T) {
+	type AccountStruct struct {
+		Id      int    `primary:"true" auto_increment:"true"`
+		Email   string `width:"64"`
+		Name    string `width:"64" index:"true" unique:"true"`
+		Deleted bool   `nullable:"false" default:"false"`
+	}
+	openTestDB(t, "indextest")
+
+	ts := sqlchemy.NewTableSpecFromStruct(AccountStruct{}, "index_accounts")
+	ts.AddIndexWithOptions(sqlchemy.SIndexOptions{Unique: true, Expression: "lower(`email`)", Where: "`deleted` = 0"})
+	ts.AddIndexWithOptions(sqlchemy.SIndexOptions{Descending: []string{"id"}, Prefixes: map[string]int{"email": 8}}, "email", "id")
+	ts.AddIndexWithOptions(sqlchemy.SIndexOptions{Type: sqlchemy.INDEX_TYPE_FULLTEXT}, "name", "email")
+	createSQLs := strings.Join(ts.CreateSQLs(), "\n")
+	for _, want := range []string{
+		"CREATE UNIQUE INDEX `ix_index_accounts_name` ON `index_accounts` (`name`)",
+		"((lower(`email`))) WHERE `deleted` = 0",
+		"CREATE INDEX `ix_index_accounts_email_id` ON `index_accounts` (`email`,`id` DESC)",
+		"CREATE INDEX `ix_index_accounts_email_name` ON `index_accounts` (`email`,`name`)",
+	} {
+		if !strings.Contains(createSQLs, want) {
+			t.Errorf("create sqls %s should contain %s", createSQLs, want)
+		}
+	}
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	if sqls := ts.SyncSQL(); len(sqls) > 0 {
+		t.Errorf("expect no sync sqls after sync, got %s", sqls)
+	}
+
+	err = ts.Insert(&AccountStruct{Email: "Foo@example.com", Name: "foo"})
+	if err != nil {
+		t.Fatalf("Insert fail: %s", err)
+	}
+	err = ts.Insert(&AccountStruct{Email: "foo@EXAMPLE.com", Name: "bar"})
+	if !errors.Is(err, sqlchemy.ErrDuplicateEntry) {
+		t.Errorf("insert duplicate email want ErrDuplicateEntry got %v", err)
+	}
+	err = ts.Insert(&AccountStruct{Email: "foo@example.com", Name: "baz", Deleted: true})
+	if err != nil {
+		t.Errorf("insert duplicate email of a deleted row fail: %s", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestInsertBatch(t *testing.T) {
+	type TableStruct struct {
+		Id      int    `primary:"true"`
+		Name    string `width:"16"`
+		Count   int    `nullable:"false" default:"0"`
+		Version int    `auto_version:"true"`
+	}
+	openTestDB(t, "insertbatchtest")
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "insert_batch_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+
+	// more rows than the placeholder limit of a single statement
+	dataList := make([]interface{}, 0)
+	for i := 1; i <= 600; i++ {
+		dataList = append(dataList, &TableStruct{Id: i, Name: fmt.Sprintf("row%d", i), Count: i})
+	}
+	err = ts.InsertBatch(dataList)
+	if err != nil {
+		t.Fatalf("InsertBatch fail: %s", err)
+	}
+	cnt, err := ts.Query().CountWithError()
+	if err != nil {
+		t.Fatalf("count fail: %s", err)
+	}
+	if cnt != 600 {
+		t.Errorf("want 600 rows got %d", cnt)
+	}
+
+	err = ts.InsertBatch([]interface{}{&TableStruct{Id: 1}})
+	if err == nil {
+		t.Errorf("InsertBatch should fail on duplicate primary key")
+	}
+
+	err = ts.InsertOrUpdateBatch([]interface{}{
+		&TableStruct{Id: 1, Name: "updated", Count: 100},
+		&TableStruct{Id: 601, Name: "new", Count: 601},
+	})
+	if err != nil {
+		t.Fatalf("InsertOrUpdateBatch fail: %s", err)
+	}
+	row := TableStruct{Id: 1}
+	err = ts.Fetch(&row)
+	if err != nil {
+		t.Fatalf("fetch fail: %s", err)
+	}
+	if row.Name != "updated" || row.Count != 100 || row.Version != 1 {
+		t.Errorf("unexpected updated row %#v", row)
+	}
+	cnt, err = ts.Query().CountWithError()
+	if err != nil {
+		t.Fatalf("count fail: %s", err)
+	}
+	if cnt != 601 {
+		t.Errorf("want 601 rows got %d", cnt)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+type ctxKey string
+
+type sRecordInterceptor struct {
+	events []sqlchemy.SQueryEvent
+}
+
+func (r *sRecordInterceptor) BeforeQuery(ctx context.Context, event *sqlchemy.SQueryEvent) context.Context {
+	return context.WithValue(ctx, ctxKey("span"), event.SQL)
+}
+
+func (r *sRecordInterceptor) AfterQuery(ctx context.Context, event *sqlchemy.SQueryEvent) {
+	if ctx.Value(ctxKey("span")) != event.SQL {
+		panic("context of BeforeQuery is not passed to AfterQuery")
+	}
+	r.events = append(r.events, *event)
+}
+
+func (r *sRecordInterceptor) find(op, prefix string) *sqlchemy.SQueryEvent {
+	for i := range r.events {
+		if r.events[i].Operation == op && strings.HasPrefix(r.events[i].SQL, prefix) {
+			return &r.events[i]
+		}
+	}
+	return nil
+}
+
+func TestInterceptor(t *testing.T) {
+	type TableStruct struct {
+		Id   int    `primary:"true"`
+		Name string `width:"16"`
+	}
+	db := openTestDB(t, "interceptortest")
+	recorder := &sRecordInterceptor{}
+	db.AddInterceptor(recorder)
+	db.AddInterceptor(sqlchemy.NewSlowQueryInterceptor(0))
+
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "interceptor_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	if recorder.find(sqlchemy.QUERY_OP_EXEC, "CREATE TABLE") == nil {
+		t.Errorf("CREATE TABLE not intercepted")
+	}
+
+	row := TableStruct{Id: 1, Name: "first"}
+	err = ts.Insert(&row)
+	if err != nil {
+		t.Fatalf("insert fail: %s", err)
+	}
+	if ev := recorder.find(sqlchemy.QUERY_OP_EXEC, "INSERT INTO"); ev == nil || ev.RowsAffected != 1 || len(ev.Vars) != 2 {
+		t.Errorf("unexpected insert event %#v", ev)
+	}
+	if ev := recorder.find(sqlchemy.QUERY_OP_QUERY_ROW, "SELECT"); ev == nil || ev.Error != nil {
+		t.Errorf("unexpected query after insert event %#v", ev)
+	}
+
+	recorder.events = nil
+	err = ts.InsertBatch([]interface{}{&TableStruct{Id: 2}, &TableStruct{Id: 3}})
+	if err != nil {
+		t.Fatalf("insert batch fail: %s", err)
+	}
+	if ev := recorder.find(sqlchemy.QUERY_OP_EXEC, "INSERT INTO"); ev == nil || ev.RowsAffected != 2 {
+		t.Errorf("unexpected insert batch event %#v", ev)
+	}
+
+	recorder.events = nil
+	_, err = ts.Update(&row, func() error {
+		row.Name = "second"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("update fail: %s", err)
+	}
+	if ev := recorder.find(sqlchemy.QUERY_OP_EXEC, "UPDATE"); ev == nil || ev.RowsAffected != 1 || ev.Duration <= 0 {
+		t.Errorf("unexpected update event %#v", ev)
+	}
+
+	recorder.events = nil
+	rows := make([]TableStruct, 0)
+	err = ts.Query().All(&rows)
+	if err != nil {
+		t.Fatalf("query fail: %s", err)
+	}
+	if ev := recorder.find(sqlchemy.QUERY_OP_QUERY, "SELECT"); ev == nil || ev.RowsAffected != -1 {
+		t.Errorf("unexpected query event %#v", ev)
+	}
+
+	recorder.events = nil
+	missing := TableStruct{}
+	err = ts.Query().Equals("id", 100).First(&missing)
+	if err != sql.ErrNoRows {
+		t.Fatalf("want sql.ErrNoRows got %v", err)
+	}
+	if ev := recorder.find(sqlchemy.QUERY_OP_QUERY_ROW, "SELECT"); ev == nil || ev.Error != sql.ErrNoRows {
+		t.Errorf("no rows should be intercepted %#v", ev)
+	}
+
+	recorder.events = nil
+	_, err = db.TxBatchExec("DELETE FROM `interceptor_table` WHERE `id` = ?", [][]interface{}{{2}, {3}})
+	if err != nil {
+		t.Fatalf("batch exec fail: %s", err)
+	}
+	if len(recorder.events) != 2 {
+		t.Errorf("want 2 events got %d", len(recorder.events))
+	}
+	for _, ev := range recorder.events {
+		if !ev.InTx || ev.RowsAffected != 1 {
+			t.Errorf("unexpected batch exec event %#v", ev)
+		}
+	}
+
+	recorder.events = nil
+	_, err = db.Exec("SELECT * FROM no_such_table")
+	if ev := recorder.find(sqlchemy.QUERY_OP_EXEC, "SELECT"); err == nil || ev == nil || ev.Error == nil {
+		t.Errorf("error should be intercepted %#v", ev)
+	}
+}
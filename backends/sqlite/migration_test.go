@@ -0,0 +1,215 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+func TestMigrator(t *testing.T) {
+	type TableStruct struct {
+		Id   int    `primary:"true"`
+		Name string `width:"16"`
+	}
+	db := openTestDB(t, "migrationtest")
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "migration_table")
+
+	mig := sqlchemy.SyncMigration(1, "Create migration table", ts)
+	if mig == nil {
+		t.Fatalf("sync migration of a new table should not be empty")
+	}
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatalf("TempDir fail: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	_, err = mig.WriteFile(dir)
+	if err != nil {
+		t.Fatalf("WriteFile fail: %s", err)
+	}
+	migrations, err := sqlchemy.LoadMigrationFiles(dir)
+	if err != nil {
+		t.Fatalf("LoadMigrationFiles fail: %s", err)
+	}
+	if len(migrations) != 1 || migrations[0].Name != mig.Name || migrations[0].Checksum() != mig.Checksum() {
+		t.Fatalf("loaded migrations differ %#v", migrations)
+	}
+
+	m := sqlchemy.NewMigrator(db)
+	err = m.Register(migrations[0], sqlchemy.SMigration{
+		Version: 2,
+		Name:    "seed data",
+		Migrate: func(ctx context.Context, db *sqlchemy.SDatabase) error {
+			_, err := db.Exec("INSERT INTO `migration_table` (`id`, `name`) VALUES (1, 'seed')")
+			return err
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register fail: %s", err)
+	}
+	err = m.Register(sqlchemy.SMigration{Version: 2})
+	if errors.Cause(err) != sqlchemy.ErrDuplicateEntry {
+		t.Errorf("duplicate version should fail, got %v", err)
+	}
+
+	err = m.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("Migrate fail: %s", err)
+	}
+	if !ts.Exists() {
+		t.Errorf("table should be created by migration")
+	}
+	applied, err := m.Applied()
+	if err != nil {
+		t.Fatalf("Applied fail: %s", err)
+	}
+	if len(applied) != 2 || applied[0].Version != 1 || applied[1].Version != 2 || applied[1].AppliedAt.IsZero() {
+		t.Errorf("unexpected applied migrations %#v", applied)
+	}
+
+	// applying again is a no-op
+	err = m.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("Migrate again fail: %s", err)
+	}
+	cnt, err := ts.Query().CountWithError()
+	if err != nil {
+		t.Fatalf("count fail: %s", err)
+	}
+	if cnt != 1 {
+		t.Errorf("data migration should run once, got %d rows", cnt)
+	}
+
+	m2 := sqlchemy.NewMigrator(db)
+	m2.Register(sqlchemy.SMigration{Version: 1, Name: "changed", SQLs: []string{"SELECT 1"}})
+	_, err = m2.Pending()
+	if errors.Cause(err) != sqlchemy.ErrMigrationChecksumMismatch {
+		t.Errorf("want ErrMigrationChecksumMismatch got %v", err)
+	}
+}
+
+func TestMigratorLockInsertFail(t *testing.T) {
+	dbConn := openTestDB(t, "migrationlocktest").DB()
+
+	m := sqlchemy.NewMigrator(sqlchemy.GetDefaultDB())
+	// ensure the tables, then make acquiring the lock fail without a holder
+	err := m.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("Migrate fail: %s", err)
+	}
+	_, err = dbConn.Exec("CREATE TRIGGER `lock_fail` BEFORE INSERT ON `" + sqlchemy.MIGRATION_LOCK_TABLE + "` BEGIN SELECT RAISE(ABORT, 'lock table read only'); END")
+	if err != nil {
+		t.Fatalf("create trigger fail: %s", err)
+	}
+	m.LockTimeout = 0
+	err = m.Migrate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "lock table read only") {
+		t.Errorf("Migrate want the insert error got %v", err)
+	}
+}
+
+func TestMigratorLockHeartbeat(t *testing.T) {
+	dbConn := openTestDB(t, "migrationheartbeattest").DB()
+
+	lockedAt := func() string {
+		var at string
+		err := dbConn.QueryRow("SELECT `locked_at` FROM `" + sqlchemy.MIGRATION_LOCK_TABLE + "`").Scan(&at)
+		if err != nil {
+			t.Fatalf("query lock fail: %s", err)
+		}
+		return at
+	}
+	m := sqlchemy.NewMigrator(sqlchemy.GetDefaultDB())
+	m.LockExpire = 300 * time.Millisecond
+	m.Register(sqlchemy.SMigration{
+		Version: 1,
+		Name:    "long migration",
+		Migrate: func(ctx context.Context, db *sqlchemy.SDatabase) error {
+			start := lockedAt()
+			time.Sleep(250 * time.Millisecond)
+			if lockedAt() == start {
+				t.Errorf("the lock should be refreshed while migrating")
+			}
+			// the lock is taken over by another migrator
+			_, err := dbConn.Exec("UPDATE `" + sqlchemy.MIGRATION_LOCK_TABLE + "` SET `owner` = 'other'")
+			return err
+		},
+	})
+	err := m.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("Migrate fail: %s", err)
+	}
+	var owner string
+	err = dbConn.QueryRow("SELECT `owner` FROM `" + sqlchemy.MIGRATION_LOCK_TABLE + "`").Scan(&owner)
+	if err != nil {
+		t.Fatalf("the lock of the other migrator should be kept: %s", err)
+	}
+	if owner != "other" {
+		t.Errorf("unexpected lock owner %s", owner)
+	}
+}
+
+func TestMigratorApplyFail(t *testing.T) {
+	dbConn := openTestDB(t, "migrationfailtest").DB()
+
+	createSQL := "CREATE TABLE `migration_fail_table` (`id` INTEGER PRIMARY KEY)"
+	m := sqlchemy.NewMigrator(sqlchemy.GetDefaultDB())
+	m.Register(sqlchemy.SMigration{
+		Version: 1,
+		Name:    "fail after DDL",
+		SQLs:    []string{createSQL},
+		Migrate: func(ctx context.Context, db *sqlchemy.SDatabase) error {
+			return errors.Error("data migration fail")
+		},
+	})
+	err := m.Migrate(context.Background())
+	if err == nil {
+		t.Fatalf("Migrate should fail")
+	}
+	// the DDL is rollbacked along with the data migration
+	var cnt int
+	err = dbConn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE `name` = 'migration_fail_table'").Scan(&cnt)
+	if err != nil {
+		t.Fatalf("query sqlite_master fail: %s", err)
+	}
+	if cnt != 0 {
+		t.Errorf("the table of the failed migration should be rollbacked")
+	}
+	applied, err := m.Applied()
+	if err != nil {
+		t.Fatalf("Applied fail: %s", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("unexpected applied migrations %#v", applied)
+	}
+
+	// the fixed migration is applied from scratch
+	m = sqlchemy.NewMigrator(sqlchemy.GetDefaultDB())
+	m.Register(sqlchemy.SMigration{Version: 1, Name: "fixed", SQLs: []string{createSQL}})
+	err = m.Migrate(context.Background())
+	if err != nil {
+		t.Fatalf("Migrate fixed migration fail: %s", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestMutationQuery(t *testing.T) {
+	type TableStruct struct {
+		Id    int    `primary:"true"`
+		Name  string `width:"16"`
+		Count int    `nullable:"false" default:"0"`
+	}
+	openTestDB(t, "mutationtest")
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "mutation_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	for i, name := range []string{"alpha", "beta", "gamma", "delta"} {
+		err := ts.Insert(&TableStruct{Id: i + 1, Name: name, Count: i})
+		if err != nil {
+			t.Fatalf("insert fail: %s", err)
+		}
+	}
+
+	uq := ts.UpdateQuery()
+	uq.SetField("count", sqlchemy.ADD("", uq.Field("count"), sqlchemy.NewConstField(10)))
+	uq.SetField("name", sqlchemy.NewFunction(sqlchemy.NewCase().When(sqlchemy.Equals(uq.Field("id"), 1), sqlchemy.NewStringField("first")).Else(uq.Field("name")), "", false))
+	uq.Filter(sqlchemy.OR(sqlchemy.Equals(uq.Field("id"), 1), sqlchemy.Like(uq.Field("name"), "g%")))
+	cnt, err := uq.Exec()
+	if err != nil {
+		t.Fatalf("update fail: %s", err)
+	}
+	if cnt != 2 {
+		t.Errorf("want 2 rows updated got %d", cnt)
+	}
+	row := TableStruct{Id: 1}
+	err = ts.Fetch(&row)
+	if err != nil {
+		t.Fatalf("fetch fail: %s", err)
+	}
+	if row.Name != "first" || row.Count != 10 {
+		t.Errorf("unexpected updated row %#v", row)
+	}
+
+	_, err = ts.UpdateQuery().Set("no_such_column", 1).Exec()
+	if err == nil {
+		t.Errorf("update unknown column should fail")
+	}
+
+	dq := ts.DeleteQuery()
+	dq.Filter(sqlchemy.NOT(sqlchemy.Between(dq.Field("count"), 1, 10)))
+	cnt, err = dq.Exec()
+	if err != nil {
+		t.Fatalf("delete fail: %s", err)
+	}
+	if cnt != 1 {
+		t.Errorf("want 1 row deleted got %d", cnt)
+	}
+
+	cnt, err = ts.DeleteQuery().Exec()
+	if err != nil {
+		t.Fatalf("delete all fail: %s", err)
+	}
+	if cnt != 3 {
+		t.Errorf("want 3 rows deleted got %d", cnt)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+func TestOptimisticLock(t *testing.T) {
+	type LockStruct struct {
+		Id      int    `primary:"true"`
+		Name    string `width:"16"`
+		Version int    `auto_version:"true" optimistic_lock:"true"`
+	}
+	openTestDB(t, "optlocktest")
+	ts := sqlchemy.NewTableSpecFromStruct(LockStruct{}, "optlock_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	err = ts.Insert(&LockStruct{Id: 1, Name: "init"})
+	if err != nil {
+		t.Fatalf("Insert fail: %s", err)
+	}
+
+	first := LockStruct{}
+	second := LockStruct{}
+	for _, rec := range []*LockStruct{&first, &second} {
+		err = ts.Query().Equals("id", 1).First(rec)
+		if err != nil {
+			t.Fatalf("First fail: %s", err)
+		}
+	}
+	_, err = ts.Update(&first, func() error {
+		first.Name = "first"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update fail: %s", err)
+	}
+	if first.Version != 1 {
+		t.Errorf("version after update want 1 got %d", first.Version)
+	}
+	_, err = ts.Update(&second, func() error {
+		second.Name = "second"
+		return nil
+	})
+	if errors.Cause(err) != sqlchemy.ErrConcurrentModification {
+		t.Errorf("stale update want ErrConcurrentModification got %v", err)
+	}
+	err = ts.UpdateFields(&second, map[string]interface{}{"name": "second"})
+	if errors.Cause(err) != sqlchemy.ErrConcurrentModification {
+		t.Errorf("stale UpdateFields want ErrConcurrentModification got %v", err)
+	}
+
+	err = ts.UpdateFields(&first, map[string]interface{}{"name": "again"})
+	if err != nil {
+		t.Fatalf("UpdateFields fail: %s", err)
+	}
+	if first.Name != "again" || first.Version != 2 {
+		t.Errorf("want again/2 got %s/%d", first.Name, first.Version)
+	}
+}
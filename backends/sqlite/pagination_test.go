@@ -0,0 +1,81 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestPageAfter(t *testing.T) {
+	type TableStruct struct {
+		Id    int    `primary:"true"`
+		Group string `width:"16" nullable:"false"`
+	}
+	openTestDB(t, "pagetest")
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "page_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	for i := 1; i <= 7; i++ {
+		err := ts.Insert(&TableStruct{Id: i, Group: fmt.Sprintf("g%d", i%2)})
+		if err != nil {
+			t.Fatalf("insert fail: %s", err)
+		}
+	}
+
+	// ORDER BY group ASC, id DESC: g0: 6, 4, 2; g1: 7, 5, 3, 1
+	want := [][]int{{6, 4, 2}, {7, 5, 3}, {1}, {}}
+	var cursor *sqlchemy.SPageCursor
+	for page := range want {
+		if cursor != nil {
+			// cursor should survive encoding
+			cursor, err = sqlchemy.DecodePageCursor(cursor.Encode())
+			if err != nil {
+				t.Fatalf("decode cursor fail: %s", err)
+			}
+		}
+		tbl := ts.Instance()
+		q := tbl.Query(tbl.Field("id")).Asc("group").Desc("id").Limit(3)
+		q, err = q.PageAfter(cursor)
+		if err != nil {
+			t.Fatalf("PageAfter fail: %s", err)
+		}
+		rows := make([]TableStruct, 0)
+		err = q.All(&rows)
+		if err != nil {
+			t.Fatalf("query page %d fail: %s", page, err)
+		}
+		got := make([]int, 0, len(rows))
+		for i := range rows {
+			got = append(got, rows[i].Id)
+		}
+		if fmt.Sprintf("%v", got) != fmt.Sprintf("%v", want[page]) {
+			t.Fatalf("page %d want %v got %v", page, want[page], got)
+		}
+		cursor, err = q.NextPageCursor()
+		if err != nil {
+			t.Fatalf("NextPageCursor fail: %s", err)
+		}
+	}
+	if cursor != nil {
+		t.Errorf("cursor should be nil after the last page")
+	}
+}
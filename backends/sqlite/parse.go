@@ -15,21 +15,9 @@
 package sqlite
 
 import (
-	"regexp"
-
-	"yunion.io/x/pkg/errors"
-
 	"yunion.io/x/sqlchemy"
 )
 
-const (
-	indexPattern = `CREATE\s+INDEX\s+` + "`" + `(?P<name>\w+)` + "`" + `\s+ON\s+` + "`" + `(?P<tblname>\w+)` + "`" + `\s*\((?P<cols>` + "`" + `\w+` + "`" + `(,\s*` + "`" + `\w+` + "`" + `)*)\)`
-)
-
-var (
-	indexRegexp = regexp.MustCompile(indexPattern)
-)
-
 type sSqliteTableInfo struct {
 	Type string
 	Name string
@@ -37,9 +25,33 @@ type sSqliteTableInfo struct {
 }
 
 func (ti *sSqliteTableInfo) parseTableIndex(ts sqlchemy.ITableSpec) (sqlchemy.STableIndex, error) {
-	matches := indexRegexp.FindAllStringSubmatch(ti.Sql, -1)
-	if len(matches) > 0 {
-		return sqlchemy.NewTableIndex(ts, matches[0][1], sqlchemy.FetchColumns(matches[0][3]), false), nil
+	return sqlchemy.ParseCreateIndexSQL(ts, ti.Sql)
+}
+
+type sSqliteForeignKeyInfo struct {
+	Id       int
+	Seq      int
+	Table    string
+	From     string
+	To       string
+	OnUpdate string `json:"on_update"`
+	OnDelete string `json:"on_delete"`
+}
+
+// parseForeignKeys groups the rows of PRAGMA foreign_key_list by id into constraints,
+// sqlite keeps no names of foreign keys
+func parseForeignKeys(infos []sSqliteForeignKeyInfo) []sqlchemy.STableConstraint {
+	ret := make([]sqlchemy.STableConstraint, 0)
+	for i := 0; i < len(infos); {
+		cols := make([]string, 0)
+		fcols := make([]string, 0)
+		j := i
+		for ; j < len(infos) && infos[j].Id == infos[i].Id; j++ {
+			cols = append(cols, infos[j].From)
+			fcols = append(fcols, infos[j].To)
+		}
+		ret = append(ret, sqlchemy.NewTableForeignKey(nil, "", cols, infos[i].Table, fcols, infos[i].OnDelete, infos[i].OnUpdate))
+		i = j
 	}
-	return sqlchemy.STableIndex{}, errors.ErrNotFound
+	return ret
 }
@@ -37,3 +37,23 @@ func TestParseTableIndex(t *testing.T) {
 		}
 	}
 }
+
+func TestParseForeignKeys(t *testing.T) {
+	infos := []sSqliteForeignKeyInfo{
+		{Id: 0, Seq: 0, Table: "groups", From: "group_id", To: "id", OnUpdate: "NO ACTION", OnDelete: "CASCADE"},
+		{Id: 1, Seq: 0, Table: "users", From: "owner_domain", To: "domain", OnUpdate: "NO ACTION", OnDelete: "NO ACTION"},
+		{Id: 1, Seq: 1, Table: "users", From: "owner_id", To: "id", OnUpdate: "NO ACTION", OnDelete: "NO ACTION"},
+	}
+	fks := parseForeignKeys(infos)
+	if len(fks) != 2 {
+		t.Fatalf("want 2 foreign keys, got %d", len(fks))
+	}
+	want := "CONSTRAINT `` FOREIGN KEY (`group_id`) REFERENCES `groups` (`id`) ON DELETE CASCADE"
+	if got := fks[0].DefinitionString("`"); got != want {
+		t.Errorf("want: %s != got: %s", want, got)
+	}
+	want = "CONSTRAINT `` FOREIGN KEY (`owner_domain`, `owner_id`) REFERENCES `users` (`domain`, `id`)"
+	if got := fks[1].DefinitionString("`"); got != want {
+		t.Errorf("want: %s != got: %s", want, got)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestPlanSync(t *testing.T) {
+	type OldStruct struct {
+		Id    int    `primary:"true"`
+		Name  string `width:"64" charset:"utf8"`
+		Descr string `width:"128" charset:"utf8"`
+	}
+	type NewStruct struct {
+		Id     int    `primary:"true"`
+		Name   string `width:"64" charset:"utf8"`
+		Status string `width:"36" charset:"ascii" default:"init"`
+	}
+	db := openTestDB(t, "plansynctest")
+
+	oldTs := sqlchemy.NewTableSpecFromStruct(OldStruct{}, "plansync_table")
+	plan, err := db.PlanSync(oldTs)
+	if err != nil {
+		t.Fatalf("PlanSync fail: %s", err)
+	}
+	if len(plan.Tables) != 1 || !plan.Tables[0].Create || plan.IsDestructive() {
+		t.Errorf("plan of a new table should create it: %s", plan.JSON())
+	}
+	err = oldTs.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+
+	newTs := sqlchemy.NewTableSpecFromStruct(NewStruct{}, "plansync_table")
+	plan, err = db.PlanSync(newTs)
+	if err != nil {
+		t.Fatalf("PlanSync fail: %s", err)
+	}
+	tp := plan.Tables[0]
+	if tp.Create {
+		t.Errorf("existing table should not be created")
+	}
+	if len(tp.AddedColumns) != 1 || tp.AddedColumns[0].Column != "status" {
+		t.Errorf("want added column status, got %#v", tp.AddedColumns)
+	}
+	// the removed column is kept by default
+	if len(tp.RemovedColumns) != 1 || tp.RemovedColumns[0].Column != "descr" || tp.RemovedColumns[0].Destructive {
+		t.Errorf("want kept removed column descr, got %#v", tp.RemovedColumns)
+	}
+	if !plan.HasChanges() || plan.IsDestructive() || len(tp.SQLs) == 0 {
+		t.Errorf("plan should have non-destructive changes: %s", plan.JSON())
+	}
+	if !strings.Contains(plan.String(), "kept, dropping is not allowed") {
+		t.Errorf("unexpected plan text: %s", plan.String())
+	}
+
+	plan, err = db.PlanSyncWithOptions(sqlchemy.SSyncOptions{AllowDrop: true}, newTs)
+	if err != nil {
+		t.Fatalf("PlanSyncWithOptions fail: %s", err)
+	}
+	tp = plan.Tables[0]
+	if len(tp.RemovedColumns) != 1 || tp.RemovedColumns[0].Column != "descr" || !tp.RemovedColumns[0].Destructive {
+		t.Errorf("want destructive removed column descr, got %#v", tp.RemovedColumns)
+	}
+	if !plan.HasChanges() || !plan.IsDestructive() || len(tp.SQLs) == 0 {
+		t.Errorf("plan should have destructive changes: %s", plan.JSON())
+	}
+	if !strings.Contains(plan.String(), "- column `descr`") {
+		t.Errorf("unexpected plan text: %s", plan.String())
+	}
+	if !strings.Contains(plan.JSON().String(), `"table":"plansync_table"`) {
+		t.Errorf("unexpected plan json: %s", plan.JSON())
+	}
+}
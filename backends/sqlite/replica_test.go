@@ -0,0 +1,101 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestReadReplicas(t *testing.T) {
+	type ReplicaStruct struct {
+		Id   int    `primary:"true"`
+		Name string `width:"16"`
+	}
+	const replicaDB = sqlchemy.DBName("replica")
+	// prepare the replica with a row not in the primary, to tell where the reads go
+	replicaConn := openTestDB(t, "replicareplica").DB()
+	sqlchemy.SetDBWithNameBackend(replicaConn, replicaDB, sqlchemy.SQLiteBackend)
+	replicaTs := sqlchemy.NewTableSpecFromStructWithDBName(ReplicaStruct{}, "replica_table", replicaDB)
+	err := replicaTs.Sync()
+	if err != nil {
+		t.Fatalf("Sync replica fail: %s", err)
+	}
+	err = replicaTs.Insert(&ReplicaStruct{Id: 1, Name: "replica"})
+	if err != nil {
+		t.Fatalf("Insert replica fail: %s", err)
+	}
+
+	db := openTestDB(t, "replicaprimary")
+	ts := sqlchemy.NewTableSpecFromStructWithDBName(ReplicaStruct{}, "replica_table", sqlchemy.DefaultDB)
+	err = ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	err = ts.Insert(&ReplicaStruct{Id: 1, Name: "primary"})
+	if err != nil {
+		t.Fatalf("Insert fail: %s", err)
+	}
+
+	readName := func(q *sqlchemy.SQuery) string {
+		rec := ReplicaStruct{}
+		err := q.Equals("id", 1).First(&rec)
+		if err != nil {
+			t.Fatalf("First fail: %s", err)
+		}
+		return rec.Name
+	}
+
+	db.SetReplicas([]*sql.DB{replicaConn}, nil)
+	if got := readName(ts.Query()); got != "replica" {
+		t.Errorf("read want replica got %s", got)
+	}
+	if got := readName(ts.Query().UsePrimary()); got != "primary" {
+		t.Errorf("pinned read want primary got %s", got)
+	}
+	err = db.RunInTx(context.Background(), func(tx *sqlchemy.STx) error {
+		rec := ReplicaStruct{}
+		err := tx.Database().NewRawQuery("SELECT `id`, `name` FROM `replica_table` WHERE `id` = 1", "id", "name").First(&rec)
+		if err != nil {
+			return err
+		}
+		if rec.Name != "primary" {
+			t.Errorf("read in transaction want primary got %s", rec.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTx fail: %s", err)
+	}
+
+	db.SetReplicas([]*sql.DB{replicaConn}, &sqlchemy.SReplicaOptions{StickyWindow: time.Hour})
+	if got := readName(ts.Query()); got != "replica" {
+		t.Errorf("read before write want replica got %s", got)
+	}
+	err = ts.Insert(&ReplicaStruct{Id: 2, Name: "write"})
+	if err != nil {
+		t.Fatalf("Insert fail: %s", err)
+	}
+	if got := readName(ts.Query()); got != "primary" {
+		t.Errorf("read after write want primary got %s", got)
+	}
+	db.SetReplicas(nil, nil)
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+type retryCounter struct {
+	retries []int
+}
+
+func (c *retryCounter) BeforeQuery(ctx context.Context, event *sqlchemy.SQueryEvent) context.Context {
+	return ctx
+}
+
+func (c *retryCounter) AfterQuery(ctx context.Context, event *sqlchemy.SQueryEvent) {
+}
+
+func (c *retryCounter) OnRetry(ctx context.Context, event *sqlchemy.SRetryEvent) {
+	c.retries = append(c.retries, event.Attempt)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	type RetryStruct struct {
+		Id   int    `primary:"true"`
+		Name string `width:"16"`
+	}
+	db := openTestDB(t, "retrytest")
+	ts := sqlchemy.NewTableSpecFromStruct(RetryStruct{}, "retry_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	counter := &retryCounter{}
+	db.AddInterceptor(counter)
+	policy := sqlchemy.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	db.SetRetryPolicy(policy)
+	defer db.SetRetryPolicy(nil)
+
+	t.Run("transaction closure", func(t *testing.T) {
+		counter.retries = nil
+		calls := 0
+		err := db.RunInTx(context.Background(), func(tx *sqlchemy.STx) error {
+			calls++
+			_, err := tx.Database().Exec("INSERT INTO `retry_table` (`id`, `name`) VALUES (?, ?)", calls, "tx")
+			if err != nil {
+				return err
+			}
+			if calls < 3 {
+				return errors.Wrap(sqlchemy.ErrDeadlock, "simulated")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RunInTx fail: %s", err)
+		}
+		if calls != 3 || len(counter.retries) != 2 {
+			t.Errorf("want 3 calls and 2 retries, got %d calls and retries %v", calls, counter.retries)
+		}
+		cnt, _ := ts.Query().CountWithError()
+		if cnt != 1 {
+			t.Errorf("only the last attempt should be committed, got %d rows", cnt)
+		}
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		counter.retries = nil
+		calls := 0
+		err := db.RunInTx(context.Background(), func(tx *sqlchemy.STx) error {
+			calls++
+			return sqlchemy.ErrLockTimeout
+		})
+		if errors.Cause(err) != sqlchemy.ErrLockTimeout {
+			t.Errorf("want ErrLockTimeout got %v", err)
+		}
+		if calls != policy.MaxAttempts {
+			t.Errorf("want %d attempts got %d", policy.MaxAttempts, calls)
+		}
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		counter.retries = nil
+		err := ts.Insert(&RetryStruct{Id: 3, Name: "dup"})
+		if !stderrors.Is(err, sqlchemy.ErrDuplicateEntry) {
+			t.Errorf("want ErrDuplicateEntry got %v", err)
+		}
+		if len(counter.retries) != 0 {
+			t.Errorf("duplicate entry should not be retried, got retries %v", counter.retries)
+		}
+	})
+
+	t.Run("locked table", func(t *testing.T) {
+		counter.retries = nil
+		lockTx, err := db.DB().Begin()
+		if err != nil {
+			t.Fatalf("Begin fail: %s", err)
+		}
+		_, err = lockTx.Exec("INSERT INTO `retry_table` (`id`, `name`) VALUES (10, 'lock')")
+		if err != nil {
+			t.Fatalf("Exec fail: %s", err)
+		}
+		err = ts.Insert(&RetryStruct{Id: 11, Name: "locked"})
+		lockTx.Rollback()
+		if !stderrors.Is(err, sqlchemy.ErrLockTimeout) {
+			t.Errorf("want ErrLockTimeout got %v", err)
+		}
+		if len(counter.retries) != policy.MaxAttempts-1 {
+			t.Errorf("want %d retries got %v", policy.MaxAttempts-1, counter.retries)
+		}
+		err = ts.Insert(&RetryStruct{Id: 11, Name: "unlocked"})
+		if err != nil {
+			t.Errorf("Insert after unlock fail: %s", err)
+		}
+	})
+}
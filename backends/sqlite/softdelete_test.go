@@ -0,0 +1,94 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+func TestSoftDelete(t *testing.T) {
+	type TableStruct struct {
+		Id        int       `primary:"true"`
+		Name      string    `width:"16"`
+		Deleted   bool      `nullable:"false" default:"false" soft_delete:"true"`
+		DeletedAt time.Time `nullable:"true" soft_delete:"true"`
+	}
+	type PlainStruct struct {
+		Id int `primary:"true"`
+	}
+	openTestDB(t, "softdeletetest")
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "softdelete_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	if !ts.IsSoftDeletable() {
+		t.Fatalf("table should be soft-deletable")
+	}
+	for i := 1; i <= 3; i++ {
+		err := ts.Insert(&TableStruct{Id: i, Name: "row"})
+		if err != nil {
+			t.Fatalf("insert fail: %s", err)
+		}
+	}
+
+	row := TableStruct{Id: 2}
+	err = ts.Fetch(&row)
+	if err != nil {
+		t.Fatalf("fetch fail: %s", err)
+	}
+	err = ts.SoftDelete(&row)
+	if err != nil {
+		t.Fatalf("soft delete fail: %s", err)
+	}
+	if !row.Deleted || row.DeletedAt.IsZero() {
+		t.Errorf("soft delete should set deleted and deleted_at: %#v", row)
+	}
+
+	cnt, err := ts.Query().CountWithError()
+	if err != nil {
+		t.Fatalf("count fail: %s", err)
+	}
+	if cnt != 2 {
+		t.Errorf("want 2 rows got %d", cnt)
+	}
+	cnt, err = ts.Query().Unscoped().CountWithError()
+	if err != nil {
+		t.Fatalf("count unscoped fail: %s", err)
+	}
+	if cnt != 3 {
+		t.Errorf("want 3 rows unscoped got %d", cnt)
+	}
+	rows := make([]TableStruct, 0)
+	err = ts.Query().Equals("name", "row").Asc("id").All(&rows)
+	if err != nil {
+		t.Fatalf("query fail: %s", err)
+	}
+	if len(rows) != 2 || rows[0].Id != 1 || rows[1].Id != 3 {
+		t.Errorf("want rows 1 and 3, got %#v", rows)
+	}
+
+	plain := sqlchemy.NewTableSpecFromStruct(PlainStruct{}, "plain_table")
+	err = plain.SoftDelete(&PlainStruct{Id: 1})
+	if errors.Cause(err) != sqlchemy.ErrNotSoftDeletable {
+		t.Errorf("want ErrNotSoftDeletable got %v", err)
+	}
+}
@@ -17,7 +17,6 @@ package sqlite
 import (
 	"fmt"
 	"reflect"
-	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 
@@ -55,6 +54,21 @@ func (sqlite *SSqliteBackend) CanInsertOrUpdate() bool {
 	return true
 }
 
+// CanSupportSavepoint returns wether the backend supports SAVEPOINT for nested transactions
+func (sqlite *SSqliteBackend) CanSupportSavepoint() bool {
+	return true
+}
+
+// CanSupportTransactionalDDL returns wether DDL statements can be rollbacked in a transaction
+func (sqlite *SSqliteBackend) CanSupportTransactionalDDL() bool {
+	return true
+}
+
+// CanSupportCTE returns wether the backend supports common table expressions
+func (sqlite *SSqliteBackend) CanSupportCTE() bool {
+	return true
+}
+
 func (sqlite *SSqliteBackend) CurrentUTCTimeStampString() string {
 	return "DATETIME('now')"
 }
@@ -71,6 +85,10 @@ func (sqlite *SSqliteBackend) InsertOrUpdateSQLTemplate() string {
 	return "INSERT INTO `{{ .Table }}` ({{ .Columns }}) VALUES ({{ .Values }}) ON CONFLICT({{ .PrimaryKeys }}) DO UPDATE SET {{ .SetValues }}"
 }
 
+func (sqlite *SSqliteBackend) InsertOrUpdateBatchSQLTemplate() string {
+	return "INSERT INTO `{{ .Table }}` ({{ .Columns }}) VALUES {{ .Values }} ON CONFLICT({{ .PrimaryKeys }}) DO UPDATE SET {{ range $i, $c := .UpdateColumns }}{{ if $i }}, {{ end }}`{{ $c }}` = excluded.`{{ $c }}`{{ end }}{{ range $i, $c := .VersionColumns }}{{ if or $i $.UpdateColumns }}, {{ end }}`{{ $c }}` = `{{ $c }}` + 1{{ end }}"
+}
+
 func (sqlite *SSqliteBackend) GetTableSQL() string {
 	return "SELECT name FROM sqlite_master WHERE type='table'"
 }
@@ -79,21 +97,18 @@ func (sqlite *SSqliteBackend) IsSupportIndexAndContraints() bool {
 	return true
 }
 
-func (sqlite *SSqliteBackend) GetCreateSQLs(ts sqlchemy.ITableSpec) []string {
-	cols := make([]string, 0)
-	primaries := make([]string, 0)
-	for _, c := range ts.Columns() {
-		cols = append(cols, c.DefinitionString())
-		if c.IsPrimary() && !c.IsAutoIncrement() {
-			primaries = append(primaries, fmt.Sprintf("`%s`", c.Name()))
-		}
-	}
-	if len(primaries) > 0 {
-		cols = append(cols, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaries, ", ")))
+func (sqlite *SSqliteBackend) IsSupportIndexFeature(ts sqlchemy.ITableSpec, feature sqlchemy.TIndexFeature) bool {
+	switch feature {
+	case sqlchemy.INDEX_FEATURE_DESC, sqlchemy.INDEX_FEATURE_EXPRESSION, sqlchemy.INDEX_FEATURE_PARTIAL:
+		return true
 	}
+	return false
+}
+
+func (sqlite *SSqliteBackend) GetCreateSQLs(ts sqlchemy.ITableSpec) []string {
 	ret := []string{
 		"PRAGMA encoding=\"UTF-8\"",
-		fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n%s\n)", ts.Name(), strings.Join(cols, ",\n")),
+		createTableSQL(ts.Name(), ts.Columns(), ts.ForeignKeys(), true),
 	}
 	for _, idx := range ts.Indexes() {
 		ret = append(ret, createIndexSQL(ts, idx))
@@ -117,7 +132,14 @@ func (sqlite *SSqliteBackend) FetchIndexesAndConstraints(ts sqlchemy.ITableSpec)
 		}
 		indexes = append(indexes, ti)
 	}
-	return indexes, nil, nil
+	sql = fmt.Sprintf("PRAGMA foreign_key_list(`%s`)", ts.Name())
+	query = ts.Database().NewRawQuery(sql, "id", "seq", "table", "from", "to", "on_update", "on_delete")
+	fks := make([]sSqliteForeignKeyInfo, 0)
+	err = query.All(&fks)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Raw Query Scan %s", sql)
+	}
+	return indexes, parseForeignKeys(fks), nil
 }
 
 func (sqlite *SSqliteBackend) FetchTableColumnSpecs(ts sqlchemy.ITableSpec) ([]sqlchemy.IColumnSpec, error) {
@@ -15,123 +15,289 @@
 package sqlite
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
 	"yunion.io/x/sqlchemy"
 )
 
+// CommitTableChangeSQL returns the SQLs to apply the changes. Changes that SQLite
+// cannot alter in place rebuild the table with the procedure of
+// https://www.sqlite.org/lang_altertable.html#otheralter, the statements carry no
+// transaction control so that they can be executed by any connection. Dropping
+// a table referred by other tables fires their ON DELETE actions if foreign keys
+// are enforced, such changes should be applied by Sync
 func (sqlite *SSqliteBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges) []string {
-	ret := make([]string, 0)
+	if !needNewTable(changes) {
+		return alterTableSQLs(ts, changes)
+	}
+	var schemas []sSqliteTableInfo
+	if db := ts.Database().DB(); db != nil {
+		var err error
+		schemas, err = fetchDependentSchemas(context.Background(), db, ts.Name())
+		if err != nil {
+			log.Errorf("fetchDependentSchemas %s fail: %s", ts.Name(), err)
+		}
+	}
+	return rebuildTableSQLs(ts, changes, schemas)
+}
 
-	for _, idx := range changes.RemoveIndexes {
-		sql := fmt.Sprintf("DROP INDEX IF EXISTS `%s`.`%s`", ts.Name(), idx.Name())
-		ret = append(ret, sql)
-		log.Infof("%s;", sql)
+// CommitTableChanges applies the changes, rebuilding the table in a transaction
+// with foreign keys disabled on a single connection when needed
+func (sqlite *SSqliteBackend) CommitTableChanges(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges, opts sqlchemy.SSyncOptions) error {
+	if !needNewTable(changes) {
+		return sqlite.SBaseBackend.CommitTableChanges(ts, changes, opts)
+	}
+	ctx := context.Background()
+	conn, err := ts.Database().DB().Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Conn")
 	}
+	defer conn.Close()
 
-	needNewTable := false
+	// PRAGMA foreign_keys is a no-op inside a transaction
+	fkEnabled := 0
+	err = conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&fkEnabled)
+	if err != nil {
+		return errors.Wrap(err, "PRAGMA foreign_keys")
+	}
+	if fkEnabled != 0 {
+		_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+		if err != nil {
+			return errors.Wrap(err, "disable foreign_keys")
+		}
+		defer func() {
+			_, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+			if err != nil {
+				log.Errorf("enable foreign_keys fail: %s", err)
+			}
+		}()
+	}
 
-	// first check if primary key is modifed
-	changePrimary := false
-	oldHasPrimary := false
-	for _, col := range changes.RemoveColumns {
-		if col.IsPrimary() {
-			changePrimary = true
-			oldHasPrimary = true
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "BeginTx")
+	}
+	err = rebuildTable(ctx, tx, ts, changes, opts)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "rebuild table %s", ts.Name())
+	}
+	return errors.Wrap(tx.Commit(), "Commit")
+}
+
+func rebuildTable(ctx context.Context, tx *sql.Tx, ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges, opts sqlchemy.SSyncOptions) error {
+	schemas, err := fetchDependentSchemas(ctx, tx, ts.Name())
+	if err != nil {
+		return errors.Wrap(err, "fetchDependentSchemas")
+	}
+	for _, sql := range rebuildTableSQLs(ts, changes, schemas) {
+		opts.Report(sqlchemy.SSyncProgress{Table: ts.Name(), Stage: sqlchemy.SYNC_STAGE_ALTER, SQL: sql})
+		_, err := tx.ExecContext(ctx, sql)
+		if err != nil {
+			return errors.Wrapf(err, "exec %s", sql)
 		}
 	}
-	for _, cols := range changes.UpdatedColumns {
-		if cols.OldCol.IsPrimary() != cols.NewCol.IsPrimary() {
-			changePrimary = true
+
+	// check the whole database, as the tables referring to the table may be violated as well
+	sql := "PRAGMA foreign_key_check"
+	rows, err := tx.QueryContext(ctx, sql)
+	if err != nil {
+		return errors.Wrap(err, sql)
+	}
+	defer rows.Close()
+	violations := 0
+	for rows.Next() {
+		violations++
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, sql)
+	}
+	if violations > 0 {
+		return errors.Wrapf(sqlchemy.ErrForeignKeyViolation, "%d rows violate the foreign keys", violations)
+	}
+	return nil
+}
+
+type sQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// fetchDependentSchemas returns the views and triggers referring to the table,
+// views before triggers as triggers may be defined on the views
+func fetchDependentSchemas(ctx context.Context, db sQueryer, table string) ([]sSqliteTableInfo, error) {
+	sql := "SELECT `type`, `name`, `tbl_name`, `sql` FROM `sqlite_master` WHERE `type` IN ('view', 'trigger') AND (`tbl_name` = ? OR `sql` LIKE ?) ORDER BY `type` DESC"
+	rows, err := db.QueryContext(ctx, sql, table, "%"+table+"%")
+	if err != nil {
+		return nil, errors.Wrap(err, "Query")
+	}
+	defer rows.Close()
+	// the table name must appear as a whole identifier, not as a part of another name
+	ident := regexp.MustCompile(`(?i)(^|[^\w$])` + regexp.QuoteMeta(table) + `([^\w$]|$)`)
+	ret := make([]sSqliteTableInfo, 0)
+	for rows.Next() {
+		schema := sSqliteTableInfo{}
+		tblName := ""
+		err := rows.Scan(&schema.Type, &schema.Name, &tblName, &schema.Sql)
+		if err != nil {
+			return nil, errors.Wrap(err, "Scan")
 		}
-		if cols.OldCol.IsPrimary() {
-			oldHasPrimary = true
+		if tblName != table && !ident.MatchString(schema.Sql) {
+			continue
 		}
+		ret = append(ret, schema)
 	}
+	return ret, rows.Err()
+}
+
+func needNewTable(changes sqlchemy.STableChanges) bool {
+	// any change of the primary key
 	for _, col := range changes.AddColumns {
 		if col.IsPrimary() {
-			changePrimary = true
+			return true
 		}
 	}
-	if changePrimary && oldHasPrimary {
-		needNewTable = true
-	}
-	/* IGNORE DROP STATEMENT */
 	for _, col := range changes.RemoveColumns {
-		sql := fmt.Sprintf("DROP COLUMN `%s`", col.Name())
-		log.Debugf("skip ALTER TABLE %s %s;", ts.Name(), sql)
-		// alters = append(alters, sql)
-		// ignore drop statement
-		// if the column is auto_increment integer column,
-		// then need to drop auto_increment attribute
-		if col.IsAutoIncrement() {
-			needNewTable = true
+		if col.IsPrimary() {
+			return true
 		}
-		// if the column is not nullable but no default
-		// then need to drop the not-nullable attribute
-		if !col.IsNullable() && col.Default() == "" {
-			needNewTable = true
+		// an auto_increment column cannot be kept once dropped, and a not-nullable
+		// column without default blocks inserting, both need dropping the attribute
+		if col.IsAutoIncrement() || (!col.IsNullable() && col.Default() == "") {
+			return true
 		}
 	}
-	if len(changes.UpdatedColumns) > 0 {
-		needNewTable = true
+	if len(changes.UpdatedColumns) > 0 || len(changes.DropColumns) > 0 {
+		return true
+	}
+	// sqlite cannot alter the foreign keys of an existing table
+	if len(changes.RemoveConstraints) > 0 || len(changes.AddConstraints) > 0 {
+		return true
+	}
+	return false
+}
+
+func alterTableSQLs(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges) []string {
+	ret := make([]string, 0)
+	for _, idx := range changes.RemoveIndexes {
+		sql := fmt.Sprintf("DROP INDEX IF EXISTS `%s`.`%s`", ts.Name(), idx.Name())
+		ret = append(ret, sql)
+		log.Infof("%s;", sql)
+	}
+	/* IGNORE DROP STATEMENT */
+	for _, col := range changes.RemoveColumns {
+		log.Debugf("skip ALTER TABLE %s DROP COLUMN `%s`;", ts.Name(), col.Name())
 	}
 	for _, col := range changes.AddColumns {
 		sql := fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN %s", ts.Name(), col.DefinitionString())
 		ret = append(ret, sql)
 	}
-	if changePrimary {
-		needNewTable = true
+	for _, idx := range changes.AddIndexes {
+		sql := createIndexSQL(ts, idx)
+		ret = append(ret, sql)
+		log.Infof("%s;", sql)
 	}
+	return ret
+}
 
-	if needNewTable {
-		newTableName := fmt.Sprintf("%s_tmp", ts.Name())
-		oldTableName := fmt.Sprintf("%s_old", ts.Name())
-		// create a table with alter name
-		// var newTable *sqlchemy.STableSpec
-		newTable := ts.(*sqlchemy.STableSpec).Clone(newTableName, 0)
-		createSqls := newTable.CreateSQLs()
-		ret = append(ret, createSqls...)
-		// insert
-		colNameMap := make(map[string]string)
+// rebuildTableSQLs creates the new table, copies the rows, replaces the original
+// table and recreates all indexes of the table spec, as well as the dependent
+// triggers and views, which are dropped before the original table
+func rebuildTableSQLs(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges, schemas []sSqliteTableInfo) []string {
+	newTableName := fmt.Sprintf("%s_tmp", ts.Name())
 
-		for _, cols := range changes.UpdatedColumns {
-			if cols.OldCol.Name() != cols.NewCol.Name() {
-				colNameMap[cols.NewCol.Name()] = cols.OldCol.Name()
-			}
+	colNameMap := make(map[string]string)
+	for _, cols := range changes.UpdatedColumns {
+		if cols.OldCol.Name() != cols.NewCol.Name() {
+			colNameMap[cols.NewCol.Name()] = cols.OldCol.Name()
+		}
+	}
+	addedCols := make(map[string]bool)
+	for _, col := range changes.AddColumns {
+		addedCols[col.Name()] = true
+	}
 
+	columns := make([]sqlchemy.IColumnSpec, 0)
+	colNames := make([]string, 0)
+	srcCols := make([]string, 0)
+	for _, col := range ts.Columns() {
+		columns = append(columns, col)
+		if addedCols[col.Name()] {
+			continue
 		}
-		colNames := make([]string, 0)
-		srcCols := make([]string, 0)
-		for _, col := range ts.Columns() {
-			colName := col.Name()
-			srcName := colName
-			if n, ok := colNameMap[colName]; ok {
-				srcName = n
-			}
-			colNames = append(colNames, fmt.Sprintf("`%s`", colName))
-			srcCols = append(srcCols, fmt.Sprintf("`%s`", srcName))
+		srcName := col.Name()
+		if n, ok := colNameMap[srcName]; ok {
+			srcName = n
 		}
-		sql := fmt.Sprintf("INSERT INTO `%s`(%s) SELECT %s FROM `%s`", newTableName, strings.Join(colNames, ", "), strings.Join(srcCols, ", "), ts.Name())
-		ret = append(ret, sql)
-		// change name
-		sql = fmt.Sprintf("ALTER TABLE `%s` RENAME TO `%s`", ts.Name(), oldTableName)
-		ret = append(ret, sql)
-		sql = fmt.Sprintf("ALTER TABLE `%s` RENAME TO `%s`", newTableName, ts.Name())
-		ret = append(ret, sql)
+		colNames = append(colNames, fmt.Sprintf("`%s`", col.Name()))
+		srcCols = append(srcCols, fmt.Sprintf("`%s`", srcName))
 	}
-
-	for _, idx := range changes.AddIndexes {
-		sql := createIndexSQL(ts, idx)
-		ret = append(ret, sql)
-		log.Infof("%s;", sql)
+	// the removed columns are kept as nullable columns unless dropped
+	for _, col := range changes.RemoveColumns {
+		col.SetPrimary(false)
+		col.SetAutoIncrement(false)
+		col.SetNullable(true)
+		columns = append(columns, col)
+		colNames = append(colNames, fmt.Sprintf("`%s`", col.Name()))
+		srcCols = append(srcCols, fmt.Sprintf("`%s`", col.Name()))
 	}
 
+	ret := []string{
+		createTableSQL(newTableName, columns, ts.ForeignKeys(), false),
+		fmt.Sprintf("INSERT INTO `%s`(%s) SELECT %s FROM `%s`", newTableName, strings.Join(colNames, ", "), strings.Join(srcCols, ", "), ts.Name()),
+	}
+	for i := len(schemas) - 1; i >= 0; i-- {
+		ret = append(ret, fmt.Sprintf("DROP %s IF EXISTS `%s`", strings.ToUpper(schemas[i].Type), schemas[i].Name))
+	}
+	ret = append(ret,
+		fmt.Sprintf("DROP TABLE `%s`", ts.Name()),
+		fmt.Sprintf("ALTER TABLE `%s` RENAME TO `%s`", newTableName, ts.Name()),
+	)
+	for _, idx := range ts.Indexes() {
+		ret = append(ret, createIndexSQL(ts, idx))
+	}
+	for i := range schemas {
+		ret = append(ret, schemas[i].Sql)
+	}
 	return ret
 }
 
+func createTableSQL(name string, columns []sqlchemy.IColumnSpec, fks []sqlchemy.STableConstraint, ifNotExists bool) string {
+	cols := make([]string, 0)
+	primaries := make([]string, 0)
+	for _, c := range columns {
+		cols = append(cols, c.DefinitionString())
+		if c.IsPrimary() && !c.IsAutoIncrement() {
+			primaries = append(primaries, fmt.Sprintf("`%s`", c.Name()))
+		}
+	}
+	if len(primaries) > 0 {
+		cols = append(cols, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaries, ", ")))
+	}
+	for _, fk := range fks {
+		cols = append(cols, fk.DefinitionString("`"))
+	}
+	create := "CREATE TABLE"
+	if ifNotExists {
+		create = "CREATE TABLE IF NOT EXISTS"
+	}
+	return fmt.Sprintf("%s `%s` (\n%s\n)", create, name, strings.Join(cols, ",\n"))
+}
+
 func createIndexSQL(ts sqlchemy.ITableSpec, idx sqlchemy.STableIndex) string {
-	return fmt.Sprintf("CREATE INDEX `%s` ON `%s` (%s)", idx.Name(), ts.Name(), strings.Join(idx.QuotedColumns("`"), ","))
+	unique := ""
+	if idx.IsUnique() {
+		unique = "UNIQUE "
+	}
+	sql := fmt.Sprintf("CREATE %sINDEX `%s` ON `%s` (%s)", unique, idx.Name(), ts.Name(), strings.Join(idx.KeyParts("`"), ","))
+	if len(idx.Where()) > 0 {
+		sql += " WHERE " + idx.Where()
+	}
+	return sql
 }
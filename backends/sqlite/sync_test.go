@@ -17,10 +17,13 @@ package sqlite
 import (
 	"database/sql"
 	"reflect"
+	"strings"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
 
+	"yunion.io/x/pkg/errors"
+
 	"yunion.io/x/sqlchemy"
 )
 
@@ -54,11 +57,9 @@ func TestSync(t *testing.T) {
 	backend := &SSqliteBackend{}
 	sqls := backend.CommitTableChangeSQL(ts2, changes)
 	want := []string{
-		"ALTER TABLE `table1` ADD COLUMN `gender` TEXT NOT NULL DEFAULT 'male' COLLATE NOCASE",
-		"PRAGMA encoding=\"UTF-8\"",
-		"CREATE TABLE IF NOT EXISTS `table1_tmp` (\n`age` INTEGER DEFAULT 10,\n`gender` TEXT NOT NULL DEFAULT 'male' COLLATE NOCASE,\n`id` INTEGER PRIMARY KEY NOT NULL,\n`name` TEXT COLLATE NOCASE,\n`project_id` TEXT COLLATE NOCASE\n)",
-		"INSERT INTO `table1_tmp`(`age`, `gender`, `id`, `name`, `project_id`) SELECT `age`, `gender`, `id`, `name`, `tenant_id` FROM `table1`",
-		"ALTER TABLE `table1` RENAME TO `table1_old`",
+		"CREATE TABLE `table1_tmp` (\n`age` INTEGER DEFAULT 10,\n`gender` TEXT NOT NULL DEFAULT 'male' COLLATE NOCASE,\n`id` INTEGER PRIMARY KEY NOT NULL,\n`name` TEXT COLLATE NOCASE,\n`project_id` TEXT COLLATE NOCASE,\n`is_male` INTEGER DEFAULT 1\n)",
+		"INSERT INTO `table1_tmp`(`age`, `id`, `name`, `project_id`, `is_male`) SELECT `age`, `id`, `name`, `tenant_id`, `is_male` FROM `table1`",
+		"DROP TABLE `table1`",
 		"ALTER TABLE `table1_tmp` RENAME TO `table1`",
 	}
 	if !reflect.DeepEqual(sqls, want) {
@@ -66,3 +67,336 @@ func TestSync(t *testing.T) {
 		t.Errorf("Got: %s", sqls)
 	}
 }
+
+func TestSyncRebuildTable(t *testing.T) {
+	type GroupStruct struct {
+		Id int `auto_increment:"true"`
+	}
+	type MemberStructV1 struct {
+		Id      int    `auto_increment:"true"`
+		Email   string `width:"64" index:"true" unique:"true"`
+		GroupId int    `nullable:"true"`
+	}
+	type MemberStructV2 struct {
+		Id      int    `auto_increment:"true"`
+		Email   string `width:"64" index:"true" unique:"true"`
+		GroupId int    `nullable:"true" default:"1" foreign_key:"rb_groups(id)"`
+	}
+	dbConn := openTestDB(t, "rebuildtest").DB()
+	// foreign key enforcement is a per-connection setting of sqlite
+	dbConn.SetMaxOpenConns(1)
+	var err error
+
+	groups := sqlchemy.NewTableSpecFromStruct(GroupStruct{}, "rb_groups")
+	members := sqlchemy.NewTableSpecFromStruct(MemberStructV1{}, "rb_members")
+	for _, ts := range []*sqlchemy.STableSpec{groups, members} {
+		err = ts.Sync()
+		if err != nil {
+			t.Fatalf("Sync %s fail: %s", ts.Name(), err)
+		}
+	}
+	for _, sql := range []string{
+		"INSERT INTO `rb_groups` (`id`) VALUES (1)",
+		"INSERT INTO `rb_members` (`id`, `email`, `group_id`) VALUES (1, 'a@example.com', 1), (2, 'b@example.com', 2)",
+		"CREATE VIEW `rb_member_emails` AS SELECT `email` FROM `rb_members`",
+		"CREATE TRIGGER `rb_members_ins` AFTER INSERT ON `rb_members` BEGIN SELECT 1; END",
+		"PRAGMA foreign_keys = ON",
+	} {
+		_, err = dbConn.Exec(sql)
+		if err != nil {
+			t.Fatalf("exec %s fail: %s", sql, err)
+		}
+	}
+
+	// member 2 refers to a missing group, the rebuild must be rolled back
+	members = sqlchemy.NewTableSpecFromStruct(MemberStructV2{}, "rb_members")
+	err = members.Sync()
+	if errors.Cause(err) != sqlchemy.ErrForeignKeyViolation {
+		t.Fatalf("Sync with violating rows want ErrForeignKeyViolation got %v", err)
+	}
+	if len(members.SyncSQL()) == 0 {
+		t.Errorf("expect the rebuild to be rolled back")
+	}
+
+	_, err = dbConn.Exec("UPDATE `rb_members` SET `group_id` = 1 WHERE `id` = 2")
+	if err != nil {
+		t.Fatalf("fix member fail: %s", err)
+	}
+	err = members.Sync()
+	if err != nil {
+		t.Fatalf("Sync rebuild fail: %s", err)
+	}
+	if sqls := members.SyncSQL(); len(sqls) > 0 {
+		t.Errorf("expect no sync sqls after sync, got %s", sqls)
+	}
+
+	var names []string
+	rows, err := dbConn.Query("SELECT `name` FROM `sqlite_master` WHERE `name` LIKE 'rb_%' ORDER BY `name`")
+	if err != nil {
+		t.Fatalf("query sqlite_master fail: %s", err)
+	}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	rows.Close()
+	want := []string{"rb_groups", "rb_member_emails", "rb_members", "rb_members_ins"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("schema objects want %s got %s", want, names)
+	}
+
+	var fkEnabled int
+	err = dbConn.QueryRow("PRAGMA foreign_keys").Scan(&fkEnabled)
+	if err != nil || fkEnabled != 1 {
+		t.Errorf("foreign_keys want restored to 1 got %d: %v", fkEnabled, err)
+	}
+	cnt, err := members.Query().CountWithError()
+	if err != nil || cnt != 2 {
+		t.Errorf("members after rebuild want 2 got %d: %v", cnt, err)
+	}
+	_, err = dbConn.Exec("INSERT INTO `rb_members` (`email`, `group_id`) VALUES ('a@example.com', 1)")
+	if err == nil {
+		t.Errorf("unique index is not enforced after rebuild")
+	}
+	_, err = dbConn.Exec("INSERT INTO `rb_members` (`email`, `group_id`) VALUES ('c@example.com', 3)")
+	if err == nil {
+		t.Errorf("foreign key is not enforced after rebuild")
+	}
+}
+
+func TestSyncSQLRebuildTable(t *testing.T) {
+	type GroupStruct struct {
+		Id int `auto_increment:"true"`
+	}
+	type MemberStructV1 struct {
+		Id      int    `auto_increment:"true"`
+		Email   string `width:"64" index:"true" unique:"true"`
+		GroupId int    `nullable:"true"`
+	}
+	type MemberStructV2 struct {
+		Id      int    `auto_increment:"true"`
+		Email   string `width:"64" index:"true" unique:"true"`
+		GroupId int    `nullable:"true" default:"1" foreign_key:"ss_groups(id)"`
+	}
+	dbConn := openTestDB(t, "syncsqltest").DB()
+	// foreign key enforcement is a per-connection setting of sqlite
+	dbConn.SetMaxOpenConns(1)
+	var err error
+
+	groups := sqlchemy.NewTableSpecFromStruct(GroupStruct{}, "ss_groups")
+	members := sqlchemy.NewTableSpecFromStruct(MemberStructV1{}, "ss_members")
+	for _, ts := range []*sqlchemy.STableSpec{groups, members} {
+		err = ts.Sync()
+		if err != nil {
+			t.Fatalf("Sync %s fail: %s", ts.Name(), err)
+		}
+	}
+	for _, sql := range []string{
+		"INSERT INTO `ss_groups` (`id`) VALUES (1)",
+		"INSERT INTO `ss_members` (`id`, `email`, `group_id`) VALUES (1, 'a@example.com', 1)",
+		"CREATE TABLE `ss_members_log` (`email` TEXT)",
+		"CREATE VIEW `ss_member_emails` AS SELECT `email` FROM `ss_members`",
+		"CREATE VIEW `ss_members_log_emails` AS SELECT `email` FROM `ss_members_log`",
+		"CREATE TRIGGER `ss_members_ins` AFTER INSERT ON `ss_members` BEGIN INSERT INTO `ss_members_log` VALUES (NEW.`email`); END",
+		"PRAGMA foreign_keys = ON",
+	} {
+		_, err = dbConn.Exec(sql)
+		if err != nil {
+			t.Fatalf("exec %s fail: %s", sql, err)
+		}
+	}
+
+	members = sqlchemy.NewTableSpecFromStruct(MemberStructV2{}, "ss_members")
+	sqls := members.SyncSQL()
+	for _, sql := range sqls {
+		if strings.Contains(sql, "ss_members_log_emails") {
+			t.Errorf("view not referring to the table is rebuilt: %s", sql)
+		}
+		_, err = dbConn.Exec(sql)
+		if err != nil {
+			t.Fatalf("exec %s fail: %s", sql, err)
+		}
+	}
+	if sqls := members.SyncSQL(); len(sqls) > 0 {
+		t.Errorf("expect no sync sqls after executing the sync sqls, got %s", sqls)
+	}
+
+	var fkEnabled int
+	err = dbConn.QueryRow("PRAGMA foreign_keys").Scan(&fkEnabled)
+	if err != nil || fkEnabled != 1 {
+		t.Errorf("foreign_keys want 1 got %d: %v", fkEnabled, err)
+	}
+	var names []string
+	rows, err := dbConn.Query("SELECT `name` FROM `sqlite_master` WHERE `type` IN ('view', 'trigger') ORDER BY `name`")
+	if err != nil {
+		t.Fatalf("query sqlite_master fail: %s", err)
+	}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	rows.Close()
+	want := []string{"ss_member_emails", "ss_members_ins", "ss_members_log_emails"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("views and triggers want %s got %s", want, names)
+	}
+	_, err = dbConn.Exec("INSERT INTO `ss_members` (`email`, `group_id`) VALUES ('b@example.com', 1)")
+	if err != nil {
+		t.Fatalf("insert member fail: %s", err)
+	}
+	cnt := 0
+	err = dbConn.QueryRow("SELECT COUNT(*) FROM `ss_members_log`").Scan(&cnt)
+	if err != nil || cnt != 1 {
+		t.Errorf("trigger not fired after rebuild, log rows %d: %v", cnt, err)
+	}
+	_, err = dbConn.Exec("INSERT INTO `ss_members` (`email`, `group_id`) VALUES ('c@example.com', 3)")
+	if err == nil {
+		t.Errorf("foreign key is not enforced after rebuild")
+	}
+}
+
+func TestSyncLosslessTypeChange(t *testing.T) {
+	type TableStructV1 struct {
+		Id  int `primary:"true"`
+		Val int `nullable:"true"`
+	}
+	type TableStructV2 struct {
+		Id  int    `primary:"true"`
+		Val string `nullable:"true"`
+	}
+	dbConn := openTestDB(t, "losslesstest").DB()
+
+	ts := sqlchemy.NewTableSpecFromStruct(TableStructV1{}, "lossless_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	err = ts.Insert(&TableStructV1{Id: 1, Val: 42})
+	if err != nil {
+		t.Fatalf("insert fail: %s", err)
+	}
+
+	// integer to text is widening, which is applied by default on a populated table
+	ts = sqlchemy.NewTableSpecFromStruct(TableStructV2{}, "lossless_table")
+	err = ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	var valType string
+	err = dbConn.QueryRow("SELECT `type` FROM pragma_table_info('lossless_table') WHERE `name` = 'val'").Scan(&valType)
+	if err != nil {
+		t.Fatalf("table_info fail: %s", err)
+	}
+	if valType != "TEXT" {
+		t.Errorf("expect the type change applied, got %s", valType)
+	}
+	row := TableStructV2{Id: 1}
+	err = ts.Fetch(&row)
+	if err != nil {
+		t.Fatalf("fetch fail: %s", err)
+	}
+	if row.Val != "42" {
+		t.Errorf("want 42 got %q", row.Val)
+	}
+}
+
+func TestSyncDropAndNarrowColumns(t *testing.T) {
+	type TableStructV1 struct {
+		Id    int    `auto_increment:"true"`
+		Name  string `width:"64"`
+		Note  string `width:"64" nullable:"true"`
+		Score string `width:"16" nullable:"true"`
+	}
+	type TableStructV2 struct {
+		Id    int    `auto_increment:"true"`
+		Name  string `width:"64"`
+		Score int    `nullable:"true"`
+	}
+	dbConn := openTestDB(t, "droptest").DB()
+
+	ts := sqlchemy.NewTableSpecFromStruct(TableStructV1{}, "drop_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	_, err = dbConn.Exec("INSERT INTO `drop_table` (`id`, `name`, `note`, `score`) VALUES (1, 'a', 'note', 'high')")
+	if err != nil {
+		t.Fatalf("insert fail: %s", err)
+	}
+
+	columns := func() []string {
+		rows, err := dbConn.Query("SELECT `name` FROM pragma_table_info('drop_table') ORDER BY `name`")
+		if err != nil {
+			t.Fatalf("table_info fail: %s", err)
+		}
+		defer rows.Close()
+		names := make([]string, 0)
+		for rows.Next() {
+			var name string
+			rows.Scan(&name)
+			names = append(names, name)
+		}
+		return names
+	}
+
+	ts = sqlchemy.NewTableSpecFromStruct(TableStructV2{}, "drop_table")
+	// the removed column is kept and the narrowing change is skipped by default
+	err = ts.Sync()
+	if err != nil {
+		t.Fatalf("Sync fail: %s", err)
+	}
+	want := []string{"id", "name", "note", "score"}
+	if got := columns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("columns want %s got %s", want, got)
+	}
+	if sqls := ts.SyncSQL(); len(sqls) > 0 {
+		t.Errorf("expect SyncSQL to skip the drop and narrowing, got %s", sqls)
+	}
+	if len(ts.SyncSQLWithOptions(sqlchemy.SSyncOptions{AllowDrop: true})) == 0 {
+		t.Errorf("expect SyncSQLWithOptions to drop the removed column")
+	}
+
+	reports := make([]sqlchemy.SSyncProgress, 0)
+	opts := sqlchemy.SSyncOptions{
+		AllowDrop:      true,
+		AllowNarrowing: true,
+		Progress: func(p sqlchemy.SSyncProgress) {
+			if p.Stage == sqlchemy.SYNC_STAGE_DROP || p.Stage == sqlchemy.SYNC_STAGE_NARROW {
+				reports = append(reports, p)
+			}
+		},
+	}
+	err = ts.SyncWithOptions(opts)
+	if errors.Cause(err) != sqlchemy.ErrDataTruncated {
+		t.Fatalf("narrowing non-null text to integer want ErrDataTruncated got %v", err)
+	}
+
+	_, err = dbConn.Exec("UPDATE `drop_table` SET `score` = NULL")
+	if err != nil {
+		t.Fatalf("update fail: %s", err)
+	}
+	err = ts.SyncWithOptions(opts)
+	if err != nil {
+		t.Fatalf("SyncWithOptions fail: %s", err)
+	}
+	want = []string{"id", "name", "score"}
+	if got := columns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("columns want %s got %s", want, got)
+	}
+	wantReports := []sqlchemy.SSyncProgress{
+		{Table: "drop_table", Stage: sqlchemy.SYNC_STAGE_DROP, Column: "note"},
+		{Table: "drop_table", Stage: sqlchemy.SYNC_STAGE_NARROW, Column: "score"},
+	}
+	if !reflect.DeepEqual(reports, wantReports) {
+		t.Errorf("reports want %v got %v", wantReports, reports)
+	}
+	if sqls := ts.SyncSQL(); len(sqls) > 0 {
+		t.Errorf("expect no sync sqls after sync, got %s", sqls)
+	}
+	cnt, err := ts.Query().CountWithError()
+	if err != nil || cnt != 1 {
+		t.Errorf("rows after sync want 1 got %d: %v", cnt, err)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+func TestTransaction(t *testing.T) {
+	type TableStruct struct {
+		Id    uint64 `auto_increment:"true"`
+		Name  string `width:"64" charset:"utf8"`
+		Count int    `nullable:"false" default:"0"`
+	}
+	openTestDB(t, "txtest")
+	ts := sqlchemy.NewTableSpecFromStruct(TableStruct{}, "tx_table")
+	err := ts.Sync()
+	if err != nil {
+		t.Fatalf("sync table fail: %s", err)
+	}
+	count := func() int {
+		cnt, err := ts.Query().CountWithError()
+		if err != nil {
+			t.Fatalf("count fail: %s", err)
+		}
+		return cnt
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		tx, err := ts.Database().Begin()
+		if err != nil {
+			t.Fatalf("begin fail: %s", err)
+		}
+		txts := ts.InTx(tx)
+		row := TableStruct{Name: "a"}
+		err = txts.Insert(&row)
+		if err != nil {
+			t.Fatalf("insert fail: %s", err)
+		}
+		err = txts.Increment(&TableStruct{Id: row.Id, Count: 2}, &row)
+		if err != nil {
+			t.Fatalf("increment fail: %s", err)
+		}
+		if row.Count != 2 {
+			t.Errorf("count want 2 got %d", row.Count)
+		}
+		err = tx.Commit()
+		if err != nil {
+			t.Fatalf("commit fail: %s", err)
+		}
+		if cnt := count(); cnt != 1 {
+			t.Errorf("want 1 row got %d", cnt)
+		}
+		if err := tx.Commit(); err != sql.ErrTxDone {
+			t.Errorf("commit twice want ErrTxDone got %v", err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		err := ts.Database().RunInTx(context.Background(), func(tx *sqlchemy.STx) error {
+			txts := ts.InTx(tx)
+			err := txts.Insert(&TableStruct{Name: "b"})
+			if err != nil {
+				return err
+			}
+			err = txts.DeleteFrom(map[string]interface{}{"name": "a"})
+			if err != nil {
+				return err
+			}
+			cnt, err := txts.Query().CountWithError()
+			if err != nil {
+				return err
+			}
+			if cnt != 1 {
+				t.Errorf("want 1 row inside transaction got %d", cnt)
+			}
+			return errors.Error("abort")
+		})
+		if err == nil || err.Error() != "abort" {
+			t.Errorf("want abort error got %v", err)
+		}
+		if cnt := count(); cnt != 1 {
+			t.Errorf("want 1 row got %d", cnt)
+		}
+	})
+
+	t.Run("savepoint", func(t *testing.T) {
+		tx, err := ts.Database().Begin()
+		if err != nil {
+			t.Fatalf("begin fail: %s", err)
+		}
+		txts := ts.InTx(tx)
+		err = txts.Insert(&TableStruct{Name: "c"})
+		if err != nil {
+			t.Fatalf("insert fail: %s", err)
+		}
+		sp, err := tx.Begin()
+		if err != nil {
+			t.Fatalf("savepoint fail: %s", err)
+		}
+		err = ts.InTx(sp).Insert(&TableStruct{Name: "d"})
+		if err != nil {
+			t.Fatalf("insert in savepoint fail: %s", err)
+		}
+		err = sp.Rollback()
+		if err != nil {
+			t.Fatalf("rollback to savepoint fail: %s", err)
+		}
+		q := ts.Query().Equals("name", "d").InTx(tx)
+		cnt, err := q.CountWithError()
+		if err != nil {
+			t.Fatalf("count fail: %s", err)
+		}
+		if cnt != 0 {
+			t.Errorf("want 0 rows after rollback to savepoint, got %d", cnt)
+		}
+		err = tx.Commit()
+		if err != nil {
+			t.Fatalf("commit fail: %s", err)
+		}
+		if cnt := count(); cnt != 2 {
+			t.Errorf("want 2 rows got %d", cnt)
+		}
+	})
+}
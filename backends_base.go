@@ -15,6 +15,8 @@
 package sqlchemy
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"strings"
@@ -36,6 +38,10 @@ func (bb *SBaseBackend) IsSupportIndexAndContraints() bool {
 	return false
 }
 
+func (bb *SBaseBackend) IsSupportIndexFeature(ts ITableSpec, feature TIndexFeature) bool {
+	return false
+}
+
 func (bb *SBaseBackend) GetTableSQL() string {
 	return "SHOW TABLES"
 }
@@ -80,6 +86,17 @@ func (bb *SBaseBackend) SupportMixedInsertVariables() bool {
 	return true
 }
 
+func (bb *SBaseBackend) LiteralStyle() SLiteralStyle {
+	return SLiteralStyle{}
+}
+
+func (bb *SBaseBackend) ClassifyError(err error) error {
+	if err == driver.ErrBadConn || err == sql.ErrConnDone {
+		return ErrConnectionLost
+	}
+	return nil
+}
+
 func (bb *SBaseBackend) CanUpdate() bool {
 	return false
 }
@@ -96,6 +113,11 @@ func (bb *SBaseBackend) CommitTableChangeSQL(ts ITableSpec, changes STableChange
 	return nil
 }
 
+func (bb *SBaseBackend) CommitTableChanges(ts ITableSpec, changes STableChanges, opts SSyncOptions) error {
+	db := ts.Database()
+	return db.execSyncSQLs(db.backend.CommitTableChangeSQL(ts, changes))
+}
+
 func (bb *SBaseBackend) FetchIndexesAndConstraints(ts ITableSpec) ([]STableIndex, []STableConstraint, error) {
 	return nil, nil, nil
 }
@@ -108,6 +130,26 @@ func (bb *SBaseBackend) CanSupportRowAffected() bool {
 	return true
 }
 
+func (bb *SBaseBackend) CanSupportLastInsertId() bool {
+	return true
+}
+
+func (bb *SBaseBackend) CanSupportSavepoint() bool {
+	return false
+}
+
+func (bb *SBaseBackend) CanSupportCTE() bool {
+	return false
+}
+
+func (bb *SBaseBackend) CanSupportTransactionalDDL() bool {
+	return false
+}
+
+func (bb *SBaseBackend) ReplacePlaceholders(sqlstr string) string {
+	return sqlstr
+}
+
 func (bb *SBaseBackend) InsertSQLTemplate() string {
 	return "INSERT INTO `{{ .Table }}` ({{ .Columns }}) VALUES ({{ .Values }})"
 }
@@ -116,10 +158,28 @@ func (bb *SBaseBackend) UpdateSQLTemplate() string {
 	return "UPDATE `{{ .Table }}` SET {{ .Columns }} WHERE {{ .Conditions }}"
 }
 
+func (bb *SBaseBackend) DeleteSQLTemplate() string {
+	return "DELETE FROM `{{ .Table }}` WHERE {{ .Conditions }}"
+}
+
 func (bb *SBaseBackend) InsertOrUpdateSQLTemplate() string {
 	return ""
 }
 
+func (bb *SBaseBackend) InsertOrUpdateBatchSQLTemplate() string {
+	return ""
+}
+
+// MaxPlaceholders returns the maximal number of placeholders in a statement,
+// defaults to the conservative limit of SQLite
+func (bb *SBaseBackend) MaxPlaceholders() int {
+	return 999
+}
+
+func (bb *SBaseBackend) CanSupportMultiRowInsert() bool {
+	return true
+}
+
 func (bb *SBaseBackend) CAST(field IQueryField, typeStr string, fieldname string) IQueryField {
 	return NewFunctionField(fieldname, false, `CAST(%s AS `+typeStr+`)`, field)
 }
@@ -256,6 +316,46 @@ func (bb *SBaseBackend) DATEDIFF(unit string, field1, field2 IQueryField) IQuery
 	return NewFunctionField("", false, fmt.Sprintf("DATEDIFF('%s',%s,%s)", unit, "%s", "%s"), field1, field2)
 }
 
+// ROW_NUMBER represents SQL window function of ROW_NUMBER
+func (bb *SBaseBackend) ROW_NUMBER(name string, window *SWindow) IQueryField {
+	return NewWindowFunctionField(name, "ROW_NUMBER()", window)
+}
+
+// RANK represents SQL window function of RANK
+func (bb *SBaseBackend) RANK(name string, window *SWindow) IQueryField {
+	return NewWindowFunctionField(name, "RANK()", window)
+}
+
+// DENSE_RANK represents SQL window function of DENSE_RANK
+func (bb *SBaseBackend) DENSE_RANK(name string, window *SWindow) IQueryField {
+	return NewWindowFunctionField(name, "DENSE_RANK()", window)
+}
+
+// LAG represents SQL window function of LAG
+func (bb *SBaseBackend) LAG(name string, field IQueryField, offset int, window *SWindow) IQueryField {
+	return NewWindowFunctionField(name, fmt.Sprintf("LAG(%%s, %d)", offset), window, field)
+}
+
+// LEAD represents SQL window function of LEAD
+func (bb *SBaseBackend) LEAD(name string, field IQueryField, offset int, window *SWindow) IQueryField {
+	return NewWindowFunctionField(name, fmt.Sprintf("LEAD(%%s, %d)", offset), window, field)
+}
+
+// FIRST_VALUE represents SQL window function of FIRST_VALUE
+func (bb *SBaseBackend) FIRST_VALUE(name string, field IQueryField, window *SWindow) IQueryField {
+	return NewWindowFunctionField(name, "FIRST_VALUE(%s)", window, field)
+}
+
+// SUM_OVER represents SQL function of SUM over a window
+func (bb *SBaseBackend) SUM_OVER(name string, field IQueryField, window *SWindow) IQueryField {
+	return NewWindowFunctionField(name, "SUM(%s)", window, field)
+}
+
+// AVG_OVER represents SQL function of AVG over a window
+func (bb *SBaseBackend) AVG_OVER(name string, field IQueryField, window *SWindow) IQueryField {
+	return NewWindowFunctionField(name, "AVG(%s)", window, field)
+}
+
 func (bb *SBaseBackend) QuoteChar() string {
 	return "`"
 }
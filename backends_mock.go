@@ -124,6 +124,10 @@ func (mock *sMockBackend) InsertOrUpdateSQLTemplate() string {
 	return "INSERT INTO `{{ .Table }}` ({{ .Columns }}) VALUES ({{ .Values }}) ON DUPLICATE KEY UPDATE {{ .SetValues }}"
 }
 
+func (mock *sMockBackend) InsertOrUpdateBatchSQLTemplate() string {
+	return "INSERT INTO `{{ .Table }}` ({{ .Columns }}) VALUES {{ .Values }} ON DUPLICATE KEY UPDATE {{ range $i, $c := .UpdateColumns }}{{ if $i }}, {{ end }}`{{ $c }}` = VALUES(`{{ $c }}`){{ end }}{{ range $i, $c := .VersionColumns }}{{ if or $i $.UpdateColumns }}, {{ end }}`{{ $c }}` = `{{ $c }}` + 1{{ end }}"
+}
+
 func (mock *sMockBackend) PrepareInsertOrUpdateSQL(ts ITableSpec, insertColNames []string, insertFields []string, onPrimaryCols []string, updateSetCols []string, insertValues []interface{}, updateValues []interface{}) (string, []interface{}) {
 	return "", nil
 }
@@ -54,6 +54,12 @@ type IColumnSpec interface {
 	// IsIndex returns whether this column is indexable, if it is true, a index of this column will be automatically created
 	IsIndex() bool
 
+	// IsSoftDelete returns whether this column marks a row soft-deleted
+	IsSoftDelete() bool
+
+	// IsOptimisticLock returns whether this auto_version column is checked on update
+	IsOptimisticLock() bool
+
 	// ExtraDefs returns some extra column attribute definitions, not covered by the standard fields
 	ExtraDefs() string
 
@@ -148,6 +154,8 @@ type SBaseColumn struct {
 	isUnique      bool
 	isIndex       bool
 	isAllowZero   bool
+	isSoftDelete  bool
+	isOptLock     bool
 	tags          map[string]string
 	colIndex      int
 }
@@ -219,6 +227,16 @@ func (c *SBaseColumn) IsIndex() bool {
 	return c.isIndex
 }
 
+// IsSoftDelete implementation of SBaseColumn for IColumnSpec
+func (c *SBaseColumn) IsSoftDelete() bool {
+	return c.isSoftDelete
+}
+
+// IsOptimisticLock implementation of SBaseColumn for IColumnSpec
+func (c *SBaseColumn) IsOptimisticLock() bool {
+	return c.isOptLock
+}
+
 // ExtraDefs implementation of SBaseColumn for IColumnSpec
 func (c *SBaseColumn) ExtraDefs() string {
 	return ""
@@ -361,6 +379,16 @@ func NewBaseColumn(name string, sqltype string, tagmap map[string]string, isPoin
 	if ok {
 		isAllowZero = utils.ToBool(val)
 	}
+	isSoftDelete := false
+	tagmap, val, ok = utils.TagPop(tagmap, TAG_SOFT_DELETE)
+	if ok {
+		isSoftDelete = utils.ToBool(val)
+	}
+	isOptLock := false
+	tagmap, val, ok = utils.TagPop(tagmap, TAG_OPTIMISTIC_LOCK)
+	if ok {
+		isOptLock = utils.ToBool(val)
+	}
 	return SBaseColumn{
 		name:          name,
 		dbName:        dbName,
@@ -374,6 +402,8 @@ func NewBaseColumn(name string, sqltype string, tagmap map[string]string, isPoin
 		tags:          tagmap,
 		isPointer:     isPointer,
 		isAllowZero:   isAllowZero,
+		isSoftDelete:  isSoftDelete,
+		isOptLock:     isOptLock,
 		colIndex:      -1,
 	}
 }
@@ -86,4 +86,17 @@ const (
 	// TAG_OLD_NAME is a field indicate the colume was renamed from an old name,
 	// sync table will do renaming of coolumn instead of creating a new column
 	TAG_OLD_NAME = "old_name"
+	// TAG_SOFT_DELETE is a field tag that indicates the column marks a row soft-deleted, either a boolean column
+	// as the deleted flag, or a datetime column as the deleted_at timestamp
+	TAG_SOFT_DELETE = "soft_delete"
+	// TAG_OPTIMISTIC_LOCK is a field tag that indicates the auto_version column is compared with the version loaded
+	// in the WHERE clause of an update, so that a concurrent modification is detected
+	TAG_OPTIMISTIC_LOCK = "optimistic_lock"
+	// TAG_FOREIGN_KEY is a field tag that indicates the column references a column of another table,
+	// in the form of table(column) or table.column
+	TAG_FOREIGN_KEY = "foreign_key"
+	// TAG_ON_DELETE is a field tag that indicates the action of the foreign key on deleting the referenced row
+	TAG_ON_DELETE = "on_delete"
+	// TAG_ON_UPDATE is a field tag that indicates the action of the foreign key on updating the referenced row
+	TAG_ON_UPDATE = "on_update"
 )
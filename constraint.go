@@ -14,13 +14,33 @@
 
 package sqlchemy
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
+const (
+	// FK_ACTION_CASCADE deletes or updates the referencing rows along with the referenced row
+	FK_ACTION_CASCADE = "CASCADE"
+	// FK_ACTION_SET_NULL sets the referencing columns to NULL
+	FK_ACTION_SET_NULL = "SET NULL"
+	// FK_ACTION_RESTRICT rejects the deletion or update of the referenced row
+	FK_ACTION_RESTRICT = "RESTRICT"
+	// FK_ACTION_NO_ACTION rejects the deletion or update of the referenced row, the default action
+	FK_ACTION_NO_ACTION = "NO ACTION"
+)
+
+// STableConstraint represents a foreign key constraint of a table
 type STableConstraint struct {
 	name         string
 	columns      []string
 	foreignTable string
 	foreignKeys  []string
+
+	onDelete string
+	onUpdate string
+
+	ts ITableSpec
 }
 
 func NewTableConstraint(name string, cols []string, foreignTable string, fcols []string) STableConstraint {
@@ -32,6 +52,145 @@ func NewTableConstraint(name string, cols []string, foreignTable string, fcols [
 	}
 }
 
+// NewTableForeignKey returns a foreign key constraint with the referential actions
+func NewTableForeignKey(ts ITableSpec, name string, cols []string, foreignTable string, fcols []string, onDelete, onUpdate string) STableConstraint {
+	return STableConstraint{
+		name:         name,
+		columns:      cols,
+		foreignTable: foreignTable,
+		foreignKeys:  fcols,
+		onDelete:     normalizeForeignKeyAction(onDelete),
+		onUpdate:     normalizeForeignKeyAction(onUpdate),
+		ts:           ts,
+	}
+}
+
+// normalizeForeignKeyAction returns the action in upper case, and empty for the default actions
+func normalizeForeignKeyAction(action string) string {
+	action = strings.ToUpper(strings.Join(strings.Fields(strings.ReplaceAll(action, "_", " ")), " "))
+	switch action {
+	case FK_ACTION_RESTRICT, FK_ACTION_NO_ACTION:
+		return ""
+	}
+	return action
+}
+
+// Name returns the name of the constraint, generated from the table and columns if not given
+func (c *STableConstraint) Name() string {
+	if len(c.name) > 0 || c.ts == nil {
+		return c.name
+	}
+	name := fmt.Sprintf("fk_%s_%s", c.ts.Name(), strings.Join(c.columns, "_"))
+	if len(name) > IndexLimit {
+		name = name[:IndexLimit]
+	}
+	return name
+}
+
+// Columns returns the referencing columns
+func (c *STableConstraint) Columns() []string {
+	return c.columns
+}
+
+// ForeignTable returns the referenced table
+func (c *STableConstraint) ForeignTable() string {
+	return c.foreignTable
+}
+
+// ForeignKeys returns the referenced columns
+func (c *STableConstraint) ForeignKeys() []string {
+	return c.foreignKeys
+}
+
+// OnDelete returns the action on deleting the referenced row, empty for the default
+func (c *STableConstraint) OnDelete() string {
+	return c.onDelete
+}
+
+// OnUpdate returns the action on updating the referenced row, empty for the default
+func (c *STableConstraint) OnUpdate() string {
+	return c.onUpdate
+}
+
+// IsIdentical returns whether two constraints have the same definition, regardless of names
+func (c *STableConstraint) IsIdentical(o STableConstraint) bool {
+	if c.foreignTable != o.foreignTable || len(c.columns) != len(o.columns) || len(c.foreignKeys) != len(o.foreignKeys) {
+		return false
+	}
+	for i := range c.columns {
+		if c.columns[i] != o.columns[i] {
+			return false
+		}
+	}
+	for i := range c.foreignKeys {
+		if c.foreignKeys[i] != o.foreignKeys[i] {
+			return false
+		}
+	}
+	return normalizeForeignKeyAction(c.onDelete) == normalizeForeignKeyAction(o.onDelete) &&
+		normalizeForeignKeyAction(c.onUpdate) == normalizeForeignKeyAction(o.onUpdate)
+}
+
+// DefinitionString returns the constraint clause used in CREATE TABLE and ALTER TABLE ADD,
+// quoted with quoteStr
+func (c *STableConstraint) DefinitionString(quoteStr string) string {
+	quote := func(names []string) string {
+		ret := make([]string, len(names))
+		for i := range names {
+			ret[i] = fmt.Sprintf("%s%s%s", quoteStr, names[i], quoteStr)
+		}
+		return strings.Join(ret, ", ")
+	}
+	def := fmt.Sprintf("CONSTRAINT %s%s%s FOREIGN KEY (%s) REFERENCES %s%s%s (%s)", quoteStr, c.Name(), quoteStr, quote(c.columns), quoteStr, c.foreignTable, quoteStr, quote(c.foreignKeys))
+	if len(c.onDelete) > 0 {
+		def += " ON DELETE " + c.onDelete
+	}
+	if len(c.onUpdate) > 0 {
+		def += " ON UPDATE " + c.onUpdate
+	}
+	return def
+}
+
+func (c STableConstraint) clone(ts ITableSpec) STableConstraint {
+	return NewTableForeignKey(ts, "", c.columns, c.foreignTable, c.foreignKeys, c.onDelete, c.onUpdate)
+}
+
+// parseForeignKeyTag parses the value of foreign_key tag, in the form of table(column) or table.column
+func parseForeignKeyTag(val string) (string, string, bool) {
+	val = strings.TrimSpace(val)
+	if strings.HasSuffix(val, ")") {
+		pos := strings.IndexByte(val, '(')
+		if pos > 0 {
+			return strings.TrimSpace(val[:pos]), strings.TrimSpace(val[pos+1 : len(val)-1]), true
+		}
+		return "", "", false
+	}
+	pos := strings.LastIndexByte(val, '.')
+	if pos > 0 && pos < len(val)-1 {
+		return val[:pos], val[pos+1:], true
+	}
+	return "", "", false
+}
+
+// AddForeignKey declares a foreign key constraint from cols of the table to refCols of refTable,
+// onDelete and onUpdate are the referential actions, e.g. FK_ACTION_CASCADE, empty for the default
+func (ts *STableSpec) AddForeignKey(cols []string, refTable string, refCols []string, onDelete, onUpdate string) bool {
+	fk := NewTableForeignKey(ts, "", cols, refTable, refCols, onDelete, onUpdate)
+	for i := range ts._contraints {
+		if ts._contraints[i].IsIdentical(fk) {
+			return false
+		}
+	}
+	ts._contraints = append(ts._contraints, fk)
+	return true
+}
+
+// ForeignKeys returns the foreign key constraints declared of the table
+func (ts *STableSpec) ForeignKeys() []STableConstraint {
+	ts.Columns()
+	return ts._contraints
+}
+
 func FetchColumns(match string) []string {
 	ret := make([]string, 0)
 	if len(match) > 0 {
@@ -0,0 +1,318 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"fmt"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+// SCTEField represents a field of a common table expression, which implements IQueryField
+type SCTEField struct {
+	cte   *SCTE
+	name  string
+	alias string
+}
+
+// Expression implementation of SCTEField for IQueryField
+func (cf *SCTEField) Expression() string {
+	qChar := cf.cte.database().backend.QuoteChar()
+	return fmt.Sprintf("%s%s%s.%s%s%s", qChar, cf.cte.Alias(), qChar, qChar, cf.name, qChar)
+}
+
+// Name implementation of SCTEField for IQueryField
+func (cf *SCTEField) Name() string {
+	if len(cf.alias) > 0 {
+		return cf.alias
+	}
+	return cf.name
+}
+
+// Reference implementation of SCTEField for IQueryField
+func (cf *SCTEField) Reference() string {
+	qChar := cf.cte.database().backend.QuoteChar()
+	return fmt.Sprintf("%s%s%s.%s%s%s", qChar, cf.cte.Alias(), qChar, qChar, cf.Name(), qChar)
+}
+
+// Label implementation of SCTEField for IQueryField
+func (cf *SCTEField) Label(label string) IQueryField {
+	if len(label) > 0 {
+		ncf := *cf
+		ncf.alias = label
+		return &ncf
+	}
+	return cf
+}
+
+// Variables implementation of SCTEField for IQueryField
+func (cf *SCTEField) Variables() []interface{} {
+	return nil
+}
+
+// ConvertFromValue implementation of SCTEField for IQueryField
+func (cf *SCTEField) ConvertFromValue(val interface{}) interface{} {
+	field := cf.cte.query.Field(cf.name)
+	if field != nil {
+		return field.ConvertFromValue(val)
+	}
+	return val
+}
+
+// database implementation of SCTEField for IQueryField
+func (cf *SCTEField) database() *SDatabase {
+	return cf.cte.database()
+}
+
+// SCTE represents a common table expression, e.g. WITH name AS (SELECT ...)
+// SCTE implements IQuerySource, so that it can be queried or joined as a table.
+// A SQuery that refers to a SCTE should declare it by SQuery.With or SQuery.WithRecursive,
+// or simply be generated by SCTE.Query
+type SCTE struct {
+	name  string
+	alias string
+
+	// query is the non-recursive term
+	query IQuery
+	// recursive is the recursive term that refers to the CTE itself
+	recursive IQuery
+
+	isRecursive bool
+	fields      []IQueryField
+}
+
+// NewCTE returns a common table expression named name of query q
+func NewCTE(name string, q IQuery) *SCTE {
+	cte := &SCTE{
+		name:  name,
+		alias: getTableAliasName(),
+		query: q,
+	}
+	cte.initFields()
+	return cte
+}
+
+// NewRecursiveCTE returns a recursive common table expression named name. The CTE
+// is defined as anchor UNION ALL the query returned by recursive, which refers to the
+// CTE itself through the self argument, e.g.
+//
+//	WITH RECURSIVE name AS (anchor UNION ALL recursive(self))
+//
+// The fields of the CTE are determined by the anchor query. A recursive CTE cannot be
+// inlined as a subquery, so ErrCTENotSupported is returned if the backend does not support CTE
+func NewRecursiveCTE(name string, anchor IQuery, recursive func(self *SCTE) IQuery) (*SCTE, error) {
+	if anchor.database() == nil {
+		panic("common table expression with empty database")
+	}
+	backend := anchor.database().backend
+	if !backend.CanSupportCTE() {
+		return nil, errors.Wrapf(ErrCTENotSupported, "backend %s, cte %s", backend.Name(), name)
+	}
+	cte := &SCTE{
+		name:        name,
+		alias:       getTableAliasName(),
+		query:       anchor,
+		isRecursive: true,
+	}
+	cte.initFields()
+	cte.recursive = recursive(cte)
+	return cte, nil
+}
+
+func (cte *SCTE) initFields() {
+	if cte.query.database() == nil {
+		panic("common table expression with empty database")
+	}
+	qfields := cte.query.QueryFields()
+	cte.fields = make([]IQueryField, len(qfields))
+	for i := range qfields {
+		cte.fields[i] = &SCTEField{cte: cte, name: qfields[i].Name()}
+	}
+}
+
+// Name returns the name of the common table expression
+func (cte *SCTE) Name() string {
+	return cte.name
+}
+
+// IsRecursive returns whether it is a recursive common table expression
+func (cte *SCTE) IsRecursive() bool {
+	return cte.isRecursive
+}
+
+func (cte *SCTE) isInline() bool {
+	return !cte.database().backend.CanSupportCTE()
+}
+
+// body returns the query text of the CTE, namely the part within AS ( ... )
+func (cte *SCTE) body() string {
+	if !cte.isRecursive {
+		return cte.query.String()
+	}
+	if cte.recursive == nil {
+		// still in the definition of recursive term
+		return cte.query.String()
+	}
+	return fmt.Sprintf("%s %s %s", cte.query.String(), cte.database().backend.UnionAllString(), cte.recursive.String())
+}
+
+func (cte *SCTE) bodyVariables() []interface{} {
+	vars := cte.query.Variables()
+	if cte.recursive != nil {
+		vars = append(vars, cte.recursive.Variables()...)
+	}
+	return vars
+}
+
+// definition returns the definition clause in WITH, e.g. name(col1, col2) AS (SELECT ...)
+func (cte *SCTE) definition() string {
+	qChar := cte.database().backend.QuoteChar()
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("%s%s%s", qChar, cte.name, qChar))
+	if cte.isRecursive {
+		buf.WriteByte('(')
+		for i := range cte.fields {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(fmt.Sprintf("%s%s%s", qChar, cte.fields[i].Name(), qChar))
+		}
+		buf.WriteByte(')')
+	}
+	buf.WriteString(" AS (")
+	buf.WriteString(cte.body())
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+// Expression implementation of SCTE for IQuerySource
+// If the backend does not support CTE, the CTE is inlined as a subquery
+func (cte *SCTE) Expression() string {
+	if cte.isInline() {
+		return fmt.Sprintf("(%s)", cte.body())
+	}
+	qChar := cte.database().backend.QuoteChar()
+	return fmt.Sprintf("%s%s%s", qChar, cte.name, qChar)
+}
+
+// Alias implementation of SCTE for IQuerySource
+func (cte *SCTE) Alias() string {
+	return cte.alias
+}
+
+// Variables implementation of SCTE for IQuerySource
+// The variables of the CTE definition belong to the WITH clause, except that the CTE is inlined
+func (cte *SCTE) Variables() []interface{} {
+	if cte.isInline() {
+		return cte.bodyVariables()
+	}
+	return nil
+}
+
+// Field implementation of SCTE for IQuerySource
+func (cte *SCTE) Field(id string, alias ...string) IQueryField {
+	for i := range cte.fields {
+		if cte.fields[i].Name() == id {
+			if len(alias) > 0 {
+				return cte.fields[i].Label(alias[0])
+			}
+			return cte.fields[i]
+		}
+	}
+	log.Errorf("common table expression %s cannot find field %s", cte.name, id)
+	return nil
+}
+
+// Fields implementation of SCTE for IQuerySource
+func (cte *SCTE) Fields() []IQueryField {
+	return cte.fields
+}
+
+// database implementation of SCTE for IQuerySource
+func (cte *SCTE) database() *SDatabase {
+	return cte.query.database()
+}
+
+// Query of SCTE generates a new query from a common table expression, which declares the CTE in its WITH clause
+func (cte *SCTE) Query(f ...IQueryField) *SQuery {
+	q := DoQuery(cte, f...)
+	if !cte.isRecursive || cte.recursive != nil {
+		q.addCTE(cte)
+	}
+	return q
+}
+
+func (tq *SQuery) addCTE(cte *SCTE) {
+	for i := range tq.ctes {
+		if tq.ctes[i] == cte {
+			return
+		}
+	}
+	tq.ctes = append(tq.ctes, cte)
+}
+
+// With of SQuery declares a common table expression named name of query q in the WITH clause,
+// and returns the CTE which can be used as a query source in this query
+func (tq *SQuery) With(name string, q IQuery) *SCTE {
+	cte := NewCTE(name, q)
+	tq.addCTE(cte)
+	return cte
+}
+
+// WithRecursive of SQuery declares a recursive common table expression in the WITH clause,
+// and returns the CTE which can be used as a query source in this query. See NewRecursiveCTE
+func (tq *SQuery) WithRecursive(name string, anchor IQuery, recursive func(self *SCTE) IQuery) (*SCTE, error) {
+	cte, err := NewRecursiveCTE(name, anchor, recursive)
+	if err != nil {
+		return nil, err
+	}
+	tq.addCTE(cte)
+	return cte, nil
+}
+
+func withClause(tq *SQuery) string {
+	if len(tq.ctes) == 0 || !tq.database().backend.CanSupportCTE() {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("WITH ")
+	for i := range tq.ctes {
+		if tq.ctes[i].isRecursive {
+			buf.WriteString("RECURSIVE ")
+			break
+		}
+	}
+	for i := range tq.ctes {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(tq.ctes[i].definition())
+	}
+	buf.WriteByte(' ')
+	return buf.String()
+}
+
+func withVariables(tq *SQuery) []interface{} {
+	if len(tq.ctes) == 0 || !tq.database().backend.CanSupportCTE() {
+		return nil
+	}
+	vars := make([]interface{}, 0)
+	for i := range tq.ctes {
+		vars = append(vars, tq.ctes[i].bodyVariables()...)
+	}
+	return vars
+}
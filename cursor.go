@@ -0,0 +1,129 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// SCursor is a forward-only cursor that decodes the rows of a query one at a time without buffering.
+// The cursor must be closed after use, e.g.
+//
+//	cursor, err := q.Cursor()
+//	if err != nil {
+//		return err
+//	}
+//	defer cursor.Close()
+//	for cursor.Next() {
+//		err := cursor.Scan(&row)
+//		...
+//	}
+//	return cursor.Err()
+type SCursor struct {
+	query *SQuery
+	rows  *sql.Rows
+	ctx   context.Context
+}
+
+// Cursor of SQuery executes the query and returns a cursor of the result rows
+func (tq *SQuery) Cursor() (*SCursor, error) {
+	return tq.CursorContext(context.Background())
+}
+
+// CursorContext of SQuery executes the query and returns a cursor of the result rows with context
+func (tq *SQuery) CursorContext(ctx context.Context) (*SCursor, error) {
+	rows, err := tq.RowsContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "RowsContext")
+	}
+	return &SCursor{
+		query: tq,
+		rows:  rows,
+		ctx:   ctx,
+	}, nil
+}
+
+// Next of SCursor advances the cursor to the next row, returns false if no more row or error occurs
+func (c *SCursor) Next() bool {
+	return c.rows.Next()
+}
+
+// Scan of SCursor decodes the current row into the struct that dest points to
+func (c *SCursor) Scan(dest interface{}) error {
+	return c.query.db.wrapError(c.ctx, c.query.Row2Struct(c.rows, dest))
+}
+
+// ScanStringMap of SCursor returns the current row in a stringmap(map[string]string)
+func (c *SCursor) ScanStringMap() (map[string]string, error) {
+	result, err := c.query.rowScan2StringMap(c.rows)
+	if err != nil {
+		return nil, c.query.db.wrapError(c.ctx, err)
+	}
+	c.query.lastRow = result
+	return result, nil
+}
+
+// Err of SCursor returns the error encountered during iteration
+func (c *SCursor) Err() error {
+	return c.query.db.wrapError(c.ctx, c.rows.Err())
+}
+
+// Close of SCursor closes the underlying sql.Rows, it is safe to call Close multiple times
+func (c *SCursor) Close() error {
+	return c.rows.Close()
+}
+
+// Iterate of SQuery decodes the result rows one by one into dest, which is a pointer to struct,
+// and calls callback with dest for each row. dest is reset before decoding each row. The iteration
+// stops when callback returns an error, and the error is returned except ErrStopIteration
+func (tq *SQuery) Iterate(dest interface{}, callback func(dest interface{}) error) error {
+	return tq.IterateContext(context.Background(), dest, callback)
+}
+
+// IterateContext of SQuery is the context-aware variant of Iterate
+func (tq *SQuery) IterateContext(ctx context.Context, dest interface{}, callback func(dest interface{}) error) error {
+	destPtrValue := reflect.ValueOf(dest)
+	if destPtrValue.Kind() != reflect.Ptr {
+		return errors.Wrap(ErrNeedsPointer, "input must be a pointer")
+	}
+	destValue := destPtrValue.Elem()
+	zero := reflect.Zero(destValue.Type())
+
+	cursor, err := tq.CursorContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	for cursor.Next() {
+		destValue.Set(zero)
+		err := cursor.Scan(dest)
+		if err != nil {
+			return errors.Wrap(err, "Scan")
+		}
+		err = callback(dest)
+		if err != nil {
+			if errors.Cause(err) == ErrStopIteration {
+				return nil
+			}
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -15,9 +15,7 @@
 package sqlchemy
 
 import (
-	"fmt"
-	"strings"
-	"time"
+	"context"
 
 	"yunion.io/x/log"
 )
@@ -27,30 +25,31 @@ var (
 	DEBUG_SQLCHEMY = false
 )
 
-func sqlDebug(key, sqlstr string, variables []interface{}) {
-	sqlstr = _sqlDebug(sqlstr, variables)
+func sqlDebug(db *SDatabase, key, sqlstr string, variables []interface{}) {
+	sqlstr = _sqlDebug(db, sqlstr, variables)
 	if key == "" {
 		key = "SQuery"
 	}
 	log.Debugln(key, sqlstr)
 }
 
-func _sqlDebug(sqlstr string, variables []interface{}) string {
-	return SQLPrintf(sqlstr, variables)
+func _sqlDebug(db *SDatabase, sqlstr string, variables []interface{}) string {
+	return db.SQLPrintf(sqlstr, variables)
 }
 
+// SQLPrintf renders the SQL with the placeholders substituted by the literals of variables
+// with the generic quoting rules, use SDatabase.SQLPrintf for the rules of a backend
 func SQLPrintf(sqlstr string, variables []interface{}) string {
-	for _, v := range variables {
-		switch v.(type) {
-		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
-			sqlstr = strings.Replace(sqlstr, "?", fmt.Sprintf("%v", v), 1)
-		case string, time.Time:
-			sqlstr = strings.Replace(sqlstr, "?", fmt.Sprintf("'%s'", v), 1)
-		default:
-			sqlstr = strings.Replace(sqlstr, "?", fmt.Sprintf("'%v'", v), 1)
-		}
+	return SLiteralStyle{}.Render(sqlstr, variables)
+}
+
+// SQLPrintf renders the SQL with the placeholders substituted by the literals of variables
+// quoted by the rules of the backend, so that the output can be executed
+func (db *SDatabase) SQLPrintf(sqlstr string, variables []interface{}) string {
+	if db == nil || db.backend == nil {
+		return SQLPrintf(sqlstr, variables)
 	}
-	return sqlstr
+	return db.backend.LiteralStyle().Render(sqlstr, variables)
 }
 
 // DebugQuery show the full query string for debug
@@ -62,18 +61,18 @@ func (tq *SQuery) DebugQuery() {
 func (tq *SQuery) DebugQuery2(key string) {
 	sqlstr := tq.String()
 	vars := tq.Variables()
-	sqlDebug(key, sqlstr, vars)
+	sqlDebug(tq.db, key, sqlstr, vars)
 }
 
 func (tq *SQuery) DebugString() string {
-	return _sqlDebug(tq.String(), tq.Variables())
+	return _sqlDebug(tq.db, tq.String(), tq.Variables())
 }
 
 // DebugQuery show the full query string for a subquery for debug
 func (sqf *SSubQuery) DebugQuery2(key string) {
 	sqlstr := sqf.Expression()
 	vars := sqf.query.Variables()
-	sqlDebug(key, sqlstr, vars)
+	sqlDebug(sqf.query.database(), key, sqlstr, vars)
 }
 
 // DebugQuery show the full query string for a subquery for debug
@@ -83,15 +82,15 @@ func (sqf *SSubQuery) DebugQuery() {
 
 // DebugInsert does insert with debug mode on
 func (t *STableSpec) DebugInsert(dt interface{}) error {
-	return t.insert(dt, false, true)
+	return t.insert(context.Background(), dt, false, true)
 }
 
 // DebugInsertOrUpdate does insertOrUpdate with debug mode on
 func (t *STableSpec) DebugInsertOrUpdate(dt interface{}) error {
-	return t.insert(dt, true, true)
+	return t.insert(context.Background(), dt, true, true)
 }
 
 // DebugUpdateFields does update with debug mode on
 func (t *STableSpec) DebugUpdateFields(dt interface{}, fields map[string]interface{}) error {
-	return t.updateFields(dt, fields, true)
+	return t.updateFields(context.Background(), dt, fields, true)
 }
@@ -15,17 +15,22 @@
 package sqlchemy
 
 import (
+	"database/sql"
 	"testing"
+	"time"
 
+	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/util/timeutils"
 )
 
 func TestSqlDebug(t *testing.T) {
 	tm, _ := timeutils.ParseIsoTime("2021-11-01T12:00:00Z")
+	cst := time.FixedZone("CST", 8*3600)
 	cases := []struct {
-		sql  string
-		vars []interface{}
-		want string
+		sql   string
+		vars  []interface{}
+		style SLiteralStyle
+		want  string
 	}{
 		{
 			sql: `SET a = ?, b = ?, c = ?`,
@@ -34,13 +39,43 @@ func TestSqlDebug(t *testing.T) {
 				123,
 				tm,
 			},
-			want: `SET a = 'name', b = 123, c = '2021-11-01 12:00:00 +0000 UTC'`,
+			want: `SET a = 'name', b = 123, c = '2021-11-01 12:00:00'`,
+		},
+		{
+			sql:  `SELECT * FROM t WHERE a = '?' AND "b?" = ? AND c = ? -- ?`,
+			vars: []interface{}{"it's", nil},
+			want: `SELECT * FROM t WHERE a = '?' AND "b?" = 'it''s' AND c = NULL -- ?`,
+		},
+		{
+			sql:   "SELECT * FROM `t?` WHERE a = 'x\\' ?' AND b = ? /* ? */ AND c = ?",
+			vars:  []interface{}{`a\'b`, true},
+			style: SLiteralStyle{BackslashEscape: true},
+			want:  "SELECT * FROM `t?` WHERE a = 'x\\' ?' AND b = 'a\\\\''b' /* ? */ AND c = 1",
+		},
+		{
+			sql:   `UPDATE t SET a = ?, b = ?, c = ?, d = ?`,
+			vars:  []interface{}{[]byte{0xde, 0xad}, false, time.Date(2021, 11, 1, 20, 0, 0, 500000000, cst), jsonutils.Marshal(map[string]string{"k": "v's"})},
+			style: SLiteralStyle{BooleanKeyword: true, ByteaHex: true},
+			want:  `UPDATE t SET a = '\xdead', b = FALSE, c = '2021-11-01 12:00:00.5', d = '{"k":"v''s"}'`,
+		},
+		{
+			sql:  `UPDATE t SET a = ?, b = ?, c = ?`,
+			vars: []interface{}{&tm, (*time.Time)(nil), jsonutils.NewString("v")},
+			want: `UPDATE t SET a = '2021-11-01 12:00:00', b = NULL, c = '"v"'`,
+		},
+		{
+			sql:  `INSERT INTO t VALUES (?, ?, ?, ?)`,
+			vars: []interface{}{[]byte{0x01}, sql.NullString{}, sql.NullInt64{Int64: 5, Valid: true}, 1.5},
+			want: `INSERT INTO t VALUES (X'01', NULL, 5, 1.5)`,
 		},
 	}
 	for _, c := range cases {
-		got := _sqlDebug(c.sql, c.vars)
+		got := c.style.Render(c.sql, c.vars)
 		if got != c.want {
 			t.Errorf("want: %s got: %s", c.want, got)
 		}
 	}
+	if got := SQLPrintf(cases[0].sql, cases[0].vars); got != cases[0].want {
+		t.Errorf("want: %s got: %s", cases[0].want, got)
+	}
 }
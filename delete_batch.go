@@ -15,62 +15,196 @@
 package sqlchemy
 
 import (
-	"fmt"
+	"context"
 	"reflect"
-	"strings"
+	"sort"
 
 	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
 )
 
-func (ts *STableSpec) getSQLFilters(filter map[string]interface{}, qChar string) ([]string, []interface{}) {
-	conds := make([]string, 0, len(filter))
-	params := make([]interface{}, 0, len(filter))
-	for k, v := range filter {
-		col := ts.ColumnSpec(k)
-		if col == nil {
-			log.Warningf("getSQLFilters: column %s not found", k)
+// SDeleteQuery is a builder of DELETE statement whose filters are arbitrary ICondition
+type SDeleteQuery struct {
+	table *STable
+	where ICondition
+}
+
+// DeleteQuery returns a DELETE statement builder of the table, the conditions
+// are built with the fields returned by Field of the builder, soft-delete scope
+// is not applied
+func (ts *STableSpec) DeleteQuery() *SDeleteQuery {
+	return &SDeleteQuery{table: ts.mutationInstance()}
+}
+
+// mutationInstance returns a table instance aliased with the table name,
+// so that its fields can be referred in UPDATE and DELETE statements
+func (ts *STableSpec) mutationInstance() *STable {
+	return &STable{spec: ts, alias: ts.name}
+}
+
+// Field returns the field of the target table with the given name
+func (dq *SDeleteQuery) Field(name string) IQueryField {
+	return dq.table.Field(name)
+}
+
+// Filter adds a condition to the WHERE clause of the DELETE statement
+func (dq *SDeleteQuery) Filter(cond ICondition) *SDeleteQuery {
+	if dq.where == nil {
+		dq.where = cond
+	} else {
+		dq.where = AND(dq.where, cond)
+	}
+	return dq
+}
+
+// String returns the SQL of the DELETE statement
+func (dq *SDeleteQuery) String() string {
+	return TemplateEval(dq.table.spec.Database().backend.DeleteSQLTemplate(), struct {
+		Table      string
+		Conditions string
+	}{
+		Table:      dq.table.spec.Name(),
+		Conditions: mutationWhereClause(dq.where),
+	})
+}
+
+// Variables returns the variables of the DELETE statement
+func (dq *SDeleteQuery) Variables() []interface{} {
+	if dq.where == nil {
+		return nil
+	}
+	return dq.where.Variables()
+}
+
+// Exec executes the DELETE statement and returns the number of affected rows
+func (dq *SDeleteQuery) Exec() (int64, error) {
+	return dq.ExecContext(context.Background())
+}
+
+// ExecContext is the context-aware variant of Exec
+func (dq *SDeleteQuery) ExecContext(ctx context.Context) (int64, error) {
+	return execMutation(ctx, dq.table.spec.Database(), dq.String(), dq.Variables())
+}
+
+func mutationWhereClause(where ICondition) string {
+	if where == nil {
+		return "1 = 1"
+	}
+	return where.WhereClause()
+}
+
+func execMutation(ctx context.Context, db *SDatabase, sqlstr string, vars []interface{}) (int64, error) {
+	if DEBUG_SQLCHEMY {
+		log.Infof("Exec: %s", _sqlDebug(db, sqlstr, vars))
+	}
+	results, err := db.TxExecContext(ctx, sqlstr, vars...)
+	if err != nil {
+		return 0, errors.Wrap(err, "TxExec")
+	}
+	if !db.backend.CanSupportRowAffected() {
+		return 0, nil
+	}
+	affected, err := results.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "RowsAffected")
+	}
+	return affected, nil
+}
+
+// filterConditions converts a map filter into conditions on the fields of tbl,
+// a slice value is converted into IN condition and an empty slice is ignored
+func filterConditions(tbl *STable, filters map[string]interface{}) ICondition {
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	conds := make([]ICondition, 0, len(keys))
+	for _, k := range keys {
+		v := filters[k]
+		if tbl.spec.ColumnSpec(k) == nil {
+			log.Warningf("filterConditions: column %s not found", k)
 			continue
 		}
-		if reflect.TypeOf(v).Kind() == reflect.Slice || reflect.TypeOf(v).Kind() == reflect.Array {
-			value := reflect.ValueOf(v)
-			if value.Len() == 0 {
+		kind := reflect.TypeOf(v).Kind()
+		if kind == reflect.Slice || kind == reflect.Array {
+			if reflect.ValueOf(v).Len() == 0 {
 				continue
 			}
-			arr := make([]string, value.Len())
-			for i := 0; i < value.Len(); i++ {
-				arr[i] = "?"
-				params = append(params, col.ConvertFromValue(value.Index(i).Interface()))
-			}
-			conds = append(conds, fmt.Sprintf("%s%s%s in (%s)", qChar, k, qChar, strings.Join(arr, ", ")))
+			conds = append(conds, In(tbl.Field(k), v))
 		} else {
-			conds = append(conds, fmt.Sprintf("%s%s%s = ?", qChar, k, qChar))
-			params = append(params, col.ConvertFromValue(v))
+			conds = append(conds, Equals(tbl.Field(k), v))
 		}
 	}
-	return conds, params
+	switch len(conds) {
+	case 0:
+		return nil
+	case 1:
+		return conds[0]
+	default:
+		return AND(conds...)
+	}
 }
 
+// DeleteFrom deletes the records matching filters, if the model implements
+// IBeforeDeleteHook or IAfterDeleteHook, the matching records are loaded and
+// deleted in one transaction and the hooks are called on each of them
 func (ts *STableSpec) DeleteFrom(filters map[string]interface{}) error {
-	buf := strings.Builder{}
-
-	qChar := ts.Database().backend.QuoteChar()
-
-	buf.WriteString("DELETE FROM ")
-	buf.WriteString(qChar)
-	buf.WriteString(ts.Name())
-	buf.WriteString(qChar)
-
-	conds, params := ts.getSQLFilters(filters, qChar)
-
-	if len(conds) > 0 {
-		buf.WriteString(" WHERE ")
-		buf.WriteString(strings.Join(conds, " AND "))
+	if !ts.hasHook(hookBeforeDelete, hookAfterDelete) {
+		return ts.deleteFrom(filters)
 	}
-
-	if DEBUG_SQLCHEMY {
-		log.Infof("Update: %s %s", buf.String(), params)
+	var records reflect.Value
+	deleteRecords := func(ts *STableSpec) error {
+		var err error
+		records, err = ts.fetchDeleteRecords(filters)
+		if err != nil {
+			return errors.Wrap(err, "fetchDeleteRecords")
+		}
+		for i := 0; i < records.Len(); i++ {
+			err := callHook(records.Index(i), hookBeforeDelete)
+			if err != nil {
+				return err
+			}
+		}
+		return ts.deleteFrom(filters)
+	}
+	var err error
+	if db := ts.Database(); db.tx != nil {
+		err = deleteRecords(ts)
+	} else {
+		err = db.RunInTx(context.Background(), func(tx *STx) error {
+			return deleteRecords(ts.InTx(tx))
+		})
+	}
+	if err != nil {
+		return err
+	}
+	// AfterDelete returns nothing, the records are already deleted
+	for i := 0; i < records.Len(); i++ {
+		callHook(records.Index(i), hookAfterDelete)
 	}
+	return nil
+}
 
-	_, err := ts.Database().TxExec(buf.String(), params...)
+func (ts *STableSpec) deleteFrom(filters map[string]interface{}) error {
+	dq := ts.DeleteQuery()
+	if cond := filterConditions(dq.table, filters); cond != nil {
+		dq.Filter(cond)
+	}
+	_, err := dq.Exec()
 	return err
 }
+
+func (ts *STableSpec) fetchDeleteRecords(filters map[string]interface{}) (reflect.Value, error) {
+	tbl := ts.Instance()
+	q := tbl.Query().Unscoped().UsePrimary()
+	if cond := filterConditions(tbl, filters); cond != nil {
+		q = q.Filter(cond)
+	}
+	records := reflect.New(reflect.SliceOf(ts.structType))
+	err := q.All(records.Interface())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return records.Elem(), nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// sDBError is a driver error classified by the backend, it matches the Error constant
+// of the class by errors.Is, and its Cause and Unwrap are the driver error
+type sDBError struct {
+	class error
+	err   error
+}
+
+func (e *sDBError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sDBError) Cause() error {
+	return e.err
+}
+
+func (e *sDBError) Unwrap() error {
+	return e.err
+}
+
+func (e *sDBError) Is(target error) bool {
+	return e.class == target
+}
+
+// sContextError is an error caused by a canceled or expired context, it matches ErrContextCanceled
+// by errors.Is and Cause, and unwraps to the error of the context, e.g. context.Canceled
+type sContextError struct {
+	ctxErr error
+	err    error
+}
+
+func (e *sContextError) Error() string {
+	if e.err == e.ctxErr {
+		return fmt.Sprintf("%s: %s", e.ctxErr, ErrContextCanceled)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.ctxErr, e.err, ErrContextCanceled)
+}
+
+func (e *sContextError) Cause() error {
+	return ErrContextCanceled
+}
+
+func (e *sContextError) Unwrap() error {
+	return e.ctxErr
+}
+
+func (e *sContextError) Is(target error) bool {
+	return target == ErrContextCanceled
+}
+
+// ClassifyError returns the Error constant that a driver error, or an error wrapping it, falls into, nil if unknown
+func (db *SDatabase) ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var dbErr *sDBError
+	if stderrors.As(err, &dbErr) {
+		return dbErr.class
+	}
+	switch cause := errors.Cause(err); cause {
+	case ErrDuplicateEntry, ErrForeignKeyViolation, ErrDeadlock, ErrLockTimeout, ErrDataTooLong, ErrConnectionLost, ErrTableNotExists:
+		return cause
+	}
+	return db.backend.ClassifyError(err)
+}
+
+// wrapError converts a driver error into ErrContextCanceled or a classified error
+func (db *SDatabase) wrapError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return wrapContextError(ctx, err)
+	}
+	if _, ok := err.(*sDBError); ok {
+		return err
+	}
+	class := db.backend.ClassifyError(err)
+	if class == nil {
+		return err
+	}
+	return &sDBError{class: class, err: err}
+}
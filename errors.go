@@ -25,6 +25,27 @@ const (
 	// ErrDuplicateEntry is an Error constant: duplicate entry
 	ErrDuplicateEntry = errors.Error("duplicate entry")
 
+	// ErrForeignKeyViolation is an Error constant: a foreign key constraint is violated
+	ErrForeignKeyViolation = errors.Error("foreign key violation")
+
+	// ErrDeadlock is an Error constant: a deadlock or serialization failure, the transaction can be retried
+	ErrDeadlock = errors.Error("deadlock or serialization failure")
+
+	// ErrLockTimeout is an Error constant: timeout waiting for a lock
+	ErrLockTimeout = errors.Error("lock timeout")
+
+	// ErrDataTooLong is an Error constant: the value is too long for the column
+	ErrDataTooLong = errors.Error("data too long")
+
+	// ErrConnectionLost is an Error constant: the connection to the database server is lost
+	ErrConnectionLost = errors.Error("connection lost")
+
+	// ErrOnlineChangeNotSupported is an Error constant: the table cannot be altered without blocking writes
+	ErrOnlineChangeNotSupported = errors.Error("online schema change not supported")
+
+	// ErrDataTruncated is an Error constant: narrowing a column would truncate the existing data
+	ErrDataTruncated = errors.Error("data would be truncated")
+
 	// ErrEmptyQuery is an Error constant: empty query
 	ErrEmptyQuery = errors.Error("empty query")
 
@@ -54,4 +75,31 @@ const (
 
 	// ErrUnionDatabasesNotMatch is an Error constant: backend database of union queries not match
 	ErrUnionAcrossDatabases = errors.Error("cannot union across different databases")
+
+	// ErrContextCanceled is an Error constant: the context of a query is canceled or its deadline exceeded
+	ErrContextCanceled = errors.Error("context canceled")
+
+	// ErrInvalidPageCursor is an Error constant: the cursor of keyset pagination does not match the query
+	ErrInvalidPageCursor = errors.Error("invalid page cursor")
+
+	// ErrStopIteration is an Error constant: returned by the callback of SQuery.Iterate to stop the iteration early
+	ErrStopIteration = errors.Error("stop iteration")
+
+	// ErrNotSoftDeletable is an Error constant: the table has no soft-delete column
+	ErrNotSoftDeletable = errors.Error("table not soft-deletable")
+
+	// ErrUnknownColumn is an Error constant: the column does not exist in the table
+	ErrUnknownColumn = errors.Error("unknown column")
+
+	// ErrMigrationChecksumMismatch is an Error constant: an applied migration differs from the registered one
+	ErrMigrationChecksumMismatch = errors.Error("migration checksum mismatch")
+
+	// ErrMigrationLocked is an Error constant: the migration lock is held by another migrator
+	ErrMigrationLocked = errors.Error("migration locked")
+
+	// ErrConcurrentModification is an Error constant: the record was modified by others since it was loaded
+	ErrConcurrentModification = errors.Error("concurrent modification")
+
+	// ErrCTENotSupported is an Error constant: the backend does not support recursive common table expressions
+	ErrCTENotSupported = errors.Error("recursive common table expression not supported")
 )
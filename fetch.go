@@ -15,6 +15,7 @@
 package sqlchemy
 
 import (
+	"context"
 	"reflect"
 
 	"yunion.io/x/log"
@@ -24,9 +25,14 @@ import (
 )
 
 // Fetch method fetches the values of a struct whose primary key values have been set
-// input is a pointer to the model to be populated
+// input is a pointer to the model to be populated, soft-deleted records are fetched as well
 func (ts *STableSpec) Fetch(dt interface{}) error {
-	q := ts.Query()
+	return ts.FetchContext(context.Background(), dt)
+}
+
+// FetchContext is the context-aware variant of Fetch
+func (ts *STableSpec) FetchContext(ctx context.Context, dt interface{}) error {
+	q := ts.Query().Unscoped()
 	dataValue := reflect.ValueOf(dt).Elem()
 	fields := reflectutils.FetchStructFieldValueSet(dataValue)
 	for _, c := range ts.Columns() {
@@ -35,12 +41,17 @@ func (ts *STableSpec) Fetch(dt interface{}) error {
 			q = q.Equals(c.Name(), priVal)
 		}
 	}
-	return q.First(dt)
+	return q.FirstContext(ctx, dt)
 }
 
 // FetchAll method fetches the values of an array of structs whose primary key values have been set
-// input is a pointer to the array of models to be populated
+// input is a pointer to the array of models to be populated, soft-deleted records are fetched as well
 func (ts *STableSpec) FetchAll(dest interface{}) error {
+	return ts.FetchAllContext(context.Background(), dest)
+}
+
+// FetchAllContext is the context-aware variant of FetchAll
+func (ts *STableSpec) FetchAllContext(ctx context.Context, dest interface{}) error {
 	arrayType := reflect.TypeOf(dest).Elem()
 	if arrayType.Kind() != reflect.Array && arrayType.Kind() != reflect.Slice {
 		return errors.Wrap(ErrNeedsArray, "dest is not an array or slice")
@@ -60,9 +71,9 @@ func (ts *STableSpec) FetchAll(dest interface{}) error {
 		fields := reflectutils.FetchStructFieldValueSet(eleValue)
 		keyValues[i], _ = fields.GetInterface(primaryCol.Name())
 	}
-	q := ts.Query().In(primaryCol.Name(), keyValues)
+	q := ts.Query().Unscoped().In(primaryCol.Name(), keyValues)
 
-	tmpDestMaps, err := q.AllStringMap()
+	tmpDestMaps, err := q.AllStringMapContext(ctx)
 	if err != nil {
 		return errors.Wrap(err, "q.AllStringMap")
 	}
@@ -16,6 +16,7 @@ package sqlchemy
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 
@@ -28,7 +29,12 @@ import (
 // UpdateFields update a record with the values provided by fields stringmap
 // params dt: model struct, fileds: {struct-field-name-string: update-value}
 func (ts *STableSpec) UpdateFields(dt interface{}, fields map[string]interface{}) error {
-	return ts.updateFields(dt, fields, false)
+	return ts.UpdateFieldsContext(context.Background(), dt, fields)
+}
+
+// UpdateFieldsContext is the context-aware variant of UpdateFields
+func (ts *STableSpec) UpdateFieldsContext(ctx context.Context, dt interface{}, fields map[string]interface{}) error {
+	return ts.updateFields(ctx, dt, fields, false)
 }
 
 // params dt: model struct, fileds: {struct-field-name-string: update-value}
@@ -44,8 +50,10 @@ func (ts *STableSpec) updateFieldSql(dt interface{}, fields map[string]interface
 
 	fullFields := reflectutils.FetchStructFieldValueSet(dataValue)
 	versionFields := make([]string, 0)
+	versions := make([]sPrimaryKeyValue, 0)
 	updatedFields := make([]string, 0)
 	primaryCols := make([]sPrimaryKeyValue, 0)
+	setters := make([]SUpdateDiff, 0)
 	for _, col := range ts.Columns() {
 		name := col.Name()
 		colValue, ok := fullFields.GetInterface(name)
@@ -70,6 +78,12 @@ func (ts *STableSpec) updateFieldSql(dt interface{}, fields map[string]interface
 		}
 		if col.IsAutoVersion() {
 			versionFields = append(versionFields, name)
+			if col.IsOptimisticLock() {
+				versions = append(versions, sPrimaryKeyValue{
+					key:   name,
+					value: col.ConvertFromValue(colValue),
+				})
+			}
 			continue
 		}
 		if col.IsUpdatedAt() {
@@ -79,6 +93,7 @@ func (ts *STableSpec) updateFieldSql(dt interface{}, fields map[string]interface
 		if _, exist := fields[name]; exist {
 			cv[name] = col.ConvertFromValue(fields[name])
 			cnames = append(cnames, name)
+			setters = append(setters, SUpdateDiff{old: colValue, new: fields[name], col: col})
 		}
 	}
 
@@ -113,6 +128,10 @@ func (ts *STableSpec) updateFieldSql(dt interface{}, fields map[string]interface
 		buf.WriteString(fmt.Sprintf("%s%s%s = ?", qChar, pkv.key, qChar))
 		vars = append(vars, pkv.value)
 	}
+	for _, ver := range versions {
+		buf.WriteString(fmt.Sprintf(" AND %s%s%s = ?", qChar, ver.key, qChar))
+		vars = append(vars, ver.value)
+	}
 
 	if DEBUG_SQLCHEMY || debug {
 		log.Infof("Update: %s", buf.String())
@@ -121,20 +140,27 @@ func (ts *STableSpec) updateFieldSql(dt interface{}, fields map[string]interface
 	return &SUpdateSQLResult{
 		Sql:       buf.String(),
 		Vars:      vars,
+		setters:   setters,
 		primaries: primaryCols,
+		versions:  versions,
 	}, nil
 }
 
-func (ts *STableSpec) updateFields(dt interface{}, fields map[string]interface{}, debug bool) error {
+func (ts *STableSpec) updateFields(ctx context.Context, dt interface{}, fields map[string]interface{}, debug bool) error {
+	err := callHook(reflect.ValueOf(dt), hookBeforeUpdate)
+	if err != nil {
+		return err
+	}
+
 	results, err := ts.updateFieldSql(dt, fields, debug)
 	if err != nil {
 		return errors.Wrap(err, "updateFieldSql")
 	}
 
-	err = ts.execUpdateSql(dt, results)
+	err = ts.execUpdateSql(ctx, dt, results)
 	if err != nil {
 		return errors.Wrap(err, "execUpdateSql")
 	}
 
-	return nil
+	return callHook(reflect.ValueOf(dt), hookAfterUpdate, updateDiffList2Map(results.setters))
 }
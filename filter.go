@@ -33,6 +33,17 @@ func (tq *SQuery) Filter(cond ICondition) *SQuery {
 	return tq
 }
 
+// whereCondition returns the conditions in WHERE clause, including the condition that excludes soft-deleted rows
+func (tq *SQuery) whereCondition() ICondition {
+	if tq.softDelete == nil {
+		return tq.where
+	}
+	if tq.where == nil {
+		return tq.softDelete
+	}
+	return AND(tq.softDelete, tq.where)
+}
+
 // FilterByTrue filters query with a true condition
 func (tq *SQuery) FilterByTrue() *SQuery {
 	return tq.Filter(&STrueCondition{})
@@ -6,6 +6,7 @@ require (
 	github.com/ClickHouse/clickhouse-go v1.5.4
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/golang-plus/uuid v1.0.0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.19
 	golang.org/x/text v0.3.7
 	yunion.io/x/jsonutils v0.0.0-20220106020632-953b71a4c3a8
@@ -0,0 +1,155 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"reflect"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// Model structs may implement the following optional interfaces to hook into
+// the lifecycle of a record. A Before* hook without the error return value is
+// also accepted, e.g. the legacy form BeforeInsert().
+
+// IBeforeInsertHook is called before a record is inserted, an error aborts the insert
+type IBeforeInsertHook interface {
+	BeforeInsert() error
+}
+
+// IAfterInsertHook is called after a record is inserted and reloaded from database
+type IAfterInsertHook interface {
+	AfterInsert()
+}
+
+// IBeforeUpdateHook is called before a record is updated, an error aborts the update
+type IBeforeUpdateHook interface {
+	BeforeUpdate() error
+}
+
+// IAfterUpdateHook is called after a record is updated with the changed columns
+type IAfterUpdateHook interface {
+	AfterUpdate(diffs UpdateDiffs)
+}
+
+// IBeforeDeleteHook is called on each matching record before DeleteFrom, an error aborts the delete
+type IBeforeDeleteHook interface {
+	BeforeDelete() error
+}
+
+// IAfterDeleteHook is called on each matching record after DeleteFrom
+type IAfterDeleteHook interface {
+	AfterDelete()
+}
+
+type iLegacyBeforeInsertHook interface {
+	BeforeInsert()
+}
+
+type iLegacyBeforeUpdateHook interface {
+	BeforeUpdate()
+}
+
+type iLegacyBeforeDeleteHook interface {
+	BeforeDelete()
+}
+
+const (
+	hookBeforeInsert = "BeforeInsert"
+	hookAfterInsert  = "AfterInsert"
+	hookBeforeUpdate = "BeforeUpdate"
+	hookAfterUpdate  = "AfterUpdate"
+	hookBeforeDelete = "BeforeDelete"
+	hookAfterDelete  = "AfterDelete"
+)
+
+// hookTypes are the interfaces implementing each hook
+var hookTypes = map[string][]reflect.Type{
+	hookBeforeInsert: {reflect.TypeOf((*IBeforeInsertHook)(nil)).Elem(), reflect.TypeOf((*iLegacyBeforeInsertHook)(nil)).Elem()},
+	hookAfterInsert:  {reflect.TypeOf((*IAfterInsertHook)(nil)).Elem()},
+	hookBeforeUpdate: {reflect.TypeOf((*IBeforeUpdateHook)(nil)).Elem(), reflect.TypeOf((*iLegacyBeforeUpdateHook)(nil)).Elem()},
+	hookAfterUpdate:  {reflect.TypeOf((*IAfterUpdateHook)(nil)).Elem()},
+	hookBeforeDelete: {reflect.TypeOf((*IBeforeDeleteHook)(nil)).Elem(), reflect.TypeOf((*iLegacyBeforeDeleteHook)(nil)).Elem()},
+	hookAfterDelete:  {reflect.TypeOf((*IAfterDeleteHook)(nil)).Elem()},
+}
+
+// callHook calls the hook of the given name if val implements it, the error
+// returned by the hook, if any, is returned. AfterUpdate is called with diffs
+func callHook(val reflect.Value, name string, diffs ...UpdateDiffs) error {
+	if val.Kind() != reflect.Ptr && val.CanAddr() {
+		val = val.Addr()
+	}
+	if !val.IsValid() || !val.CanInterface() || (val.Kind() == reflect.Ptr && val.IsNil()) {
+		return nil
+	}
+	obj := val.Interface()
+	var err error
+	switch name {
+	case hookBeforeInsert:
+		if hook, ok := obj.(IBeforeInsertHook); ok {
+			err = hook.BeforeInsert()
+		} else if hook, ok := obj.(iLegacyBeforeInsertHook); ok {
+			hook.BeforeInsert()
+		}
+	case hookAfterInsert:
+		if hook, ok := obj.(IAfterInsertHook); ok {
+			hook.AfterInsert()
+		}
+	case hookBeforeUpdate:
+		if hook, ok := obj.(IBeforeUpdateHook); ok {
+			err = hook.BeforeUpdate()
+		} else if hook, ok := obj.(iLegacyBeforeUpdateHook); ok {
+			hook.BeforeUpdate()
+		}
+	case hookAfterUpdate:
+		if hook, ok := obj.(IAfterUpdateHook); ok {
+			var d UpdateDiffs
+			if len(diffs) > 0 {
+				d = diffs[0]
+			}
+			hook.AfterUpdate(d)
+		}
+	case hookBeforeDelete:
+		if hook, ok := obj.(IBeforeDeleteHook); ok {
+			err = hook.BeforeDelete()
+		} else if hook, ok := obj.(iLegacyBeforeDeleteHook); ok {
+			hook.BeforeDelete()
+		}
+	case hookAfterDelete:
+		if hook, ok := obj.(IAfterDeleteHook); ok {
+			hook.AfterDelete()
+		}
+	}
+	if err != nil {
+		return errors.Wrap(err, name)
+	}
+	return nil
+}
+
+// hasHook returns wether the model type of the table implements one of the hooks
+func (ts *STableSpec) hasHook(names ...string) bool {
+	if ts.structType == nil {
+		return false
+	}
+	ptrType := reflect.PtrTo(ts.structType)
+	for _, name := range names {
+		for _, hookType := range hookTypes[name] {
+			if ptrType.Implements(hookType) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -16,6 +16,7 @@ package sqlchemy
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 
@@ -30,19 +31,29 @@ import (
 // if target is given as a pointer to a variable, the result will be stored in the target
 // if target is not given, the updated result will be stored in diff
 func (t *STableSpec) Increment(diff interface{}, target interface{}) error {
+	return t.IncrementContext(context.Background(), diff, target)
+}
+
+// IncrementContext is the context-aware variant of Increment
+func (t *STableSpec) IncrementContext(ctx context.Context, diff interface{}, target interface{}) error {
 	if !t.Database().backend.CanUpdate() {
 		return errors.ErrNotSupported
 	}
-	return t.incrementInternal(diff, "+", target)
+	return t.incrementInternal(ctx, diff, "+", target)
 }
 
 // Decrement is similar to Increment methods, the difference is that this method will atomically decrease the numeric fields
 // with the value of diff
 func (t *STableSpec) Decrement(diff interface{}, target interface{}) error {
+	return t.DecrementContext(context.Background(), diff, target)
+}
+
+// DecrementContext is the context-aware variant of Decrement
+func (t *STableSpec) DecrementContext(ctx context.Context, diff interface{}, target interface{}) error {
 	if !t.Database().backend.CanUpdate() {
 		return errors.ErrNotSupported
 	}
-	return t.incrementInternal(diff, "-", target)
+	return t.incrementInternal(ctx, diff, "-", target)
 }
 
 func (t *STableSpec) incrementInternalSql(diff interface{}, opcode string, target interface{}) (*SUpdateSQLResult, error) {
@@ -144,7 +155,7 @@ func (t *STableSpec) incrementInternalSql(diff interface{}, opcode string, targe
 	}, nil
 }
 
-func (t *STableSpec) incrementInternal(diff interface{}, opcode string, target interface{}) error {
+func (t *STableSpec) incrementInternal(ctx context.Context, diff interface{}, opcode string, target interface{}) error {
 	if target == nil {
 		if reflect.ValueOf(diff).Kind() != reflect.Ptr {
 			return errors.Wrap(ErrNeedsPointer, "Incremental input must be a Pointer")
@@ -158,9 +169,9 @@ func (t *STableSpec) incrementInternal(diff interface{}, opcode string, target i
 	intResult, err := t.incrementInternalSql(diff, opcode, target)
 
 	if target != nil {
-		err = t.execUpdateSql(target, intResult)
+		err = t.execUpdateSql(ctx, target, intResult)
 	} else {
-		err = t.execUpdateSql(diff, intResult)
+		err = t.execUpdateSql(ctx, diff, intResult)
 	}
 	if err != nil {
 		return errors.Wrap(err, "query after update failed")
@@ -16,28 +16,110 @@ package sqlchemy
 
 import (
 	"fmt"
+	"hash/crc32"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	// INDEX_TYPE_FULLTEXT is the type of a fulltext index, MySQL only
+	INDEX_TYPE_FULLTEXT = "FULLTEXT"
+	// INDEX_TYPE_SPATIAL is the type of a spatial index, MySQL only
+	INDEX_TYPE_SPATIAL = "SPATIAL"
 )
 
+// TIndexFeature is an optional feature of index definitions that a backend may support
+type TIndexFeature int
+
+const (
+	// INDEX_FEATURE_PREFIX indicates indexing a prefix of the column values, e.g. `name`(16)
+	INDEX_FEATURE_PREFIX TIndexFeature = 1 << iota
+	// INDEX_FEATURE_DESC indicates indexing columns in descending order
+	INDEX_FEATURE_DESC
+	// INDEX_FEATURE_EXPRESSION indicates indexing an expression instead of columns
+	INDEX_FEATURE_EXPRESSION
+	// INDEX_FEATURE_PARTIAL indicates indexing the rows satisfying a WHERE predicate
+	INDEX_FEATURE_PARTIAL
+	// INDEX_FEATURE_FULLTEXT indicates the INDEX_TYPE_FULLTEXT index type
+	INDEX_FEATURE_FULLTEXT
+	// INDEX_FEATURE_SPATIAL indicates the INDEX_TYPE_SPATIAL index type
+	INDEX_FEATURE_SPATIAL
+)
+
+// SIndexOptions are the options of an index besides its columns
+type SIndexOptions struct {
+	// Name of the index, generated from the table and columns if empty
+	Name string
+	// Unique indicates a unique index
+	Unique bool
+	// Type is the index type, e.g. INDEX_TYPE_FULLTEXT
+	Type string
+	// Prefixes are the lengths of the indexed prefixes of columns
+	Prefixes map[string]int
+	// Descending are the columns indexed in descending order
+	Descending []string
+	// Expression is the expression of a functional index, which takes the place of columns
+	Expression string
+	// Where is the predicate of a partial index
+	Where string
+}
+
 type STableIndex struct {
 	name    string
 	columns []string
 
 	isUnique bool
 
+	indexType  string
+	prefixes   map[string]int
+	descending map[string]bool
+	expression string
+	where      string
+
 	ts ITableSpec
 }
 
 func NewTableIndex(ts ITableSpec, name string, cols []string, unique bool) STableIndex {
+	return NewTableIndexWithOptions(ts, cols, SIndexOptions{Name: name, Unique: unique})
+}
+
+// NewTableIndexWithOptions returns an index of cols with options
+func NewTableIndexWithOptions(ts ITableSpec, cols []string, opts SIndexOptions) STableIndex {
 	sort.Sort(TColumnNames(cols))
-	return STableIndex{
-		name:    name,
+	index := STableIndex{
+		name:    opts.Name,
 		columns: cols,
 
-		isUnique: unique,
-		ts:       ts,
+		isUnique: opts.Unique,
+
+		indexType:  strings.ToUpper(opts.Type),
+		expression: strings.TrimSpace(opts.Expression),
+		where:      strings.TrimSpace(opts.Where),
+		ts:         ts,
+	}
+	for col, length := range opts.Prefixes {
+		if length > 0 {
+			if index.prefixes == nil {
+				index.prefixes = make(map[string]int)
+			}
+			index.prefixes[col] = length
+		}
 	}
+	for _, col := range opts.Descending {
+		if index.descending == nil {
+			index.descending = make(map[string]bool)
+		}
+		index.descending[col] = true
+	}
+	if len(index.expression) > 0 {
+		index.columns = nil
+	}
+	return index
 }
 
 type TColumnNames []string
@@ -65,14 +147,104 @@ func (index *STableIndex) Name() string {
 		return index.name
 	}
 	name := fmt.Sprintf("ix_%s_%s", index.ts.Name(), strings.Join(index.columns, "_"))
+	if len(index.expression) > 0 || len(index.where) > 0 {
+		// expressions and predicates are not fit for names, distinguish the index by a checksum of them
+		sum := crc32.ChecksumIEEE([]byte(normalizeIndexExpression(index.expression) + "|" + normalizeIndexExpression(index.where)))
+		name = strings.TrimSuffix(name, "_")
+		suffix := fmt.Sprintf("_%08x", sum)
+		if len(name)+len(suffix) > IndexLimit {
+			name = name[:IndexLimit-len(suffix)]
+		}
+		return name + suffix
+	}
 	if len(name) > IndexLimit {
 		name = name[:IndexLimit]
 	}
 	return name
 }
 
+// Columns returns the indexed columns, empty for a functional index
+func (index *STableIndex) Columns() []string {
+	return index.columns
+}
+
+// IsUnique returns whether it is a unique index
+func (index *STableIndex) IsUnique() bool {
+	return index.isUnique
+}
+
+// Type returns the index type, e.g. INDEX_TYPE_FULLTEXT, empty for an ordinary index
+func (index *STableIndex) Type() string {
+	return index.indexType
+}
+
+// Prefix returns the length of the indexed prefix of a column, 0 for the whole value
+func (index *STableIndex) Prefix(col string) int {
+	return index.prefixes[col]
+}
+
+// IsDescending returns whether a column is indexed in descending order
+func (index *STableIndex) IsDescending(col string) bool {
+	return index.descending[col]
+}
+
+// Expression returns the expression of a functional index
+func (index *STableIndex) Expression() string {
+	return index.expression
+}
+
+// Where returns the predicate of a partial index
+func (index *STableIndex) Where() string {
+	return index.where
+}
+
+func (index *STableIndex) options() SIndexOptions {
+	opts := SIndexOptions{
+		Name:       index.name,
+		Unique:     index.isUnique,
+		Type:       index.indexType,
+		Prefixes:   index.prefixes,
+		Expression: index.expression,
+		Where:      index.where,
+	}
+	for col := range index.descending {
+		opts.Descending = append(opts.Descending, col)
+	}
+	return opts
+}
+
 func (index STableIndex) clone(ts ITableSpec) STableIndex {
-	return NewTableIndex(ts, "", index.columns, index.isUnique)
+	opts := index.options()
+	opts.Name = ""
+	return NewTableIndexWithOptions(ts, append([]string{}, index.columns...), opts)
+}
+
+// supportedBy returns the index with the features unsupported by the backend of the table removed,
+// false if the index cannot be created without them
+func (index STableIndex) supportedBy(ts ITableSpec, backend IBackend) (STableIndex, bool) {
+	if len(index.expression) > 0 && !backend.IsSupportIndexFeature(ts, INDEX_FEATURE_EXPRESSION) {
+		return index, false
+	}
+	if len(index.where) > 0 && !backend.IsSupportIndexFeature(ts, INDEX_FEATURE_PARTIAL) {
+		return index, false
+	}
+	if len(index.prefixes) > 0 && !backend.IsSupportIndexFeature(ts, INDEX_FEATURE_PREFIX) {
+		index.prefixes = nil
+	}
+	if len(index.descending) > 0 && !backend.IsSupportIndexFeature(ts, INDEX_FEATURE_DESC) {
+		index.descending = nil
+	}
+	switch index.indexType {
+	case INDEX_TYPE_FULLTEXT:
+		if !backend.IsSupportIndexFeature(ts, INDEX_FEATURE_FULLTEXT) {
+			index.indexType = ""
+		}
+	case INDEX_TYPE_SPATIAL:
+		if !backend.IsSupportIndexFeature(ts, INDEX_FEATURE_SPATIAL) {
+			index.indexType = ""
+		}
+	}
+	return index, true
 }
 
 func (index *STableIndex) IsIdentical(cols ...string) bool {
@@ -88,6 +260,36 @@ func (index *STableIndex) IsIdentical(cols ...string) bool {
 	return true
 }
 
+// IsEquivalent returns whether two indexes have the same definition, regardless of names,
+// expressions and predicates are compared loosely as databases rewrite them
+func (index *STableIndex) IsEquivalent(o STableIndex) bool {
+	if !index.IsIdentical(append([]string{}, o.columns...)...) {
+		return false
+	}
+	if index.isUnique != o.isUnique || index.indexType != o.indexType {
+		return false
+	}
+	for _, col := range index.columns {
+		if index.prefixes[col] != o.prefixes[col] || index.descending[col] != o.descending[col] {
+			return false
+		}
+	}
+	return normalizeIndexExpression(index.expression) == normalizeIndexExpression(o.expression) &&
+		normalizeIndexExpression(index.where) == normalizeIndexExpression(o.where)
+}
+
+var (
+	indexCastRegexp  = regexp.MustCompile(`::\s*[a-z_]+( varying)?(\[\])?`)
+	indexSpaceRegexp = regexp.MustCompile(`[\s()` + "`" + `"]+`)
+)
+
+// normalizeIndexExpression strips quotes, parentheses, spaces and type casts of an expression for comparison
+func normalizeIndexExpression(expr string) string {
+	expr = strings.ToLower(expr)
+	expr = indexCastRegexp.ReplaceAllString(expr, "")
+	return indexSpaceRegexp.ReplaceAllString(expr, "")
+}
+
 func (index *STableIndex) QuotedColumns(quoteStr string) []string {
 	ret := make([]string, len(index.columns))
 	for i := 0; i < len(ret); i++ {
@@ -96,6 +298,153 @@ func (index *STableIndex) QuotedColumns(quoteStr string) []string {
 	return ret
 }
 
+// KeyParts returns the key parts of the index quoted with quoteStr, with the prefix lengths and
+// orders of columns, or the parenthesized expression of a functional index
+func (index *STableIndex) KeyParts(quoteStr string) []string {
+	if len(index.expression) > 0 {
+		return []string{"(" + index.expression + ")"}
+	}
+	ret := index.QuotedColumns(quoteStr)
+	for i, col := range index.columns {
+		if length := index.prefixes[col]; length > 0 {
+			ret[i] += fmt.Sprintf("(%d)", length)
+		}
+		if index.descending[col] {
+			ret[i] += " DESC"
+		}
+	}
+	return ret
+}
+
+// SplitParenthesized returns the content in the leading parentheses of str and the remaining string
+func SplitParenthesized(str string) (string, string, bool) {
+	if len(str) == 0 || str[0] != '(' {
+		return "", str, false
+	}
+	depth := 0
+	var quote byte
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return str[1:i], str[i+1:], true
+			}
+		}
+	}
+	return "", str, false
+}
+
+// splitKeyParts splits the key parts of an index at the top-level commas
+func splitKeyParts(str string) []string {
+	ret := make([]string, 0)
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			ret = append(ret, strings.TrimSpace(str[start:i]))
+			start = i + 1
+		}
+	}
+	if part := strings.TrimSpace(str[start:]); len(part) > 0 {
+		ret = append(ret, part)
+	}
+	return ret
+}
+
+var (
+	keyPartOrderRegexp  = regexp.MustCompile(`(?i)\s+(ASC|DESC)$`)
+	keyPartColumnRegexp = regexp.MustCompile("^[`\"]?(\\w+)[`\"]?(\\((\\d+)\\))?$")
+)
+
+// NewTableIndexFromKeyParts parses the key parts of an index definition, e.g. `name`(16) DESC, `age`,
+// into an index with options, a part other than a column makes a functional index
+func NewTableIndexFromKeyParts(ts ITableSpec, keyParts string, opts SIndexOptions) STableIndex {
+	cols := make([]string, 0)
+	exprs := make([]string, 0)
+	isExpr := false
+	for _, part := range splitKeyParts(keyParts) {
+		desc := false
+		if m := keyPartOrderRegexp.FindStringSubmatch(part); m != nil {
+			desc = strings.ToUpper(m[1]) == "DESC"
+			part = part[:len(part)-len(m[0])]
+		}
+		if m := keyPartColumnRegexp.FindStringSubmatch(part); m != nil {
+			cols = append(cols, m[1])
+			if len(m[3]) > 0 {
+				if opts.Prefixes == nil {
+					opts.Prefixes = make(map[string]int)
+				}
+				opts.Prefixes[m[1]], _ = strconv.Atoi(m[3])
+			}
+			if desc {
+				opts.Descending = append(opts.Descending, m[1])
+			}
+		} else {
+			isExpr = true
+			if inner, rest, ok := SplitParenthesized(part); ok && len(strings.TrimSpace(rest)) == 0 {
+				part = inner
+			}
+		}
+		exprs = append(exprs, part)
+	}
+	if isExpr {
+		opts.Expression = strings.Join(exprs, ", ")
+		opts.Prefixes = nil
+		opts.Descending = nil
+	}
+	return NewTableIndexWithOptions(ts, cols, opts)
+}
+
+var createIndexRegexp = regexp.MustCompile("(?is)^\\s*CREATE\\s+(UNIQUE\\s+)?INDEX\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?[`\"]?(\\w+)[`\"]?\\s+ON\\s+(?:ONLY\\s+)?(?:[\\w`\"]+\\.)?[`\"]?\\w+[`\"]?\\s*(?:USING\\s+\\w+\\s*)?")
+
+// ParseCreateIndexSQL parses a CREATE INDEX statement, as stored by sqlite or returned by pg_get_indexdef,
+// into an index
+func ParseCreateIndexSQL(ts ITableSpec, sql string) (STableIndex, error) {
+	loc := createIndexRegexp.FindStringSubmatchIndex(sql)
+	if loc == nil {
+		return STableIndex{}, errors.Wrap(errors.ErrNotFound, sql)
+	}
+	keyParts, rest, ok := SplitParenthesized(sql[loc[1]:])
+	if !ok {
+		return STableIndex{}, errors.Wrapf(errors.ErrInvalidStatus, "unbalanced parentheses %s", sql)
+	}
+	opts := SIndexOptions{
+		Name:   sql[loc[4]:loc[5]],
+		Unique: loc[2] >= 0,
+	}
+	rest = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), ";"))
+	if len(rest) > 6 && strings.EqualFold(rest[:6], "WHERE ") {
+		opts.Where = strings.TrimSpace(rest[6:])
+		if inner, tail, ok := SplitParenthesized(opts.Where); ok && len(strings.TrimSpace(tail)) == 0 {
+			opts.Where = inner
+		}
+	}
+	return NewTableIndexFromKeyParts(ts, keyParts, opts), nil
+}
+
 // AddIndex adds a SQL index over multiple columns for a Table
 // param unique: indicates a unique index cols: name of columns
 func (ts *STableSpec) addIndexWithName(name string, unique bool, cols ...string) bool {
@@ -112,3 +461,36 @@ func (ts *STableSpec) addIndexWithName(name string, unique bool, cols ...string)
 func (ts *STableSpec) AddIndex(unique bool, cols ...string) bool {
 	return ts.addIndexWithName("", unique, cols...)
 }
+
+// AddIndexWithOptions adds an index over cols with options, e.g. prefix lengths, orders, type,
+// or a functional index over opts.Expression, or a partial index with opts.Where
+func (ts *STableSpec) AddIndexWithOptions(opts SIndexOptions, cols ...string) bool {
+	idx := NewTableIndexWithOptions(ts, cols, opts)
+	for i := 0; i < len(ts._indexes); i++ {
+		if ts._indexes[i].IsEquivalent(idx) {
+			return false
+		}
+	}
+	ts._indexes = append(ts._indexes, idx)
+	return true
+}
+
+// backendIndexes returns the indexes declared of the table with features supported by the backend
+func (ts *STableSpec) backendIndexes() []STableIndex {
+	// the indexes of columns are declared by parsing the columns
+	ts.Columns()
+	db := ts.Database()
+	if db == nil || db.backend == nil {
+		return ts._indexes
+	}
+	ret := make([]STableIndex, 0, len(ts._indexes))
+	for i := range ts._indexes {
+		idx, ok := ts._indexes[i].supportedBy(ts, db.backend)
+		if !ok {
+			log.Warningf("index %s of table %s is not supported by %s, skip", idx.Name(), ts.name, db.backend.Name())
+			continue
+		}
+		ret = append(ret, idx)
+	}
+	return ret
+}
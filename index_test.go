@@ -0,0 +1,114 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCreateIndexSQL(t *testing.T) {
+	cases := []struct {
+		in   string
+		want SIndexOptions
+		cols []string
+	}{
+		{
+			in:   "CREATE INDEX `ix_t_name` ON `t` (`name`)",
+			want: SIndexOptions{Name: "ix_t_name"},
+			cols: []string{"name"},
+		},
+		{
+			in:   "CREATE UNIQUE INDEX public_ix ON public.t USING btree (name DESC, age) WHERE (deleted = false)",
+			want: SIndexOptions{Name: "public_ix", Unique: true, Descending: []string{"name"}, Where: "deleted = false"},
+			cols: []string{"age", "name"},
+		},
+		{
+			in:   "CREATE INDEX ix_lower ON public.t USING btree (lower((email)::text))",
+			want: SIndexOptions{Name: "ix_lower", Expression: "lower((email)::text)"},
+		},
+		{
+			in:   "CREATE INDEX `ix_t_expr` ON `t` ((lower(`email`))) WHERE `deleted` = 0",
+			want: SIndexOptions{Name: "ix_t_expr", Expression: "lower(`email`)", Where: "`deleted` = 0"},
+		},
+	}
+	for _, c := range cases {
+		got, err := ParseCreateIndexSQL(nil, c.in)
+		if err != nil {
+			t.Errorf("ParseCreateIndexSQL %s fail %s", c.in, err)
+			continue
+		}
+		want := NewTableIndexWithOptions(nil, c.cols, c.want)
+		if got.Name() != want.Name() || !got.IsEquivalent(want) || got.Expression() != want.Expression() || got.Where() != want.Where() {
+			t.Errorf("%s: want %#v got %#v", c.in, want, got)
+		}
+	}
+}
+
+func TestIndexIsEquivalent(t *testing.T) {
+	cases := []struct {
+		a, b SIndexOptions
+		want bool
+	}{
+		{
+			a:    SIndexOptions{Expression: "lower(email)"},
+			b:    SIndexOptions{Expression: "lower((email)::text)"},
+			want: true,
+		},
+		{
+			a:    SIndexOptions{Where: "deleted = false"},
+			b:    SIndexOptions{Where: "(deleted = false)"},
+			want: true,
+		},
+		{
+			a:    SIndexOptions{Unique: true},
+			b:    SIndexOptions{},
+			want: false,
+		},
+		{
+			a:    SIndexOptions{Prefixes: map[string]int{"name": 16}},
+			b:    SIndexOptions{Prefixes: map[string]int{"name": 32}},
+			want: false,
+		},
+		{
+			a:    SIndexOptions{Type: "fulltext"},
+			b:    SIndexOptions{Type: INDEX_TYPE_FULLTEXT},
+			want: true,
+		},
+	}
+	for _, c := range cases {
+		a := NewTableIndexWithOptions(nil, []string{"name"}, c.a)
+		b := NewTableIndexWithOptions(nil, []string{"name"}, c.b)
+		if got := a.IsEquivalent(b); got != c.want {
+			t.Errorf("%#v vs %#v want %v got %v", c.a, c.b, c.want, got)
+		}
+	}
+}
+
+func TestIndexKeyParts(t *testing.T) {
+	idx := NewTableIndexWithOptions(nil, []string{"name", "age"}, SIndexOptions{
+		Prefixes:   map[string]int{"name": 16},
+		Descending: []string{"age"},
+	})
+	want := []string{"`age` DESC", "`name`(16)"}
+	if got := idx.KeyParts("`"); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %s got %s", want, got)
+	}
+	idx = NewTableIndexWithOptions(nil, nil, SIndexOptions{Expression: "lower(`email`)"})
+	want = []string{"(lower(`email`))"}
+	if got := idx.KeyParts("`"); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %s got %s", want, got)
+	}
+}
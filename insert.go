@@ -15,6 +15,8 @@
 package sqlchemy
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"reflect"
 	"strings"
@@ -28,24 +30,34 @@ import (
 
 // Insert perform a insert operation, the value of the record is store in dt
 func (t *STableSpec) Insert(dt interface{}) error {
+	return t.InsertContext(context.Background(), dt)
+}
+
+// InsertContext perform a insert operation with context, the value of the record is store in dt
+func (t *STableSpec) InsertContext(ctx context.Context, dt interface{}) error {
 	if !t.Database().backend.CanInsert() {
 		return errors.Wrap(errors.ErrNotSupported, "Insert")
 	}
-	return t.insert(dt, false, false)
+	return t.insert(ctx, dt, false, false)
 }
 
 // InsertOrUpdate perform a insert or update operation, the value of the record is string in dt
 // MySQL: INSERT INTO ... ON DUPLICATE KEY UPDATE ...
 // works only for the cases that all values of primary keys are determeted before insert
 func (t *STableSpec) InsertOrUpdate(dt interface{}) error {
+	return t.InsertOrUpdateContext(context.Background(), dt)
+}
+
+// InsertOrUpdateContext perform a insert or update operation with context, the value of the record is string in dt
+func (t *STableSpec) InsertOrUpdateContext(ctx context.Context, dt interface{}) error {
 	if !t.Database().backend.CanInsertOrUpdate() {
 		if !t.Database().backend.CanUpdate() {
-			return t.insert(dt, false, false)
+			return t.insert(ctx, dt, false, false)
 		} else {
 			return errors.Wrap(errors.ErrNotSupported, "InsertOrUpdate")
 		}
 	}
-	return t.insert(dt, true, false)
+	return t.insert(ctx, dt, true, false)
 }
 
 type InsertSqlResult struct {
@@ -55,7 +67,10 @@ type InsertSqlResult struct {
 }
 
 func (t *STableSpec) InsertSqlPrep(data interface{}, update bool) (*InsertSqlResult, error) {
-	beforeInsert(reflect.ValueOf(data))
+	err := beforeInsert(reflect.ValueOf(data))
+	if err != nil {
+		return nil, err
+	}
 
 	dataValue := reflect.ValueOf(data).Elem()
 	dataFields := reflectutils.FetchStructFieldValueSet(dataValue)
@@ -248,27 +263,27 @@ func (t *STableSpec) InsertSqlPrep(data interface{}, update bool) (*InsertSqlRes
 	}, nil
 }
 
-func beforeInsert(val reflect.Value) {
+func beforeInsert(val reflect.Value) error {
 	switch val.Kind() {
 	case reflect.Struct:
 		structType := val.Type()
 		for i := 0; i < val.NumField(); i++ {
 			fieldType := structType.Field(i)
 			if fieldType.Anonymous {
-				beforeInsert(val.Field(i))
+				err := beforeInsert(val.Field(i))
+				if err != nil {
+					return err
+				}
 			}
 		}
-		valPtr := val.Addr()
-		afterMarshalFunc := valPtr.MethodByName("BeforeInsert")
-		if afterMarshalFunc.IsValid() && !afterMarshalFunc.IsNil() {
-			afterMarshalFunc.Call([]reflect.Value{})
-		}
+		return callHook(val.Addr(), hookBeforeInsert)
 	case reflect.Ptr:
-		beforeInsert(val.Elem())
+		return beforeInsert(val.Elem())
 	}
+	return nil
 }
 
-func (t *STableSpec) insert(data interface{}, update bool, debug bool) error {
+func (t *STableSpec) insert(ctx context.Context, data interface{}, update bool, debug bool) error {
 	insertResult, err := t.InsertSqlPrep(data, update)
 	if err != nil {
 		return errors.Wrap(err, "insertSqlPrep")
@@ -278,12 +293,44 @@ func (t *STableSpec) insert(data interface{}, update bool, debug bool) error {
 		log.Debugf("%s values: %#v", insertResult.Sql, insertResult.Values)
 	}
 
-	results, err := t.Database().TxExec(insertResult.Sql, insertResult.Values...)
-	if err != nil {
-		return errors.Wrap(err, "TxExec")
+	var autoIncCol IColumnSpec
+	for _, c := range t.Columns() {
+		if c.IsPrimary() && c.IsAutoIncrement() {
+			autoIncCol = c
+			break
+		}
 	}
 
-	if t.Database().backend.CanSupportRowAffected() {
+	var results sql.Result
+	lastId := int64(0)
+	if autoIncCol != nil && !t.Database().backend.CanSupportLastInsertId() {
+		// fetch the auto increment value with INSERT ... RETURNING, e.g. PostgreSQL
+		qChar := t.Database().backend.QuoteChar()
+		sqlstr := fmt.Sprintf("%s RETURNING %s%s%s", insertResult.Sql, qChar, autoIncCol.Name(), qChar)
+		queryCtx, done := t.Database().intercept(ctx, QUERY_OP_QUERY_ROW, sqlstr, insertResult.Values, t.Database().tx != nil)
+		err = t.Database().conn().QueryRowContext(queryCtx, t.Database().backend.ReplacePlaceholders(sqlstr), insertResult.Values...).Scan(&lastId)
+		err = t.Database().wrapError(ctx, err)
+		done(nil, err)
+		if t.Database().tx == nil {
+			t.Database().markWrite()
+		}
+		if err != nil {
+			return errors.Wrap(err, "QueryRow")
+		}
+	} else {
+		results, err = t.Database().TxExecContext(ctx, insertResult.Sql, insertResult.Values...)
+		if err != nil {
+			return errors.Wrap(err, "TxExec")
+		}
+		if autoIncCol != nil {
+			lastId, err = results.LastInsertId()
+			if err != nil {
+				return errors.Wrap(err, "fetching lastInsertId failed")
+			}
+		}
+	}
+
+	if results != nil && t.Database().backend.CanSupportRowAffected() {
 		affectCnt, err := results.RowsAffected()
 		if err != nil {
 			return err
@@ -312,14 +359,10 @@ func (t *STableSpec) insert(data interface{}, update bool, debug bool) error {
 
 	// query the value, so default value can be feedback into the object
 	// fields = reflectutils.FetchStructFieldNameValueInterfaces(dataValue)
-	q := t.Query()
+	q := t.Query().Unscoped().UsePrimary()
 	for _, c := range t.Columns() {
 		if c.IsPrimary() {
 			if c.IsAutoIncrement() {
-				lastId, err := results.LastInsertId()
-				if err != nil {
-					return errors.Wrap(err, "fetching lastInsertId failed")
-				}
 				q = q.Equals(c.Name(), lastId)
 			} else {
 				priVal, _ := insertResult.Primaries[c.Name()]
@@ -329,10 +372,10 @@ func (t *STableSpec) insert(data interface{}, update bool, debug bool) error {
 			}
 		}
 	}
-	err = q.First(data)
+	err = q.FirstContext(ctx, data)
 	if err != nil {
 		return errors.Wrap(err, "query after insert failed")
 	}
 
-	return nil
+	return callHook(reflect.ValueOf(data), hookAfterInsert)
 }
@@ -15,7 +15,7 @@
 package sqlchemy
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -28,34 +28,100 @@ import (
 )
 
 const (
-	sqlLineLimit = 100
+	// sqlLineLimit is the maximal number of rows in a multi-row insert statement
+	sqlLineLimit = 1000
 )
 
+// InsertBatch inserts the records in dataList with multi-row INSERT statements,
+// the number of rows of each statement is limited by the placeholder limit of the backend,
+// backends without multi-row insert execute a single-row statement for each record in a transaction
 func (t *STableSpec) InsertBatch(dataList []interface{}) error {
-	qChar := t.Database().backend.QuoteChar()
+	return t.InsertBatchContext(context.Background(), dataList)
+}
+
+// InsertBatchContext is the context-aware variant of InsertBatch
+func (t *STableSpec) InsertBatchContext(ctx context.Context, dataList []interface{}) error {
+	return t.insertBatch(ctx, dataList, false)
+}
+
+// InsertOrUpdateBatch inserts the records in dataList with multi-row INSERT statements,
+// the existing records with the same primary keys are updated with the upsert syntax of the backend
+func (t *STableSpec) InsertOrUpdateBatch(dataList []interface{}) error {
+	return t.InsertOrUpdateBatchContext(context.Background(), dataList)
+}
+
+// InsertOrUpdateBatchContext is the context-aware variant of InsertOrUpdateBatch
+func (t *STableSpec) InsertOrUpdateBatchContext(ctx context.Context, dataList []interface{}) error {
+	if !t.Database().backend.CanInsertOrUpdate() || len(t.Database().backend.InsertOrUpdateBatchSQLTemplate()) == 0 {
+		return errors.Wrap(errors.ErrNotSupported, "InsertOrUpdateBatch")
+	}
+	return t.insertBatch(ctx, dataList, true)
+}
+
+type sInsertBatchSQL struct {
+	table          string
+	headers        []string
+	format         string
+	primaryKeys    []string
+	updateColumns  []string
+	versionColumns []string
+	update         bool
+}
+
+func (bs *sInsertBatchSQL) sql(backend IBackend, rowCount int) string {
+	rows := make([]string, rowCount)
+	for i := range rows {
+		rows[i] = bs.format
+	}
+	values := strings.Join(rows, ", ")
+	if !bs.update {
+		return fmt.Sprintf("INSERT INTO %s%s%s (%s) VALUES %s", backend.QuoteChar(), bs.table, backend.QuoteChar(), strings.Join(bs.headers, ", "), values)
+	}
+	return TemplateEval(backend.InsertOrUpdateBatchSQLTemplate(), struct {
+		Table          string
+		Columns        string
+		Values         string
+		PrimaryKeys    string
+		UpdateColumns  []string
+		VersionColumns []string
+	}{
+		Table:          bs.table,
+		Columns:        strings.Join(bs.headers, ", "),
+		Values:         values,
+		PrimaryKeys:    strings.Join(bs.primaryKeys, ", "),
+		UpdateColumns:  bs.updateColumns,
+		VersionColumns: bs.versionColumns,
+	})
+}
+
+func (t *STableSpec) prepareInsertBatchSql(update bool) (*sInsertBatchSQL, int, error) {
+	backend := t.Database().backend
+	qChar := backend.QuoteChar()
 
-	var sql string
+	batchSql := &sInsertBatchSQL{
+		table:  t.Name(),
+		update: update,
+	}
 	var fieldCount int
 	{
-		buffer := new(bytes.Buffer)
-
-		buffer.WriteString("INSERT INTO ")
-		buffer.WriteString(qChar)
-		buffer.WriteString(t.Name())
-		buffer.WriteString(qChar)
-		buffer.WriteString(" (")
-		headers := make([]string, 0)
 		format := make([]string, 0)
-
 		for _, col := range t.Columns() {
+			name := col.Name()
+			if col.IsPrimary() {
+				batchSql.primaryKeys = append(batchSql.primaryKeys, fmt.Sprintf("%s%s%s", qChar, name, qChar))
+			}
 			if col.IsAutoIncrement() {
 				continue
 			}
-			name := col.Name()
-			headers = append(headers, fmt.Sprintf("%s%s%s", qChar, name, qChar))
+			batchSql.headers = append(batchSql.headers, fmt.Sprintf("%s%s%s", qChar, name, qChar))
+			if col.IsAutoVersion() {
+				batchSql.versionColumns = append(batchSql.versionColumns, name)
+			} else if !col.IsPrimary() && !col.IsCreatedAt() {
+				batchSql.updateColumns = append(batchSql.updateColumns, name)
+			}
 			if col.IsCreatedAt() || col.IsUpdatedAt() {
-				if t.Database().backend.SupportMixedInsertVariables() {
-					format = append(format, t.Database().backend.CurrentUTCTimeStampString())
+				if backend.SupportMixedInsertVariables() {
+					format = append(format, backend.CurrentUTCTimeStampString())
 				} else {
 					format = append(format, "?")
 					fieldCount++
@@ -65,31 +131,60 @@ func (t *STableSpec) InsertBatch(dataList []interface{}) error {
 			format = append(format, "?")
 			fieldCount++
 		}
+		batchSql.format = "(" + strings.Join(format, ", ") + ")"
+	}
+	if update {
+		if len(batchSql.primaryKeys) == 0 {
+			return nil, 0, errors.Wrap(ErrEmptyPrimaryKey, "InsertOrUpdateBatch")
+		}
+		if len(batchSql.updateColumns) == 0 && len(batchSql.versionColumns) == 0 {
+			return nil, 0, errors.Wrap(ErrNoDataToUpdate, "InsertOrUpdateBatch")
+		}
+	}
+	return batchSql, fieldCount, nil
+}
 
-		buffer.WriteString(strings.Join(headers, ","))
-		buffer.WriteString(") VALUES ")
-		buffer.WriteString("(")
-		buffer.WriteString(strings.Join(format, ","))
-		buffer.WriteString(")")
+// InsertBatchSqlResult is a statement of InsertBatch, which is executed once for each values in ValuesList
+type InsertBatchSqlResult struct {
+	Sql        string
+	ValuesList [][]interface{}
+}
 
-		sql = buffer.String()
+// InsertBatchSqlPrep returns the statements to insert the records in dataList. If the backend supports
+// multi-row insert, each statement inserts as many rows as the placeholder limit allows, otherwise
+// the single-row statement is executed for each record
+func (t *STableSpec) InsertBatchSqlPrep(dataList []interface{}, update bool) ([]InsertBatchSqlResult, error) {
+	backend := t.Database().backend
+	batchSql, fieldCount, err := t.prepareInsertBatchSql(update)
+	if err != nil {
+		return nil, err
+	}
 
-		if DEBUG_SQLCHEMY {
-			log.Debugf("batchInsert SQL: %s", buffer.String())
-		}
+	multiRow := backend.CanSupportMultiRowInsert()
+	rowLimit := sqlLineLimit
+	if multiRow && fieldCount > 0 && backend.MaxPlaceholders()/fieldCount < rowLimit {
+		rowLimit = backend.MaxPlaceholders() / fieldCount
+	}
+	if rowLimit < 1 {
+		rowLimit = 1
 	}
 
-	batchParams := make([][]interface{}, 0)
+	results := make([]InsertBatchSqlResult, 0)
+	varsList := make([][]interface{}, 0)
+	batchParams := make([]interface{}, 0)
+	batchRows := 0
 
 	now := timeutils.UtcNow()
-	errs := make([]error, 0)
 	for i := range dataList {
 		v := dataList[i]
 
 		var params []interface{}
 
 		modelValue := reflect.Indirect(reflect.ValueOf(v))
-		beforeInsert(modelValue)
+		err := beforeInsert(modelValue)
+		if err != nil {
+			return nil, errors.Wrap(err, "beforeInsert")
+		}
 		dataFields := reflectutils.FetchStructFieldValueSet(modelValue)
 
 		for _, col := range t.Columns() {
@@ -97,7 +192,7 @@ func (t *STableSpec) InsertBatch(dataList []interface{}) error {
 				continue
 			}
 			if col.IsCreatedAt() || col.IsUpdatedAt() {
-				if !t.Database().backend.SupportMixedInsertVariables() {
+				if !backend.SupportMixedInsertVariables() {
 					params = append(params, now)
 				}
 				continue
@@ -126,23 +221,61 @@ func (t *STableSpec) InsertBatch(dataList []interface{}) error {
 			log.Errorf("expect %d got %d(%#v)", fieldCount, len(params), params)
 		}
 
-		batchParams = append(batchParams, params)
-		if len(batchParams) >= sqlLineLimit || (i+1) == len(dataList) {
-			results, err := t.Database().TxBatchExec(sql, batchParams)
-			if err != nil {
-				return errors.Wrap(err, "TxBatchExec")
+		if multiRow {
+			batchParams = append(batchParams, params...)
+		} else {
+			varsList = append(varsList, params)
+		}
+		batchRows++
+		if batchRows >= rowLimit || (i+1) == len(dataList) {
+			if multiRow {
+				results = append(results, InsertBatchSqlResult{
+					Sql:        batchSql.sql(backend, batchRows),
+					ValuesList: [][]interface{}{batchParams},
+				})
+				batchParams = make([]interface{}, 0)
+			} else {
+				results = append(results, InsertBatchSqlResult{
+					Sql:        batchSql.sql(backend, 1),
+					ValuesList: varsList,
+				})
+				varsList = make([][]interface{}, 0)
 			}
-			for _, result := range results {
-				if result.Error != nil {
-					errs = append(errs, result.Error)
-				}
+			batchRows = 0
+		}
+	}
+	return results, nil
+}
+
+func (t *STableSpec) insertBatch(ctx context.Context, dataList []interface{}, update bool) error {
+	results, err := t.InsertBatchSqlPrep(dataList, update)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if DEBUG_SQLCHEMY {
+			log.Debugf("batchInsert SQL: %s", result.Sql)
+		}
+		if len(result.ValuesList) == 1 {
+			_, err := t.Database().TxExecContext(ctx, result.Sql, result.ValuesList[0]...)
+			if err != nil {
+				return errors.Wrap(err, "TxExec")
 			}
-			if len(errs) != 0 {
-				return errors.NewAggregate(errs)
+			continue
+		}
+		execResults, err := t.Database().TxBatchExecContext(ctx, result.Sql, result.ValuesList)
+		if err != nil {
+			return errors.Wrap(err, "TxBatchExec")
+		}
+		errs := make([]error, 0)
+		for _, execResult := range execResults {
+			if execResult.Error != nil {
+				errs = append(errs, execResult.Error)
 			}
-			batchParams = make([][]interface{}, 0)
+		}
+		if len(errs) != 0 {
+			return errors.NewAggregate(errs)
 		}
 	}
-
 	return nil
 }
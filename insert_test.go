@@ -105,3 +105,29 @@ func TestInsertUpdateSQL(t *testing.T) {
 		t.Errorf("VARs want %d got %d", wantVars, len(results.Values))
 	}
 }
+
+func TestInsertBatchSQL(t *testing.T) {
+	SetupMockDatabaseBackend()
+
+	table := NewTableSpecFromStruct(TableStruct2{}, "testtable2")
+	batchSql, fieldCount, err := table.prepareInsertBatchSql(false)
+	if err != nil {
+		t.Fatalf("prepareInsertBatchSql fail %s", err)
+	}
+	want := "INSERT INTO `testtable2` (`id`, `user_id`, `name`, `age`, `is_male`, `created_at`, `updated_at`, `version`) VALUES (?, ?, ?, ?, ?, UTC_NOW(), UTC_NOW(), ?), (?, ?, ?, ?, ?, UTC_NOW(), UTC_NOW(), ?)"
+	if got := batchSql.sql(table.Database().backend, 2); got != want {
+		t.Errorf("SQL: want %s got %s", want, got)
+	}
+	if fieldCount != 6 {
+		t.Errorf("field count want 6 got %d", fieldCount)
+	}
+
+	batchSql, _, err = table.prepareInsertBatchSql(true)
+	if err != nil {
+		t.Fatalf("prepareInsertBatchSql fail %s", err)
+	}
+	want = "INSERT INTO `testtable2` (`id`, `user_id`, `name`, `age`, `is_male`, `created_at`, `updated_at`, `version`) VALUES (?, ?, ?, ?, ?, UTC_NOW(), UTC_NOW(), ?) ON DUPLICATE KEY UPDATE `user_id` = VALUES(`user_id`), `name` = VALUES(`name`), `age` = VALUES(`age`), `is_male` = VALUES(`is_male`), `updated_at` = VALUES(`updated_at`), `version` = `version` + 1"
+	if got := batchSql.sql(table.Database().backend, 1); got != want {
+		t.Errorf("SQL: want %s got %s", want, got)
+	}
+}
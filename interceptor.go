@@ -0,0 +1,138 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"yunion.io/x/log"
+)
+
+const (
+	// QUERY_OP_EXEC represents a statement executed by Exec, e.g. INSERT, UPDATE, DELETE or DDL
+	QUERY_OP_EXEC = "Exec"
+	// QUERY_OP_QUERY represents a statement returning rows
+	QUERY_OP_QUERY = "Query"
+	// QUERY_OP_QUERY_ROW represents a statement returning at most one row;
+	// for First and Count the reported error includes the fetch and scan
+	QUERY_OP_QUERY_ROW = "QueryRow"
+)
+
+// SQueryEvent describes a statement executed by a database
+type SQueryEvent struct {
+	// Database is the name of the database
+	Database DBName
+	// Backend is the name of the database backend
+	Backend DBBackendName
+	// Operation is one of QUERY_OP_EXEC, QUERY_OP_QUERY and QUERY_OP_QUERY_ROW
+	Operation string
+	// SQL is the statement with ? placeholders
+	SQL string
+	// Vars is the variables of the statement
+	Vars []interface{}
+	// InTx indicates the statement is executed inside a transaction, including the implicit transaction of TxBatchExec
+	InTx bool
+
+	// Start is the time the statement starts
+	Start time.Time
+	// Duration is the execution time of the statement, for Query it does not include the time of fetching rows
+	Duration time.Duration
+	// RowsAffected is the number of rows affected by Exec, -1 if unknown
+	RowsAffected int64
+	// Error is the error returned by the driver
+	Error error
+
+	db *SDatabase
+}
+
+// DebugString returns the statement with the variables rendered as literals of the backend
+func (event *SQueryEvent) DebugString() string {
+	return event.db.SQLPrintf(event.SQL, event.Vars)
+}
+
+// IQueryInterceptor intercepts every statement executed by a database
+type IQueryInterceptor interface {
+	// BeforeQuery is called before the statement is executed, the returned
+	// context is used to execute the statement and passed to AfterQuery,
+	// so that an interceptor may start a tracing span
+	BeforeQuery(ctx context.Context, event *SQueryEvent) context.Context
+	// AfterQuery is called after the statement is executed with Duration,
+	// RowsAffected and Error filled
+	AfterQuery(ctx context.Context, event *SQueryEvent)
+}
+
+// AddInterceptor registers a query interceptor to the database,
+// the interceptors are called in the order of registration
+func (db *SDatabase) AddInterceptor(interceptor IQueryInterceptor) {
+	db.interceptors = append(db.interceptors, interceptor)
+}
+
+// intercept notifies the interceptors of a statement, the returned function
+// should be called with the result of the statement
+func (db *SDatabase) intercept(ctx context.Context, op string, sqlstr string, vars []interface{}, inTx bool) (context.Context, func(result sql.Result, err error)) {
+	if len(db.interceptors) == 0 {
+		return ctx, func(sql.Result, error) {}
+	}
+	event := &SQueryEvent{
+		db:           db,
+		Database:     db.name,
+		Backend:      db.backend.Name(),
+		Operation:    op,
+		SQL:          sqlstr,
+		Vars:         vars,
+		InTx:         inTx,
+		Start:        time.Now(),
+		RowsAffected: -1,
+	}
+	ctxs := make([]context.Context, len(db.interceptors))
+	for i := range db.interceptors {
+		ctx = db.interceptors[i].BeforeQuery(ctx, event)
+		ctxs[i] = ctx
+	}
+	return ctx, func(result sql.Result, err error) {
+		event.Duration = time.Since(event.Start)
+		event.Error = err
+		if result != nil && err == nil && db.backend.CanSupportRowAffected() {
+			if cnt, err := result.RowsAffected(); err == nil {
+				event.RowsAffected = cnt
+			}
+		}
+		for i := len(db.interceptors) - 1; i >= 0; i-- {
+			db.interceptors[i].AfterQuery(ctxs[i], event)
+		}
+	}
+}
+
+type sSlowQueryInterceptor struct {
+	threshold time.Duration
+}
+
+// NewSlowQueryInterceptor returns an interceptor that logs the statements
+// whose execution time exceeds threshold
+func NewSlowQueryInterceptor(threshold time.Duration) IQueryInterceptor {
+	return &sSlowQueryInterceptor{threshold: threshold}
+}
+
+func (i *sSlowQueryInterceptor) BeforeQuery(ctx context.Context, event *SQueryEvent) context.Context {
+	return ctx
+}
+
+func (i *sSlowQueryInterceptor) AfterQuery(ctx context.Context, event *SQueryEvent) {
+	if event.Duration >= i.threshold {
+		log.Warningf("slow query %s on %s: %s", event.Duration, event.Database, event.DebugString())
+	}
+}
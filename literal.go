@@ -0,0 +1,177 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+)
+
+// SLiteralStyle describes how a backend quotes values as SQL literals
+type SLiteralStyle struct {
+	// BackslashEscape indicates backslash is an escape character in string literals, e.g. MySQL
+	BackslashEscape bool
+	// BooleanKeyword renders booleans as TRUE and FALSE instead of 1 and 0
+	BooleanKeyword bool
+	// ByteaHex renders binary values as '\x...' instead of X'...', e.g. PostgreSQL
+	ByteaHex bool
+}
+
+// literalTimeFormat is the format of time literals, times are rendered in UTC
+const literalTimeFormat = "2006-01-02 15:04:05.999999"
+
+// Quote returns the SQL literal of a value
+func (style SLiteralStyle) Quote(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return style.quoteString(val)
+	case []byte:
+		if val == nil {
+			return "NULL"
+		}
+		if style.ByteaHex {
+			return `'\x` + hex.EncodeToString(val) + `'`
+		}
+		return "X'" + hex.EncodeToString(val) + "'"
+	case bool:
+		return style.quoteBool(val)
+	case time.Time:
+		return "'" + val.UTC().Format(literalTimeFormat) + "'"
+	case jsonutils.JSONObject:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "NULL"
+		}
+		return style.quoteString(val.String())
+	case driver.Valuer:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "NULL"
+		}
+		dv, err := val.Value()
+		if err != nil {
+			return style.quoteString(fmt.Sprintf("%v", v))
+		}
+		return style.Quote(dv)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return style.Quote(rv.Elem().Interface())
+	case reflect.Bool:
+		return style.quoteBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+	case reflect.String:
+		return style.quoteString(rv.String())
+	}
+	return style.quoteString(fmt.Sprintf("%v", v))
+}
+
+func (style SLiteralStyle) quoteBool(b bool) string {
+	switch {
+	case style.BooleanKeyword && b:
+		return "TRUE"
+	case style.BooleanKeyword:
+		return "FALSE"
+	case b:
+		return "1"
+	default:
+		return "0"
+	}
+}
+
+func (style SLiteralStyle) quoteString(s string) string {
+	if style.BackslashEscape {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Render substitutes the ? placeholders of sqlstr with the literals of vars,
+// placeholders inside string literals, quoted identifiers and comments are left alone
+func (style SLiteralStyle) Render(sqlstr string, vars []interface{}) string {
+	var buf strings.Builder
+	varIdx := 0
+	for i := 0; i < len(sqlstr); i++ {
+		c := sqlstr[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := skipQuoted(sqlstr, i, style.BackslashEscape && c != '`')
+			buf.WriteString(sqlstr[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(sqlstr[i:], "--"):
+			end := strings.IndexByte(sqlstr[i:], '\n')
+			if end < 0 {
+				end = len(sqlstr) - i
+			}
+			buf.WriteString(sqlstr[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(sqlstr[i:], "/*"):
+			end := strings.Index(sqlstr[i+2:], "*/")
+			if end < 0 {
+				end = len(sqlstr) - i
+			} else {
+				end += 4
+			}
+			buf.WriteString(sqlstr[i : i+end])
+			i += end - 1
+		case c == '?' && varIdx < len(vars):
+			buf.WriteString(style.Quote(vars[varIdx]))
+			varIdx++
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// skipQuoted returns the position after the quoted token starting at start,
+// a doubled quote character is an escaped quote
+func skipQuoted(sqlstr string, start int, backslashEscape bool) int {
+	quote := sqlstr[start]
+	for i := start + 1; i < len(sqlstr); i++ {
+		switch sqlstr[i] {
+		case '\\':
+			if backslashEscape {
+				i++
+			}
+		case quote:
+			if i+1 < len(sqlstr) && sqlstr[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sqlstr)
+}
@@ -0,0 +1,458 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	// MIGRATION_TABLE is the name of the bookkeeping table of applied migrations
+	MIGRATION_TABLE = "sqlchemy_migrations"
+	// MIGRATION_LOCK_TABLE is the name of the table used as the lock of applying migrations
+	MIGRATION_LOCK_TABLE = "sqlchemy_migration_lock"
+
+	// migrationLockId is the primary key of the single row of the lock table
+	migrationLockId = 1
+	// migrationLockMaxFailures is the maximal attempts of acquiring the lock while no one holds it
+	migrationLockMaxFailures = 5
+)
+
+// SMigration is a versioned migration, which consists of SQL statements,
+// e.g. generated by SyncSQL, and an optional data migration function
+type SMigration struct {
+	// Version is the unique version of the migration, migrations are applied in ascending order of versions
+	Version int64
+	// Name describes the migration
+	Name string
+	// SQLs are the statements executed in order
+	SQLs []string
+	// Migrate is executed after SQLs, e.g. to migrate data, db is a transactional handle
+	// if the backend supports transactional DDL
+	Migrate func(ctx context.Context, db *SDatabase) error
+}
+
+// SMigrationRecord is a record of the bookkeeping table of applied migrations
+type SMigrationRecord struct {
+	Version   int64     `primary:"true"`
+	Name      string    `width:"128" charset:"utf8" nullable:"false"`
+	Checksum  string    `width:"64" charset:"ascii" nullable:"false"`
+	AppliedAt time.Time `nullable:"false" created_at:"true"`
+}
+
+type sMigrationLock struct {
+	Id       int       `primary:"true"`
+	Owner    string    `width:"128" charset:"ascii" nullable:"false"`
+	LockedAt time.Time `nullable:"false"`
+}
+
+// SMigrator applies versioned migrations to a database and records the applied versions
+type SMigrator struct {
+	db         *SDatabase
+	migrations []SMigration
+
+	records   *STableSpec
+	lockTable *STableSpec
+
+	// LockTimeout is the maximal time waiting for the lock held by another migrator
+	LockTimeout time.Duration
+	// LockExpire is the time after which a lock is considered stale and taken over,
+	// the lock is refreshed while migrations run, so a long migration keeps the lock
+	LockExpire time.Duration
+
+	// owner identifies the migrator holding the lock
+	owner string
+}
+
+var migrateLock sync.Mutex
+
+// NewMigrator returns a migrator of the database
+func NewMigrator(db *SDatabase) *SMigrator {
+	return &SMigrator{
+		db:          db,
+		records:     NewTableSpecFromStructWithDBName(SMigrationRecord{}, MIGRATION_TABLE, db.name),
+		lockTable:   NewTableSpecFromStructWithDBName(sMigrationLock{}, MIGRATION_LOCK_TABLE, db.name),
+		LockTimeout: time.Minute,
+		LockExpire:  30 * time.Minute,
+	}
+}
+
+// Checksum returns the checksum of the SQL statements of the migration
+func (mig *SMigration) Checksum() string {
+	sum := sha256.Sum256([]byte(strings.Join(mig.SQLs, ";\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Register adds migrations to the migrator
+func (m *SMigrator) Register(migrations ...SMigration) error {
+	for i := range migrations {
+		for j := range m.migrations {
+			if m.migrations[j].Version == migrations[i].Version {
+				return errors.Wrapf(ErrDuplicateEntry, "migration version %d", migrations[i].Version)
+			}
+		}
+		m.migrations = append(m.migrations, migrations[i])
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return nil
+}
+
+// Migrations returns the registered migrations in ascending order of versions
+func (m *SMigrator) Migrations() []SMigration {
+	return m.migrations
+}
+
+func (m *SMigrator) ensureTables() error {
+	for _, ts := range []*STableSpec{m.records, m.lockTable} {
+		err := ts.Sync()
+		if err != nil {
+			return errors.Wrapf(err, "sync %s", ts.Name())
+		}
+	}
+	return nil
+}
+
+// Applied returns the records of applied migrations in ascending order of versions
+func (m *SMigrator) Applied() ([]SMigrationRecord, error) {
+	if !m.records.Exists() {
+		return []SMigrationRecord{}, nil
+	}
+	records := make([]SMigrationRecord, 0)
+	err := m.records.Query().UsePrimary().Asc("version").All(&records)
+	if err != nil {
+		return nil, errors.Wrap(err, "query applied migrations")
+	}
+	return records, nil
+}
+
+// Pending returns the registered migrations not yet applied, the checksum of an
+// applied migration must match that of the registered one
+func (m *SMigrator) Pending() ([]SMigration, error) {
+	records, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]SMigrationRecord)
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	pending := make([]SMigration, 0)
+	for i := range m.migrations {
+		mig := m.migrations[i]
+		if r, ok := applied[mig.Version]; ok {
+			if r.Checksum != mig.Checksum() {
+				return nil, errors.Wrapf(ErrMigrationChecksumMismatch, "migration %d %s", mig.Version, mig.Name)
+			}
+			continue
+		}
+		pending = append(pending, mig)
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations in order under the migration lock
+func (m *SMigrator) Migrate(ctx context.Context) error {
+	migrateLock.Lock()
+	defer migrateLock.Unlock()
+
+	err := m.ensureTables()
+	if err != nil {
+		return err
+	}
+	err = m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock()
+	stop := m.heartbeat()
+	defer stop()
+
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		err := m.apply(ctx, &pending[i])
+		if err != nil {
+			return errors.Wrapf(err, "migration %d %s", pending[i].Version, pending[i].Name)
+		}
+	}
+	return nil
+}
+
+// apply runs the SQLs, the Migrate function and the record of a migration in a transaction if the
+// backend supports transactional DDL. Otherwise, e.g. MySQL, the DDL commits implicitly, a failure
+// leaves the SQLs already executed applied without a record, which are executed again by the next
+// Migrate, so the migration has to be fixed up manually or written to be idempotent
+func (m *SMigrator) apply(ctx context.Context, mig *SMigration) error {
+	log.Infof("apply migration %d %s", mig.Version, mig.Name)
+	if !m.db.backend.CanSupportTransactionalDDL() {
+		return m.applyInDB(ctx, m.db, m.records, mig)
+	}
+	return m.db.RunInTx(ctx, func(tx *STx) error {
+		return m.applyInDB(ctx, tx.Database(), m.records.InTx(tx), mig)
+	})
+}
+
+func (m *SMigrator) applyInDB(ctx context.Context, db *SDatabase, records *STableSpec, mig *SMigration) error {
+	for _, sqlstr := range mig.SQLs {
+		_, err := db.ExecContext(ctx, sqlstr)
+		if err != nil {
+			return errors.Wrapf(err, "exec %s", sqlstr)
+		}
+	}
+	if mig.Migrate != nil {
+		err := mig.Migrate(ctx, db)
+		if err != nil {
+			return errors.Wrap(err, "Migrate")
+		}
+	}
+	err := records.InsertContext(ctx, &SMigrationRecord{
+		Version:  mig.Version,
+		Name:     mig.Name,
+		Checksum: mig.Checksum(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "record migration")
+	}
+	return nil
+}
+
+func (m *SMigrator) lock(ctx context.Context) error {
+	hostname, _ := os.Hostname()
+	// hostnames and pids of containers may be the same, tell the migrators apart by the time of locking
+	m.owner = fmt.Sprintf("%s:%d:%x", hostname, os.Getpid(), time.Now().UnixNano())
+	deadline := time.Now().Add(m.LockTimeout)
+	failures := 0
+	for {
+		err := m.lockTable.InsertContext(ctx, &sMigrationLock{
+			Id:       migrationLockId,
+			Owner:    m.owner,
+			LockedAt: time.Now().UTC(),
+		})
+		if err == nil {
+			return nil
+		}
+		holder := sMigrationLock{}
+		qErr := m.lockTable.Query().UsePrimary().Equals("id", migrationLockId).First(&holder)
+		if qErr != nil {
+			if errors.Cause(qErr) != sql.ErrNoRows {
+				return errors.Wrap(qErr, "query migration lock")
+			}
+			// either the lock was just released, or the insert failed for another reason
+			failures++
+			if failures >= migrationLockMaxFailures || time.Now().After(deadline) {
+				return errors.Wrap(err, "acquire migration lock")
+			}
+		} else {
+			if time.Since(holder.LockedAt) > m.LockExpire {
+				log.Warningf("take over stale migration lock held by %s since %s", holder.Owner, holder.LockedAt)
+				dq := m.lockTable.DeleteQuery()
+				dq.Filter(AND(Equals(dq.Field("id"), migrationLockId), Equals(dq.Field("owner"), holder.Owner)))
+				_, err := dq.Exec()
+				if err != nil {
+					return errors.Wrap(err, "release stale migration lock")
+				}
+				continue
+			}
+			if time.Now().After(deadline) {
+				return errors.Wrapf(ErrMigrationLocked, "held by %s since %s", holder.Owner, holder.LockedAt)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return wrapContextError(ctx, ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// heartbeat refreshes the lock periodically so that it is not taken over as stale, and returns the function to stop
+func (m *SMigrator) heartbeat() func() {
+	interval := m.LockExpire / 3
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				uq := m.lockTable.UpdateQuery()
+				uq.Set("locked_at", time.Now().UTC())
+				uq.Filter(AND(Equals(uq.Field("id"), migrationLockId), Equals(uq.Field("owner"), m.owner)))
+				cnt, err := uq.Exec()
+				if err != nil {
+					log.Errorf("refresh migration lock fail %s", err)
+				} else if cnt == 0 && m.db.backend.CanSupportRowAffected() {
+					log.Errorf("migration lock of %s was taken over", m.owner)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// unlock releases the lock only if it is still held by the migrator
+func (m *SMigrator) unlock() {
+	dq := m.lockTable.DeleteQuery()
+	dq.Filter(AND(Equals(dq.Field("id"), migrationLockId), Equals(dq.Field("owner"), m.owner)))
+	_, err := dq.Exec()
+	if err != nil {
+		log.Errorf("release migration lock fail %s", err)
+	}
+}
+
+// SyncMigration returns a migration of the SQL statements that synchronize the tables,
+// nil if the tables are already in sync
+func SyncMigration(version int64, name string, tables ...*STableSpec) *SMigration {
+	sqls := make([]string, 0)
+	for _, ts := range tables {
+		sqls = append(sqls, ts.SyncSQL()...)
+	}
+	if len(sqls) == 0 {
+		return nil
+	}
+	return &SMigration{
+		Version: version,
+		Name:    name,
+		SQLs:    sqls,
+	}
+}
+
+var migrationNameRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// FileName returns the file name of the migration, e.g. 20240101120000_add_users.sql
+func (mig *SMigration) FileName() string {
+	name := strings.Trim(migrationNameRegexp.ReplaceAllString(strings.ToLower(mig.Name), "_"), "_")
+	return fmt.Sprintf("%d_%s.sql", mig.Version, name)
+}
+
+// WriteFile writes the SQL statements of the migration to a file in dir and returns the path
+func (mig *SMigration) WriteFile(dir string) (string, error) {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "-- %s\n", mig.Name)
+	for _, sqlstr := range mig.SQLs {
+		buf.WriteString(sqlstr)
+		buf.WriteString(";\n")
+	}
+	path := filepath.Join(dir, mig.FileName())
+	err := ioutil.WriteFile(path, []byte(buf.String()), 0644)
+	if err != nil {
+		return "", errors.Wrapf(err, "write %s", path)
+	}
+	return path, nil
+}
+
+// LoadMigrationFiles loads the migrations written by WriteFile from dir
+func LoadMigrationFiles(dir string) ([]SMigration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read dir %s", dir)
+	}
+	migrations := make([]SMigration, 0)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(f.Name(), ".sql")
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			log.Warningf("skip migration file %s without version", f.Name())
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", f.Name())
+		}
+		mig := SMigration{
+			Version: version,
+			SQLs:    splitSQLStatements(string(content)),
+		}
+		if strings.HasPrefix(string(content), "-- ") {
+			mig.Name = strings.TrimSpace(strings.SplitN(string(content)[3:], "\n", 2)[0])
+		} else if len(parts) > 1 {
+			mig.Name = parts[1]
+		}
+		migrations = append(migrations, mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitSQLStatements splits a script by semicolons outside literals and comments,
+// the comment lines are removed
+func splitSQLStatements(script string) []string {
+	stmts := make([]string, 0)
+	var buf strings.Builder
+	flush := func() {
+		stmt := strings.TrimSpace(buf.String())
+		if len(stmt) > 0 {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := skipQuoted(script, i, false)
+			buf.WriteString(script[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+		case c == ';':
+			flush()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	script := "-- add column\nALTER TABLE `t` ADD COLUMN `a` TEXT DEFAULT 'x;y';\n\nUPDATE `t` SET `a` = 'it''s; ok' -- trailing;\n;\n"
+	want := []string{
+		"ALTER TABLE `t` ADD COLUMN `a` TEXT DEFAULT 'x;y'",
+		"UPDATE `t` SET `a` = 'it''s; ok'",
+	}
+	got := splitSQLStatements(script)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v got %#v", want, got)
+	}
+}
+
+func TestMigrationFileName(t *testing.T) {
+	mig := SMigration{Version: 20240101120000, Name: "Add users' table"}
+	want := "20240101120000_add_users_table.sql"
+	if got := mig.FileName(); got != want {
+		t.Errorf("want %s got %s", want, got)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sTypeChange classifies the change of the type of a column
+type sTypeChange int
+
+const (
+	// typeChangeWidening indicates all values of the old type fit in the new type
+	typeChangeWidening sTypeChange = iota
+	// typeChangeNarrowing indicates some values of the old type may be truncated by the new type
+	typeChangeNarrowing
+	// typeChangeUnknown indicates the conversion is not known to be lossless, and
+	// the values that would be lost cannot be checked
+	typeChangeUnknown
+)
+
+// IsNarrowingChange returns wether altering the type of a column from oldCol
+// to newCol may truncate data, i.e. the new type is not provably wider than
+// the old one, and the reason
+func IsNarrowingChange(oldCol, newCol IColumnSpec) (bool, string) {
+	reason := fmt.Sprintf("type changed from %s to %s", oldCol.ColType(), newCol.ColType())
+	switch classifyTypeChange(oldCol, newCol) {
+	case typeChangeNarrowing:
+		return true, reason
+	case typeChangeUnknown:
+		return true, reason + ", which is not known to be lossless"
+	}
+	return false, ""
+}
+
+func classifyTypeChange(oldCol, newCol IColumnSpec) sTypeChange {
+	oldType := strings.ToUpper(oldCol.ColType())
+	newType := strings.ToUpper(newCol.ColType())
+	if oldType == newType {
+		return typeChangeWidening
+	}
+	if newCol.IsString() {
+		if oldCol.IsString() {
+			if textCapacity(newCol) < textCapacity(oldCol) {
+				return typeChangeNarrowing
+			}
+			oldCharset, newCharset := textCharset(oldType), textCharset(newType)
+			if oldCharset != newCharset && len(newCharset) > 0 && !strings.Contains(newCharset, "UTF8MB4") {
+				return typeChangeNarrowing
+			}
+			return typeChangeWidening
+		}
+		length, ok := numericTextLength(oldType)
+		if !ok {
+			if _, _, ok = timeTypePrecision(oldType); ok {
+				length = len(literalTimeFormat)
+			}
+		}
+		if !ok {
+			return typeChangeUnknown
+		}
+		if int64(length) > textCapacity(newCol) {
+			return typeChangeNarrowing
+		}
+		return typeChangeWidening
+	}
+	if oldCol.IsString() {
+		// text that is not a valid value of the new type is lost
+		return typeChangeNarrowing
+	}
+	oldBits, oldUnsigned := integerTypeBits(oldType)
+	newBits, newUnsigned := integerTypeBits(newType)
+	newPrec, newScale, newIsDecimal := decimalTypePrecision(newType)
+	newFloatBits, newIsFloat := floatTypeBits(newType)
+	if oldBits > 0 {
+		switch {
+		case newBits > 0:
+			return typeChangeOf(isIntegerWidening(oldBits, oldUnsigned, newBits, newUnsigned))
+		case newIsDecimal:
+			return typeChangeOf(integerDigits(oldBits, oldUnsigned) <= newPrec-newScale)
+		case newIsFloat:
+			return typeChangeOf(oldBits <= floatMantissaBits(newFloatBits))
+		}
+		return typeChangeUnknown
+	}
+	if oldPrec, oldScale, ok := decimalTypePrecision(oldType); ok {
+		switch {
+		case newIsDecimal:
+			return typeChangeOf(newScale >= oldScale && newPrec-newScale >= oldPrec-oldScale)
+		case newBits > 0, newIsFloat:
+			return typeChangeNarrowing
+		}
+		return typeChangeUnknown
+	}
+	if oldFloatBits, ok := floatTypeBits(oldType); ok {
+		switch {
+		case newIsFloat:
+			return typeChangeOf(newFloatBits >= oldFloatBits)
+		case newBits > 0, newIsDecimal:
+			return typeChangeNarrowing
+		}
+		return typeChangeUnknown
+	}
+	if oldTime, oldFsp, ok := timeTypePrecision(oldType); ok {
+		if newTime, newFsp, ok := timeTypePrecision(newType); ok {
+			// TIMESTAMP covers a smaller range of dates than DATETIME
+			widening := newFsp >= oldFsp && (oldTime == newTime || newTime == "DATETIME" && (oldTime == "DATE" || oldTime == "TIMESTAMP"))
+			return typeChangeOf(widening)
+		}
+	}
+	return typeChangeUnknown
+}
+
+func typeChangeOf(widening bool) sTypeChange {
+	if widening {
+		return typeChangeWidening
+	}
+	return typeChangeNarrowing
+}
+
+// truncateCondition returns the condition of the non-null values of field that
+// would be truncated by altering oldCol to newCol, nil if no value is truncated
+// or the values cannot be checked
+func truncateCondition(field IQueryField, oldCol, newCol IColumnSpec) ICondition {
+	if classifyTypeChange(oldCol, newCol) != typeChangeNarrowing {
+		return nil
+	}
+	if oldCol.IsString() && newCol.IsString() && textCapacity(newCol) < textCapacity(oldCol) {
+		return GT(LENGTH("", field), textCapacity(newCol))
+	}
+	oldBits, _ := integerTypeBits(oldCol.ColType())
+	newBits, newUnsigned := integerTypeBits(newCol.ColType())
+	if oldBits > 0 && newBits > 0 {
+		if newUnsigned {
+			var max uint64 = 1<<uint(newBits) - 1
+			return OR(LT(field, 0), GT(field, max))
+		}
+		var min int64 = -(1 << uint(newBits-1))
+		var max int64 = 1<<uint(newBits-1) - 1
+		return OR(LT(field, min), GT(field, max))
+	}
+	if floatBits, ok := floatTypeBits(strings.ToUpper(newCol.ColType())); ok && oldBits > 0 {
+		// integers beyond the mantissa lose precision
+		var max int64 = 1 << uint(floatMantissaBits(floatBits))
+		return OR(LT(field, -max), GT(field, max))
+	}
+	// the values cannot be checked generally, any non-null value may be truncated
+	return IsNotNull(field)
+}
+
+func isIntegerWidening(oldBits int, oldUnsigned bool, newBits int, newUnsigned bool) bool {
+	if oldUnsigned == newUnsigned {
+		return newBits >= oldBits
+	}
+	return !newUnsigned && newBits > oldBits
+}
+
+// integerDigits returns the number of decimal digits of the largest magnitude of an integer type
+func integerDigits(bits int, unsigned bool) int {
+	if unsigned {
+		return len(strconv.FormatUint(1<<uint(bits)-1, 10))
+	}
+	return len(strconv.FormatInt(-(1<<uint(bits-1)), 10)) - 1
+}
+
+// numericTextLength returns the maximal length of the text of a value of an upper-cased numeric column type
+func numericTextLength(colType string) (int, bool) {
+	if bits, unsigned := integerTypeBits(colType); bits > 0 {
+		if unsigned {
+			return integerDigits(bits, unsigned), true
+		}
+		return integerDigits(bits, unsigned) + 1, true
+	}
+	if prec, scale, ok := decimalTypePrecision(colType); ok {
+		if scale > 0 {
+			// sign and decimal point
+			return prec + 2, true
+		}
+		return prec + 1, true
+	}
+	if bits, ok := floatTypeBits(colType); ok {
+		if bits > 32 {
+			// e.g. -1.7976931348623157e+308
+			return 24, true
+		}
+		return 15, true
+	}
+	return 0, false
+}
+
+// textCapacity returns the maximal length of a text column
+func textCapacity(col IColumnSpec) int64 {
+	if col.GetWidth() > 0 {
+		return int64(col.GetWidth())
+	}
+	colType := strings.ToUpper(col.ColType())
+	switch {
+	case strings.HasPrefix(colType, "TINYTEXT"):
+		return 1<<8 - 1
+	case strings.HasPrefix(colType, "MEDIUMTEXT"):
+		return 1<<24 - 1
+	case strings.HasPrefix(colType, "LONGTEXT"):
+		return 1<<32 - 1
+	case strings.HasPrefix(colType, "TEXT"):
+		return 1<<16 - 1
+	}
+	return math.MaxInt64
+}
+
+// textCharset returns the charset of an upper-cased text column type, e.g. VARCHAR(64) CHARACTER SET 'ASCII'
+func textCharset(colType string) string {
+	pos := strings.Index(colType, "CHARACTER SET")
+	if pos < 0 {
+		return ""
+	}
+	return strings.Fields(colType[pos+len("CHARACTER SET"):])[0]
+}
+
+var integerTypeRegexp = regexp.MustCompile(`^(?:NULLABLE\()?(U?)INT(8|16|32|64)\b`)
+
+// integerTypeBits returns the bits and signedness of an integer column type, 0 bits for other types
+func integerTypeBits(colType string) (int, bool) {
+	colType = strings.ToUpper(colType)
+	if m := integerTypeRegexp.FindStringSubmatch(colType); m != nil {
+		// clickhouse types, e.g. UInt32
+		bits, _ := strconv.Atoi(m[2])
+		return bits, m[1] == "U"
+	}
+	unsigned := strings.Contains(colType, "UNSIGNED")
+	switch {
+	case strings.HasPrefix(colType, "TINYINT"):
+		return 8, unsigned
+	case strings.HasPrefix(colType, "SMALLINT"):
+		return 16, unsigned
+	case strings.HasPrefix(colType, "MEDIUMINT"):
+		return 24, unsigned
+	case strings.HasPrefix(colType, "BIGINT"):
+		return 64, unsigned
+	case strings.HasPrefix(colType, "INT"):
+		return 32, unsigned
+	}
+	return 0, false
+}
+
+var decimalTypeRegexp = regexp.MustCompile(`^(?:DECIMAL|NUMERIC)\((\d+)(?:,\s*(\d+))?\)`)
+
+// decimalTypePrecision returns the precision and scale of an upper-cased decimal column type
+func decimalTypePrecision(colType string) (int, int, bool) {
+	m := decimalTypeRegexp.FindStringSubmatch(colType)
+	if m == nil {
+		return 0, 0, false
+	}
+	prec, _ := strconv.Atoi(m[1])
+	scale, _ := strconv.Atoi(m[2])
+	return prec, scale, true
+}
+
+// floatTypeBits returns the bits of an upper-cased floating point column type
+func floatTypeBits(colType string) (int, bool) {
+	switch {
+	case strings.HasPrefix(colType, "FLOAT"), strings.HasPrefix(colType, "REAL"):
+		return 32, true
+	case strings.HasPrefix(colType, "DOUBLE"):
+		return 64, true
+	}
+	return 0, false
+}
+
+// floatMantissaBits returns the bits of the mantissa of a floating point type, including the implicit bit
+func floatMantissaBits(bits int) int {
+	if bits > 32 {
+		return 53
+	}
+	return 24
+}
+
+var timeTypeRegexp = regexp.MustCompile(`^(DATETIME|DATE|TIMESTAMP|TIME)\b(?:\((\d)\))?`)
+
+// timeTypePrecision returns the type name and the fractional seconds precision of an upper-cased time column type
+func timeTypePrecision(colType string) (string, int, bool) {
+	m := timeTypeRegexp.FindStringSubmatch(colType)
+	if m == nil {
+		return "", 0, false
+	}
+	fsp, _ := strconv.Atoi(m[2])
+	return m[1], fsp, true
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// SPageCursor is an opaque cursor of keyset pagination, which records the values of
+// the ordering fields of the last row of a page
+type SPageCursor struct {
+	Values []string `json:"v"`
+}
+
+// Encode returns the URL-safe string representation of the cursor
+func (c *SPageCursor) Encode() string {
+	jsonBytes, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(jsonBytes)
+}
+
+// DecodePageCursor parses a cursor encoded by SPageCursor.Encode
+func DecodePageCursor(str string) (*SPageCursor, error) {
+	jsonBytes, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidPageCursor, err.Error())
+	}
+	cursor := &SPageCursor{}
+	err = json.Unmarshal(jsonBytes, cursor)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidPageCursor, err.Error())
+	}
+	return cursor, nil
+}
+
+// PageAfter of SQuery does keyset pagination, which filters the rows after the cursor
+// according to the ordering of the query, e.g. for ORDER BY a ASC, b DESC
+//
+//	WHERE (a > ?) OR (a = ? AND b < ?)
+//
+// A nil cursor means the first page. The ordering should be specified by Asc/Desc beforehand and
+// should be unique, e.g. ends with the primary key, and the ordering fields should not be NULL.
+// The ordering fields are always selected, so that NextPageCursor can be derived after All
+func (tq *SQuery) PageAfter(cursor *SPageCursor) (*SQuery, error) {
+	if len(tq.orderBy) == 0 {
+		return nil, errors.Wrap(ErrInvalidPageCursor, "keyset pagination requires ordering")
+	}
+	if len(tq.fields) > 0 {
+		for i := range tq.orderBy {
+			tq.AppendField(tq.orderBy[i].field)
+		}
+	}
+	if cursor == nil {
+		return tq, nil
+	}
+	if len(cursor.Values) != len(tq.orderBy) {
+		return nil, errors.Wrapf(ErrInvalidPageCursor, "cursor has %d values, ordering has %d fields", len(cursor.Values), len(tq.orderBy))
+	}
+	conds := make([]ICondition, 0, len(tq.orderBy))
+	for i := range tq.orderBy {
+		ands := make([]ICondition, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, Equals(tq.orderBy[j].field, cursor.Values[j]))
+		}
+		if tq.orderBy[i].order == SQL_ORDER_DESC {
+			ands = append(ands, LT(tq.orderBy[i].field, cursor.Values[i]))
+		} else {
+			ands = append(ands, GT(tq.orderBy[i].field, cursor.Values[i]))
+		}
+		if len(ands) == 1 {
+			conds = append(conds, ands[0])
+		} else {
+			conds = append(conds, AND(ands...))
+		}
+	}
+	return tq.Filter(OR(conds...)), nil
+}
+
+// NextPageCursor of SQuery returns the cursor of the next page, which is derived from
+// the last row scanned by All, AllStringMap or SCursor. It returns nil if no row was scanned.
+func (tq *SQuery) NextPageCursor() (*SPageCursor, error) {
+	if tq.lastRow == nil {
+		return nil, nil
+	}
+	if len(tq.orderBy) == 0 {
+		return nil, errors.Wrap(ErrInvalidPageCursor, "keyset pagination requires ordering")
+	}
+	cursor := &SPageCursor{
+		Values: make([]string, len(tq.orderBy)),
+	}
+	for i := range tq.orderBy {
+		name := tq.orderBy[i].field.Name()
+		val, ok := tq.lastRow[name]
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidPageCursor, "ordering field %s not selected", name)
+		}
+		cursor.Values[i] = val
+	}
+	return cursor, nil
+}
@@ -61,6 +61,13 @@ func (table *STableSpec) struct2TableSpec(sv reflect.Value) {
 			if column.IsIndex() {
 				table.AddIndex(column.IsUnique(), column.Name())
 			}
+			if ref, ok := column.Tags()[TAG_FOREIGN_KEY]; ok {
+				refTable, refCol, ok := parseForeignKeyTag(ref)
+				if !ok {
+					panic(fmt.Sprintf("invalid foreign key %q of column %s.%s", ref, table.name, column.Name()))
+				}
+				table.AddForeignKey([]string{column.Name()}, refTable, []string{refCol}, column.Tags()[TAG_ON_DELETE], column.Tags()[TAG_ON_UPDATE])
+			}
 			tmpCols = append(tmpCols, column)
 		}
 	}
@@ -0,0 +1,357 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+)
+
+// SColumnChange describes a change of a column in a sync plan
+type SColumnChange struct {
+	Column        string `json:"column"`
+	OldName       string `json:"old_name,omitempty"`
+	OldDefinition string `json:"old_definition,omitempty"`
+	NewDefinition string `json:"new_definition,omitempty"`
+	// Destructive indicates the change may lose data or fail on existing data
+	Destructive bool   `json:"destructive"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// SIndexChange describes an index added or removed in a sync plan
+type SIndexChange struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+
+	Type       string `json:"type,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	Where      string `json:"where,omitempty"`
+}
+
+func (idx SIndexChange) String() string {
+	keys := strings.Join(idx.Columns, ", ")
+	if len(idx.Expression) > 0 {
+		keys = idx.Expression
+	}
+	str := fmt.Sprintf("%s (%s)", idx.Name, keys)
+	if len(idx.Where) > 0 {
+		str += " WHERE " + idx.Where
+	}
+	return str
+}
+
+// SConstraintChange describes a constraint added or dropped in a sync plan
+type SConstraintChange struct {
+	Name         string   `json:"name"`
+	Columns      []string `json:"columns"`
+	ForeignTable string   `json:"foreign_table"`
+	ForeignKeys  []string `json:"foreign_keys"`
+	Reason       string   `json:"reason,omitempty"`
+}
+
+// STableSyncPlan is the diff of a table between the database and its TableSpec
+type STableSyncPlan struct {
+	Table string `json:"table"`
+	// Create indicates the table does not exist and is to be created
+	Create bool `json:"create"`
+
+	AddedColumns   []SColumnChange `json:"added_columns"`
+	RemovedColumns []SColumnChange `json:"removed_columns"`
+	AlteredColumns []SColumnChange `json:"altered_columns"`
+	RenamedColumns []SColumnChange `json:"renamed_columns"`
+
+	AddedIndexes   []SIndexChange `json:"added_indexes"`
+	RemovedIndexes []SIndexChange `json:"removed_indexes"`
+
+	AddedConstraints   []SConstraintChange `json:"added_constraints"`
+	DroppedConstraints []SConstraintChange `json:"dropped_constraints"`
+
+	// SQLs are the statements that Sync would execute
+	SQLs []string `json:"sqls"`
+	// Destructive indicates some of the changes are destructive
+	Destructive bool `json:"destructive"`
+}
+
+// SSyncPlan is a dry-run report of synchronizing tables of a database
+type SSyncPlan struct {
+	Database DBName           `json:"database"`
+	Tables   []STableSyncPlan `json:"tables"`
+}
+
+// HasChanges returns wether the table has any change
+func (tp *STableSyncPlan) HasChanges() bool {
+	return len(tp.SQLs) > 0
+}
+
+// HasChanges returns wether any table has changes
+func (p *SSyncPlan) HasChanges() bool {
+	for i := range p.Tables {
+		if p.Tables[i].HasChanges() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDestructive returns wether any change of the plan is destructive
+func (p *SSyncPlan) IsDestructive() bool {
+	for i := range p.Tables {
+		if p.Tables[i].Destructive {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON returns the JSON representation of the plan
+func (p *SSyncPlan) JSON() jsonutils.JSONObject {
+	return jsonutils.Marshal(p)
+}
+
+// String returns the text representation of the plan
+func (p *SSyncPlan) String() string {
+	var buf bytes.Buffer
+	for i := range p.Tables {
+		tp := &p.Tables[i]
+		if !tp.HasChanges() && !tp.Destructive {
+			continue
+		}
+		flag := ""
+		if tp.Destructive {
+			flag = " [DESTRUCTIVE]"
+		}
+		if tp.Create {
+			fmt.Fprintf(&buf, "table %s: create%s\n", tp.Table, flag)
+		} else {
+			fmt.Fprintf(&buf, "table %s: alter%s\n", tp.Table, flag)
+		}
+		for _, c := range tp.AddedColumns {
+			fmt.Fprintf(&buf, "  + column %s\n", c.NewDefinition)
+		}
+		for _, c := range tp.RemovedColumns {
+			fmt.Fprintf(&buf, "  - column %s%s\n", c.OldDefinition, changeNote(c.Destructive, c.Reason))
+		}
+		for _, c := range tp.RenamedColumns {
+			fmt.Fprintf(&buf, "  ~ column %s -> %s\n", c.OldName, c.Column)
+		}
+		for _, c := range tp.AlteredColumns {
+			fmt.Fprintf(&buf, "  ~ column %s -> %s%s\n", c.OldDefinition, c.NewDefinition, changeNote(c.Destructive, c.Reason))
+		}
+		for _, idx := range tp.AddedIndexes {
+			fmt.Fprintf(&buf, "  + index %s\n", idx)
+		}
+		for _, idx := range tp.RemovedIndexes {
+			fmt.Fprintf(&buf, "  - index %s\n", idx)
+		}
+		for _, cons := range tp.AddedConstraints {
+			fmt.Fprintf(&buf, "  + constraint %s (%s) -> %s (%s)\n", cons.Name, strings.Join(cons.Columns, ", "), cons.ForeignTable, strings.Join(cons.ForeignKeys, ", "))
+		}
+		for _, cons := range tp.DroppedConstraints {
+			fmt.Fprintf(&buf, "  - constraint %s (%s)%s\n", cons.Name, strings.Join(cons.Columns, ", "), changeNote(false, cons.Reason))
+		}
+	}
+	if buf.Len() == 0 {
+		return fmt.Sprintf("database %s is in sync\n", p.Database)
+	}
+	return buf.String()
+}
+
+func changeNote(destructive bool, reason string) string {
+	switch {
+	case destructive && len(reason) > 0:
+		return fmt.Sprintf(" [DESTRUCTIVE: %s]", reason)
+	case destructive:
+		return " [DESTRUCTIVE]"
+	case len(reason) > 0:
+		return fmt.Sprintf(" [%s]", reason)
+	}
+	return ""
+}
+
+// PlanSync returns the changes that Sync would apply to the tables without executing them
+func (db *SDatabase) PlanSync(specs ...*STableSpec) (*SSyncPlan, error) {
+	return db.PlanSyncWithOptions(SSyncOptions{}, specs...)
+}
+
+// PlanSyncWithOptions returns the changes that SyncWithOptions would apply to the tables without executing them
+func (db *SDatabase) PlanSyncWithOptions(opts SSyncOptions, specs ...*STableSpec) (*SSyncPlan, error) {
+	plan := &SSyncPlan{
+		Database: db.name,
+		Tables:   make([]STableSyncPlan, 0, len(specs)),
+	}
+	for _, ts := range specs {
+		tp, err := ts.PlanSyncWithOptions(opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "plan %s", ts.Name())
+		}
+		plan.Tables = append(plan.Tables, *tp)
+	}
+	return plan, nil
+}
+
+// PlanSync returns the changes that Sync would apply to the table without executing them
+func (ts *STableSpec) PlanSync() (*STableSyncPlan, error) {
+	return ts.PlanSyncWithOptions(SSyncOptions{})
+}
+
+// PlanSyncWithOptions returns the changes that SyncWithOptions would apply to the table
+// without executing them, only the changes executed are destructive
+func (ts *STableSpec) PlanSyncWithOptions(opts SSyncOptions) (*STableSyncPlan, error) {
+	tp := &STableSyncPlan{Table: ts.Name()}
+	if !ts.Exists() {
+		tp.Create = true
+		for _, col := range ts.Columns() {
+			tp.AddedColumns = append(tp.AddedColumns, SColumnChange{
+				Column:        col.Name(),
+				NewDefinition: col.DefinitionString(),
+			})
+		}
+		for _, idx := range ts._indexes {
+			tp.AddedIndexes = append(tp.AddedIndexes, indexChange(idx))
+		}
+		tp.SQLs = ts.CreateSQLs()
+		return tp, nil
+	}
+
+	changes, constraints, err := ts.tableChanges()
+	if err != nil {
+		return nil, errors.Wrap(err, "tableChanges")
+	}
+	affected := make(map[string]string)
+	for _, col := range changes.AddColumns {
+		tp.AddedColumns = append(tp.AddedColumns, SColumnChange{
+			Column:        col.Name(),
+			NewDefinition: col.DefinitionString(),
+		})
+	}
+	narrowed, skipped := applySyncPolicy(changes, opts)
+	for _, col := range changes.RemoveColumns {
+		tp.RemovedColumns = append(tp.RemovedColumns, SColumnChange{
+			Column:        col.Name(),
+			OldDefinition: col.DefinitionString(),
+			Reason:        "kept, dropping is not allowed",
+		})
+	}
+	for _, col := range changes.DropColumns {
+		tp.RemovedColumns = append(tp.RemovedColumns, SColumnChange{
+			Column:        col.Name(),
+			OldDefinition: col.DefinitionString(),
+			Destructive:   true,
+			Reason:        "column data is dropped",
+		})
+		affected[col.Name()] = "column removed"
+	}
+	for _, upd := range skipped {
+		_, reason := IsNarrowingChange(upd.OldCol, upd.NewCol)
+		tp.AlteredColumns = append(tp.AlteredColumns, SColumnChange{
+			Column:        upd.NewCol.Name(),
+			OldDefinition: upd.OldCol.DefinitionString(),
+			NewDefinition: upd.NewCol.DefinitionString(),
+			Reason:        "skipped, narrowing is not allowed: " + reason,
+		})
+	}
+	isNarrowed := make(map[string]bool)
+	for _, upd := range narrowed {
+		isNarrowed[upd.NewCol.Name()] = true
+	}
+	for _, upd := range changes.UpdatedColumns {
+		change := SColumnChange{
+			Column:        upd.NewCol.Name(),
+			OldDefinition: upd.OldCol.DefinitionString(),
+			NewDefinition: upd.NewCol.DefinitionString(),
+		}
+		if upd.OldCol.Name() != upd.NewCol.Name() {
+			change.OldName = upd.OldCol.Name()
+			tp.RenamedColumns = append(tp.RenamedColumns, change)
+			affected[upd.OldCol.Name()] = "column renamed"
+		}
+		if upd.OldCol.DefinitionString() != strings.Replace(upd.NewCol.DefinitionString(), upd.NewCol.Name(), upd.OldCol.Name(), 1) || upd.OldCol.IsPrimary() != upd.NewCol.IsPrimary() {
+			if isNarrowed[upd.NewCol.Name()] {
+				_, change.Reason = IsNarrowingChange(upd.OldCol, upd.NewCol)
+				change.Destructive = true
+				affected[upd.OldCol.Name()] = "column " + change.Reason
+			} else if upd.OldCol.IsPrimary() != upd.NewCol.IsPrimary() {
+				change.Reason = "primary key changed"
+			} else if upd.OldCol.IsNullable() && !upd.NewCol.IsNullable() && len(upd.NewCol.Default()) == 0 {
+				change.Reason = "becomes not null"
+			}
+			tp.AlteredColumns = append(tp.AlteredColumns, change)
+		}
+	}
+	for _, idx := range changes.AddIndexes {
+		tp.AddedIndexes = append(tp.AddedIndexes, indexChange(idx))
+	}
+	for _, idx := range changes.RemoveIndexes {
+		tp.RemovedIndexes = append(tp.RemovedIndexes, indexChange(idx))
+	}
+	for _, cons := range changes.AddConstraints {
+		tp.AddedConstraints = append(tp.AddedConstraints, constraintChange(cons, ""))
+	}
+	for _, cons := range changes.RemoveConstraints {
+		tp.DroppedConstraints = append(tp.DroppedConstraints, constraintChange(cons, "definition changed"))
+	}
+	for _, cons := range constraints {
+		dropped := false
+		for _, rm := range changes.RemoveConstraints {
+			if rm.Name() == cons.Name() {
+				dropped = true
+				break
+			}
+		}
+		if dropped {
+			continue
+		}
+		for _, col := range cons.columns {
+			if reason, ok := affected[col]; ok {
+				tp.DroppedConstraints = append(tp.DroppedConstraints, constraintChange(cons, reason))
+				break
+			}
+		}
+	}
+	for _, c := range append(tp.RemovedColumns, tp.AlteredColumns...) {
+		if c.Destructive {
+			tp.Destructive = true
+		}
+	}
+	tp.SQLs = ts.Database().backend.CommitTableChangeSQL(ts, *changes)
+	return tp, nil
+}
+
+func constraintChange(cons STableConstraint, reason string) SConstraintChange {
+	return SConstraintChange{
+		Name:         cons.Name(),
+		Columns:      cons.columns,
+		ForeignTable: cons.foreignTable,
+		ForeignKeys:  cons.foreignKeys,
+		Reason:       reason,
+	}
+}
+
+func indexChange(idx STableIndex) SIndexChange {
+	return SIndexChange{
+		Name:    idx.Name(),
+		Columns: idx.columns,
+		Unique:  idx.isUnique,
+
+		Type:       idx.indexType,
+		Expression: idx.expression,
+		Where:      idx.where,
+	}
+}
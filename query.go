@@ -15,6 +15,7 @@
 package sqlchemy
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
@@ -59,8 +60,10 @@ type SQuery struct {
 	from     IQuerySource
 	joins    []sQueryJoin
 	where    ICondition
-	groupBy  []IQueryField
-	orderBy  []sQueryOrder
+	// condition that excludes soft-deleted rows
+	softDelete ICondition
+	groupBy    []IQueryField
+	orderBy    []sQueryOrder
 	// having   ICondition
 	limit  int
 	offset int
@@ -69,6 +72,15 @@ type SQuery struct {
 
 	snapshot string
 
+	// common table expressions in WITH clause
+	ctes []*SCTE
+
+	// the last row scanned by AllStringMap, used for keyset pagination
+	lastRow map[string]string
+
+	// usePrimary pins the query to the primary of a database with read replicas
+	usePrimary bool
+
 	db *SDatabase
 }
 
@@ -81,11 +93,13 @@ func (tq *SQuery) Copy() *SQuery {
 		from:        tq.from,
 		joins:       []sQueryJoin{},
 		where:       tq.where,
+		softDelete:  tq.softDelete,
 		groupBy:     []IQueryField{},
 		orderBy:     []sQueryOrder{},
 		limit:       tq.limit,
 		offset:      tq.offset,
 		snapshot:    tq.snapshot,
+		usePrimary:  tq.usePrimary,
 		db:          tq.db,
 	}
 	for i := range tq.fields {
@@ -103,6 +117,7 @@ func (tq *SQuery) Copy() *SQuery {
 	for i := range tq.orderBy {
 		q.orderBy = append(q.orderBy, tq.orderBy[i])
 	}
+	q.ctes = append(q.ctes, tq.ctes...)
 	return q
 }
 
@@ -164,7 +179,9 @@ func (sq *SSubQuery) Query(f ...IQueryField) *SQuery {
 
 // Query of STable generates a new query from a table
 func (tbl *STable) Query(f ...IQueryField) *SQuery {
-	return DoQuery(tbl, f...)
+	q := DoQuery(tbl, f...)
+	q.softDelete = softDeleteCondition(tbl)
+	return q
 }
 
 // Query of STableSpec generates a new query from a STableSpec instance
@@ -308,6 +325,7 @@ func (tq *SQuery) _join(from IQuerySource, on ICondition, joinType QueryJoinType
 func (tq *SQuery) Variables() []interface{} {
 	vars := make([]interface{}, 0)
 	var fromvars []interface{}
+	vars = append(vars, withVariables(tq)...)
 	fields := tq.fields
 	for i := range fields {
 		fromvars = fields[i].Variables()
@@ -323,8 +341,8 @@ func (tq *SQuery) Variables() []interface{} {
 		fromvars = join.condition.Variables()
 		vars = append(vars, fromvars...)
 	}
-	if tq.where != nil {
-		fromvars = tq.where.Variables()
+	if where := tq.whereCondition(); where != nil {
+		fromvars = where.Variables()
 		vars = append(vars, fromvars...)
 	}
 	/*if tq.having != nil {
@@ -356,10 +374,17 @@ func (tq *SQuery) database() *SDatabase {
 
 // Row of SQuery returns an instance of  sql.Row for native data fetching
 func (tq *SQuery) Row() *sql.Row {
+	return tq.RowContext(context.Background())
+}
+
+// RowContext of SQuery returns an instance of sql.Row for native data fetching with context.
+// Interceptors see only the execution of the statement; errors deferred to
+// Scan, including sql.ErrNoRows, are not reported to them.
+func (tq *SQuery) RowContext(ctx context.Context) *sql.Row {
 	sqlstr := tq.String()
 	vars := tq.Variables()
 	if DEBUG_SQLCHEMY {
-		sqlDebug("SQuery.Row", sqlstr, vars)
+		sqlDebug(tq.db, "SQuery.Row", sqlstr, vars)
 	}
 	if tq.db == nil {
 		panic("tq.db")
@@ -367,17 +392,84 @@ func (tq *SQuery) Row() *sql.Row {
 	if tq.db.db == nil {
 		panic("tq.db.db")
 	}
-	return tq.db.db.QueryRow(sqlstr, vars...)
+	ctx, done := tq.db.intercept(ctx, QUERY_OP_QUERY_ROW, sqlstr, vars, tq.db.tx != nil)
+	row := tq.db.readConn(tq.usePrimary).QueryRowContext(ctx, tq.db.backend.ReplacePlaceholders(sqlstr), vars...)
+	done(nil, row.Err())
+	return row
 }
 
 // Rows of SQuery returns an instance of sql.Rows for native data fetching
 func (tq *SQuery) Rows() (*sql.Rows, error) {
+	return tq.RowsContext(context.Background())
+}
+
+// RowsContext of SQuery returns an instance of sql.Rows for native data fetching with context
+func (tq *SQuery) RowsContext(ctx context.Context) (*sql.Rows, error) {
 	sqlstr := tq.String()
 	vars := tq.Variables()
 	if DEBUG_SQLCHEMY {
-		sqlDebug("SQuery.Rows", sqlstr, vars)
+		sqlDebug(tq.db, "SQuery.Rows", sqlstr, vars)
+	}
+	if tq.db.tx != nil || tq.db.retryPolicy == nil {
+		return tq.db.queryContext(ctx, tq.usePrimary, sqlstr, vars)
+	}
+	var rows *sql.Rows
+	err := tq.db.withRetry(ctx, QUERY_OP_QUERY, func() error {
+		var err error
+		rows, err = tq.db.queryContext(ctx, tq.usePrimary, sqlstr, vars)
+		return err
+	})
+	return rows, err
+}
+
+// scanFirstRowContext executes the query and scans its first row, reporting
+// the scan outcome to interceptors as QUERY_OP_QUERY_ROW
+func (tq *SQuery) scanFirstRowContext(ctx context.Context, scan func(row IRowScanner) error) error {
+	sqlstr := tq.String()
+	vars := tq.Variables()
+	if DEBUG_SQLCHEMY {
+		sqlDebug(tq.db, "SQuery.Row", sqlstr, vars)
+	}
+	if tq.db.tx != nil || tq.db.retryPolicy == nil {
+		return tq.db.queryRowContext(ctx, tq.usePrimary, sqlstr, vars, scan)
+	}
+	return tq.db.withRetry(ctx, QUERY_OP_QUERY_ROW, func() error {
+		return tq.db.queryRowContext(ctx, tq.usePrimary, sqlstr, vars, scan)
+	})
+}
+
+func (db *SDatabase) queryRowContext(ctx context.Context, usePrimary bool, sqlstr string, vars []interface{}, scan func(row IRowScanner) error) error {
+	ctx, done := db.intercept(ctx, QUERY_OP_QUERY_ROW, sqlstr, vars, db.tx != nil)
+	err := db.wrapError(ctx, queryFirstRow(ctx, db.readConn(usePrimary), db.backend.ReplacePlaceholders(sqlstr), vars, scan))
+	done(nil, err)
+	return err
+}
+
+func queryFirstRow(ctx context.Context, conn iSqlConn, sqlstr string, vars []interface{}, scan func(row IRowScanner) error) error {
+	rows, err := conn.QueryContext(ctx, sqlstr, vars...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	err = scan(rows)
+	if err != nil {
+		return err
 	}
-	return tq.db.db.Query(sqlstr, vars...)
+	return rows.Close()
+}
+
+func (db *SDatabase) queryContext(ctx context.Context, usePrimary bool, sqlstr string, vars []interface{}) (*sql.Rows, error) {
+	ctx, done := db.intercept(ctx, QUERY_OP_QUERY, sqlstr, vars, db.tx != nil)
+	rows, err := db.readConn(usePrimary).QueryContext(ctx, db.backend.ReplacePlaceholders(sqlstr), vars...)
+	err = db.wrapError(ctx, err)
+	done(nil, err)
+	return rows, err
 }
 
 // Count of SQuery returns the count of a query
@@ -396,17 +488,25 @@ func (tq *SQuery) CountQuery() *SQuery {
 		fields: []IQueryField{
 			COUNT("count"),
 		},
-		from: tq2.SubQuery(),
-		db:   tq.database(),
+		from:       tq2.SubQuery(),
+		usePrimary: tq.usePrimary,
+		db:         tq.database(),
 	}
 	return cq
 }
 
 // CountWithError of SQuery returns the row count of a query
 func (tq *SQuery) CountWithError() (int, error) {
+	return tq.CountWithErrorContext(context.Background())
+}
+
+// CountWithErrorContext of SQuery returns the row count of a query with context
+func (tq *SQuery) CountWithErrorContext(ctx context.Context) (int, error) {
 	cq := tq.CountQuery()
 	count := 0
-	err := cq.Row().Scan(&count)
+	err := cq.scanFirstRowContext(ctx, func(row IRowScanner) error {
+		return row.Scan(&count)
+	})
 	if err == nil {
 		return count, nil
 	}
@@ -497,12 +597,28 @@ func (tq *SQuery) rowScan2StringMap(row IRowScanner) (map[string]string, error)
 
 // FirstStringMap returns query result of the first row in a stringmap(map[string]string)
 func (tq *SQuery) FirstStringMap() (map[string]string, error) {
-	return tq.rowScan2StringMap(tq.Row())
+	return tq.FirstStringMapContext(context.Background())
+}
+
+// FirstStringMapContext returns query result of the first row in a stringmap(map[string]string) with context
+func (tq *SQuery) FirstStringMapContext(ctx context.Context) (map[string]string, error) {
+	var result map[string]string
+	err := tq.scanFirstRowContext(ctx, func(row IRowScanner) error {
+		var err error
+		result, err = tq.rowScan2StringMap(row)
+		return err
+	})
+	return result, err
 }
 
 // AllStringMap returns query result of all rows in an array of stringmap(map[string]string)
 func (tq *SQuery) AllStringMap() ([]map[string]string, error) {
-	rows, err := tq.Rows()
+	return tq.AllStringMapContext(context.Background())
+}
+
+// AllStringMapContext returns query result of all rows in an array of stringmap(map[string]string) with context
+func (tq *SQuery) AllStringMapContext(ctx context.Context) ([]map[string]string, error) {
+	rows, err := tq.RowsContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -511,10 +627,16 @@ func (tq *SQuery) AllStringMap() ([]map[string]string, error) {
 	for rows.Next() {
 		result, err := tq.rowScan2StringMap(rows)
 		if err != nil {
-			return nil, err
+			return nil, tq.db.wrapError(ctx, err)
 		}
 		results = append(results, result)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, tq.db.wrapError(ctx, err)
+	}
+	if len(results) > 0 {
+		tq.lastRow = results[len(results)-1]
+	}
 	return results, nil
 }
 
@@ -544,16 +666,19 @@ func callAfterQuery(val reflect.Value) {
 
 // First return query result of first row and store the result in a data struct
 func (tq *SQuery) First(dest interface{}) error {
-	mapResult, err := tq.FirstStringMap()
-	if err != nil {
-		return err
-	}
+	return tq.FirstContext(context.Background(), dest)
+}
+
+// FirstContext return query result of first row and store the result in a data struct with context
+func (tq *SQuery) FirstContext(ctx context.Context, dest interface{}) error {
 	destPtrValue := reflect.ValueOf(dest)
 	if destPtrValue.Kind() != reflect.Ptr {
 		return errors.Wrap(ErrNeedsPointer, "input must be a pointer")
 	}
 	destValue := destPtrValue.Elem()
-	err = mapString2Struct(mapResult, destValue)
+	err := tq.scanFirstRowContext(ctx, func(row IRowScanner) error {
+		return tq.rowScan2Struct(row, destValue)
+	})
 	if err != nil {
 		return err
 	}
@@ -563,6 +688,11 @@ func (tq *SQuery) First(dest interface{}) error {
 
 // All return query results of all rows and store the result in an array of data struct
 func (tq *SQuery) All(dest interface{}) error {
+	return tq.AllContext(context.Background(), dest)
+}
+
+// AllContext return query results of all rows and store the result in an array of data struct with context
+func (tq *SQuery) AllContext(ctx context.Context, dest interface{}) error {
 	arrayType := reflect.TypeOf(dest).Elem()
 
 	if arrayType.Kind() != reflect.Array && arrayType.Kind() != reflect.Slice {
@@ -570,24 +700,25 @@ func (tq *SQuery) All(dest interface{}) error {
 	}
 	elemType := arrayType.Elem()
 
-	mapResults, err := tq.AllStringMap()
+	rows, err := tq.RowsContext(ctx)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	arrayValue := reflect.ValueOf(dest).Elem()
-	for _, mapV := range mapResults {
+	for rows.Next() {
 		elemPtrValue := reflect.New(elemType)
 		elemValue := reflect.Indirect(elemPtrValue)
-		err = mapString2Struct(mapV, elemValue)
+		err = tq.rowScan2Struct(rows, elemValue)
 		if err != nil {
-			break
+			return tq.db.wrapError(ctx, err)
 		}
 		callAfterQuery(elemPtrValue)
 		newArray := reflect.Append(arrayValue, elemValue)
 		arrayValue.Set(newArray)
 	}
-	return err
+	return tq.db.wrapError(ctx, rows.Err())
 }
 
 // Row2Map is a utility function that fetch stringmap(map[string]string) from a native sql.Row or sql.Rows
@@ -613,11 +744,16 @@ func (tq *SQuery) RowMap2Struct(result map[string]string, dest interface{}) erro
 
 // Row2Struct is a utility function that fill a struct with the value of a sql.Row or sql.Rows
 func (tq *SQuery) Row2Struct(row IRowScanner, dest interface{}) error {
-	result, err := tq.rowScan2StringMap(row)
+	destPtrValue := reflect.ValueOf(dest)
+	if destPtrValue.Kind() != reflect.Ptr {
+		return errors.Wrap(ErrNeedsPointer, "input must be a pointer")
+	}
+	err := tq.rowScan2Struct(row, destPtrValue.Elem())
 	if err != nil {
 		return err
 	}
-	return tq.RowMap2Struct(result, dest)
+	callAfterQuery(destPtrValue)
+	return nil
 }
 
 // Snapshot of SQuery take a snapshot of the query, so we can tell wether the query is modified later by comparing the SQL with snapshot
@@ -1,11 +1,13 @@
 package sqlchemy
 
 import (
+	"database/sql"
 	"reflect"
 	"testing"
 	"time"
 
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/tristate"
 )
 
 func TestQueryString(t *testing.T) {
@@ -482,6 +484,76 @@ func TestMapString2Struct(t *testing.T) {
 	}
 }
 
+type driverValueScanner []interface{}
+
+func (ds driverValueScanner) Scan(target ...interface{}) error {
+	for i := range target {
+		if i >= len(ds) {
+			return errors.Error("out of range")
+		}
+		err := target[i].(sql.Scanner).Scan(ds[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestStructScanPlan(t *testing.T) {
+	type EmbedStruct struct {
+		Status string
+	}
+	type testStruct struct {
+		*EmbedStruct
+
+		Name    string `json:"name"`
+		Age     *int
+		Ratio   float64
+		IsMale  bool
+		Enabled tristate.TriState
+		Created time.Time
+		Note    sql.NullString
+	}
+	created := time.Date(2023, 4, 5, 6, 7, 8, 123456789, time.UTC)
+	columns := []string{"name", "age", "ratio", "is_male", "enabled", "created", "status", "note", "extra"}
+	row := driverValueScanner{
+		[]byte("John"),
+		int64(20),
+		0.1 + 0.2,
+		false,
+		int64(1),
+		created,
+		"active",
+		nil,
+		"ignored",
+	}
+	plan := getStructScanPlan(reflect.TypeOf(testStruct{}), columns)
+	if plan == nil {
+		t.Fatalf("struct should support direct scanning")
+	}
+	if getStructScanPlan(reflect.TypeOf(testStruct{}), columns) != plan {
+		t.Errorf("scan plan should be cached")
+	}
+	dest := testStruct{IsMale: true}
+	err := plan.scan(row, reflect.ValueOf(&dest).Elem(), nil)
+	if err != nil {
+		t.Fatalf("scan fail %s", err)
+	}
+	age := 20
+	want := testStruct{
+		EmbedStruct: &EmbedStruct{Status: "active"},
+		Name:        "John",
+		Age:         &age,
+		Ratio:       0.1 + 0.2,
+		IsMale:      false,
+		Enabled:     tristate.True,
+		Created:     created,
+	}
+	if !reflect.DeepEqual(dest, want) {
+		t.Errorf("want: %#v got: %#v", want, dest)
+	}
+}
+
 func TestQueryString2(t *testing.T) {
 	SetupMockDatabaseBackend()
 	ResetTableID()
@@ -108,6 +108,7 @@ func queryString(tq *SQuery, tmpFields ...IQueryField) string {
 	qChar := tq.database().backend.QuoteChar()
 
 	var buf bytes.Buffer
+	buf.WriteString(withClause(tq))
 	buf.WriteString("SELECT ")
 	if tq.distinct {
 		buf.WriteString("DISTINCT ")
@@ -189,8 +190,8 @@ func queryString(tq *SQuery, tmpFields ...IQueryField) string {
 			buf.WriteString(whereCls)
 		}
 	}
-	if tq.where != nil {
-		whereCls := tq.where.WhereClause()
+	if where := tq.whereCondition(); where != nil {
+		whereCls := where.WhereClause()
 		if len(whereCls) > 0 {
 			buf.WriteString(" WHERE ")
 			buf.WriteString(whereCls)
@@ -76,6 +76,8 @@ func (db *SDatabase) NewRawQuery(sqlStr string, fields ...string) *SQuery {
 		db:     db,
 		rawSql: sqlStr,
 		fields: qfs,
+		// raw queries are mostly metadata queries, e.g. show tables, which should see the latest schema
+		usePrimary: true,
 	}
 	return &q
 }
@@ -0,0 +1,131 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"database/sql"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// IReplicaBalancer selects the replica to serve a read
+type IReplicaBalancer interface {
+	// Select returns the index of the selected replica among count replicas
+	Select(count int) int
+}
+
+type sRoundRobinBalancer struct {
+	next uint64
+}
+
+// NewRoundRobinBalancer returns a balancer selecting the replicas in turn
+func NewRoundRobinBalancer() IReplicaBalancer {
+	return &sRoundRobinBalancer{}
+}
+
+func (b *sRoundRobinBalancer) Select(count int) int {
+	return int((atomic.AddUint64(&b.next, 1) - 1) % uint64(count))
+}
+
+type sRandomBalancer struct{}
+
+// NewRandomBalancer returns a balancer selecting a replica randomly
+func NewRandomBalancer() IReplicaBalancer {
+	return sRandomBalancer{}
+}
+
+func (b sRandomBalancer) Select(count int) int {
+	return rand.Intn(count)
+}
+
+// SReplicaOptions is the options of routing reads to replicas
+type SReplicaOptions struct {
+	// Balancer selects the replica to serve a read, round robin if nil
+	Balancer IReplicaBalancer
+	// StickyWindow is the duration after a write during which the reads go to the primary,
+	// so that the reads see the writes not yet replicated, 0 disables the stickiness
+	StickyWindow time.Duration
+}
+
+// sReplicaSet is the read replicas of a database
+type sReplicaSet struct {
+	replicas []*sql.DB
+	options  SReplicaOptions
+
+	// lastWrite is the time in UnixNano of the last write to the primary
+	lastWrite int64
+}
+
+// SetReplicas registers the read replicas of the database, SQuery reads are served by the replicas,
+// while writes, transactions, raw queries and the queries pinned by UsePrimary go to the primary
+func (db *SDatabase) SetReplicas(replicas []*sql.DB, opts *SReplicaOptions) {
+	if len(replicas) == 0 {
+		db.replicas = nil
+		return
+	}
+	set := &sReplicaSet{
+		replicas: replicas,
+	}
+	if opts != nil {
+		set.options = *opts
+	}
+	if set.options.Balancer == nil {
+		set.options.Balancer = NewRoundRobinBalancer()
+	}
+	db.replicas = set
+}
+
+// SetReplicasWithName registers the read replicas of the database with given name
+func SetReplicasWithName(name DBName, replicas []*sql.DB, opts *SReplicaOptions) {
+	GetDBWithName(name).SetReplicas(replicas, opts)
+}
+
+// Replicas returns the read replicas of the database
+func (db *SDatabase) Replicas() []*sql.DB {
+	if db.replicas == nil {
+		return nil
+	}
+	return db.replicas.replicas
+}
+
+// markWrite records the time of a write to the primary for the stickiness of reads
+func (db *SDatabase) markWrite() {
+	if db.replicas != nil && db.replicas.options.StickyWindow > 0 {
+		atomic.StoreInt64(&db.replicas.lastWrite, time.Now().UnixNano())
+	}
+}
+
+// readConn returns the connection to serve a read, a replica unless the database is in a
+// transaction, the read is pinned to the primary or within the sticky window after a write
+func (db *SDatabase) readConn(usePrimary bool) iSqlConn {
+	if db.tx != nil || usePrimary || db.replicas == nil {
+		return db.conn()
+	}
+	set := db.replicas
+	if set.options.StickyWindow > 0 {
+		lastWrite := atomic.LoadInt64(&set.lastWrite)
+		if lastWrite > 0 && time.Since(time.Unix(0, lastWrite)) < set.options.StickyWindow {
+			return db.db
+		}
+	}
+	return set.replicas[set.options.Balancer.Select(len(set.replicas))]
+}
+
+// UsePrimary of SQuery pins the query to the primary of a database with read replicas
+func (tq *SQuery) UsePrimary() *SQuery {
+	tq.usePrimary = true
+	return tq
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"yunion.io/x/log"
+)
+
+// SRetryPolicy is the policy of retrying the operations failed with a retryable
+// error, e.g. a deadlock or a serialization failure
+type SRetryPolicy struct {
+	// MaxAttempts is the maximal number of attempts, including the first one
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff is the upper limit of the delay
+	MaxBackoff time.Duration
+	// Multiplier is the factor the delay grows by after each retry
+	Multiplier float64
+	// Jitter is the fraction, in [0, 1], of the delay that is randomized
+	Jitter float64
+	// Retryable decides whether an error is retryable, if nil, errors classified
+	// as ErrDeadlock or ErrLockTimeout are retried
+	Retryable func(err error) bool
+}
+
+// SRetryEvent describes a retry of an operation
+type SRetryEvent struct {
+	// Database is the name of the database
+	Database DBName
+	// Operation is the name of the operation retried
+	Operation string
+	// Attempt is the number of the failed attempt, starting from 1
+	Attempt int
+	// MaxAttempts is the maximal number of attempts of the policy
+	MaxAttempts int
+	// Backoff is the delay before the next attempt
+	Backoff time.Duration
+	// Error is the error of the failed attempt
+	Error error
+}
+
+// IRetryInterceptor is an optional interface of IQueryInterceptor, which is
+// notified before an operation is retried
+type IRetryInterceptor interface {
+	OnRetry(ctx context.Context, event *SRetryEvent)
+}
+
+// DefaultRetryPolicy returns a policy of 3 attempts with exponential backoff from 10ms up to 1s
+func DefaultRetryPolicy() *SRetryPolicy {
+	return &SRetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// SetRetryPolicy sets the policy of retrying the statements executed outside of
+// a transaction and the closures of RunInTx, nil disables retrying
+func (db *SDatabase) SetRetryPolicy(policy *SRetryPolicy) {
+	db.retryPolicy = policy
+}
+
+// backoff returns the delay before the retry following the attempt
+func (policy *SRetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(policy.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		if policy.Multiplier > 1 {
+			delay *= policy.Multiplier
+		}
+		if policy.MaxBackoff > 0 && delay >= float64(policy.MaxBackoff) {
+			break
+		}
+	}
+	if policy.MaxBackoff > 0 && delay > float64(policy.MaxBackoff) {
+		delay = float64(policy.MaxBackoff)
+	}
+	if policy.Jitter > 0 {
+		delay -= delay * policy.Jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// isRetryable returns whether the error is retryable by the retry policy
+func (db *SDatabase) isRetryable(err error) bool {
+	if db.retryPolicy == nil || err == nil {
+		return false
+	}
+	if db.retryPolicy.Retryable != nil {
+		return db.retryPolicy.Retryable(err)
+	}
+	switch db.ClassifyError(err) {
+	case ErrDeadlock, ErrLockTimeout:
+		return true
+	}
+	return false
+}
+
+// withRetry calls fn and retries it on retryable errors following the retry policy,
+// it should only be called with idempotent operations outside of a transaction
+func (db *SDatabase) withRetry(ctx context.Context, op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !db.isRetryable(err) || attempt >= db.retryPolicy.MaxAttempts {
+			return err
+		}
+		event := &SRetryEvent{
+			Database:    db.name,
+			Operation:   op,
+			Attempt:     attempt,
+			MaxAttempts: db.retryPolicy.MaxAttempts,
+			Backoff:     db.retryPolicy.backoff(attempt),
+			Error:       err,
+		}
+		log.Warningf("%s of database %s failed at attempt %d/%d, retry after %s: %s", op, db.name, attempt, event.MaxAttempts, event.Backoff, err)
+		for i := range db.interceptors {
+			if interceptor, ok := db.interceptors[i].(IRetryInterceptor); ok {
+				interceptor.OnRetry(ctx, event)
+			}
+		}
+		timer := time.NewTimer(event.Backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return wrapContextError(ctx, err)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/util/reflectutils"
+	"yunion.io/x/pkg/utils"
+)
+
+// sStructScanPlan maps the columns of a query to the fields of a struct type,
+// so that a row can be scanned directly into the struct fields
+type sStructScanPlan struct {
+	// index path of struct field for each column, nil if the column has no corresponding field
+	fieldIndexes [][]int
+}
+
+type sStructScanPlanKey struct {
+	structType reflect.Type
+	columns    string
+}
+
+var structScanPlanCache sync.Map
+
+type sStructScanField struct {
+	index []int
+	info  reflectutils.SStructFieldInfo
+	kebab string
+}
+
+// fetchStructScanFields returns the fields of a struct type in the order of reflectutils.FetchStructFieldValueSet
+// returns false if the struct has tagged anonymous fields, whose field names might be rewritten by reflectutils
+func fetchStructScanFields(structType reflect.Type, parentIndex []int) ([]sStructScanField, bool) {
+	fields := make([]sStructScanField, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		sf := structType.Field(i)
+		if !gotypes.IsFieldExportable(sf.Name) {
+			continue
+		}
+		index := append(append([]int{}, parentIndex...), i)
+		if sf.Anonymous {
+			sft := sf.Type
+			if sft.Kind() == reflect.Ptr {
+				sft = sft.Elem()
+			}
+			if sft.Kind() == reflect.Interface {
+				return nil, false
+			}
+			if sft.Kind() == reflect.Struct && sft != gotypes.TimeType {
+				if len(sf.Tag) > 0 {
+					return nil, false
+				}
+				subFields, ok := fetchStructScanFields(sft, index)
+				if !ok {
+					return nil, false
+				}
+				fields = append(fields, subFields...)
+				continue
+			}
+		}
+		info := reflectutils.ParseStructFieldJsonInfo(sf)
+		if info.Ignore {
+			continue
+		}
+		fields = append(fields, sStructScanField{
+			index: index,
+			info:  info,
+			kebab: utils.CamelSplit(sf.Name, "_"),
+		})
+	}
+	return fields, true
+}
+
+// getStructScanPlan returns the cached scan plan of the columns for the struct type,
+// nil if the struct type is not supported by direct scanning
+func getStructScanPlan(structType reflect.Type, columns []string) *sStructScanPlan {
+	key := sStructScanPlanKey{
+		structType: structType,
+		columns:    strings.Join(columns, ","),
+	}
+	if plan, ok := structScanPlanCache.Load(key); ok {
+		return plan.(*sStructScanPlan)
+	}
+	var plan *sStructScanPlan
+	if structType.Kind() == reflect.Struct {
+		fields, ok := fetchStructScanFields(structType, nil)
+		if ok {
+			plan = &sStructScanPlan{
+				fieldIndexes: make([][]int, len(columns)),
+			}
+			for i, col := range columns {
+				// the same matching rules as reflectutils.SStructFieldValueSet.GetStructFieldIndex
+				kebabName := utils.CamelSplit(col, "_")
+				capName := utils.Capitalize(col)
+				for j := range fields {
+					if fields[j].info.MarshalName() == col || fields[j].kebab == kebabName || fields[j].info.FieldName == col || fields[j].info.FieldName == capName {
+						plan.fieldIndexes[i] = fields[j].index
+						break
+					}
+				}
+			}
+		}
+	}
+	structScanPlanCache.Store(key, plan)
+	return plan
+}
+
+// fieldByIndex returns the nested field of the index path, allocating nil embedded struct pointers
+func fieldByIndex(value reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value
+}
+
+// sFieldScanner is a sql.Scanner that converts the column value into a struct field
+type sFieldScanner struct {
+	value reflect.Value
+	// scanner of the struct field if the field implements sql.Scanner
+	scanner sql.Scanner
+	// if not nil, keeps the string representation of the column value
+	raw *string
+}
+
+// Scan implementation of sFieldScanner for sql.Scanner
+func (s *sFieldScanner) Scan(src interface{}) error {
+	if s.raw != nil {
+		if src == nil {
+			*s.raw = ""
+		} else {
+			*s.raw = GetStringValue(src)
+		}
+	}
+	if s.scanner != nil {
+		return s.scanner.Scan(src)
+	}
+	if src == nil || !s.value.IsValid() {
+		return nil
+	}
+	return setValueBySQLValue(s.value, src)
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// scan of sStructScanPlan scans a row into the struct destValue, and keeps the string
+// representation of the i-th column in raws[i] if raws[i] is not nil
+func (plan *sStructScanPlan) scan(row IRowScanner, destValue reflect.Value, raws []*string) error {
+	targets := make([]interface{}, len(plan.fieldIndexes))
+	for i, index := range plan.fieldIndexes {
+		scanner := &sFieldScanner{}
+		if raws != nil {
+			scanner.raw = raws[i]
+		}
+		if index != nil {
+			scanner.value = fieldByIndex(destValue, index)
+			ptrType := scanner.value.Addr().Type()
+			if ptrType.Implements(scannerType) && !ptrType.Implements(gotypes.ISerializableType) && !scanner.value.Type().Implements(gotypes.ISerializableType) {
+				scanner.scanner = scanner.value.Addr().Interface().(sql.Scanner)
+			}
+		}
+		targets[i] = scanner
+	}
+	return row.Scan(targets...)
+}
+
+// setValueBySQLValue sets the value of a field by the value returned by database driver,
+// avoiding the round-trip of string conversion when possible
+func setValueBySQLValue(value reflect.Value, src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		if len(v) == 0 {
+			return nil
+		}
+		return setValueBySQLString(value, string(v))
+	case string:
+		if len(v) == 0 {
+			return nil
+		}
+		return setValueBySQLString(value, v)
+	}
+	if !value.CanSet() {
+		return setValueBySQLString(value, GetStringValue(src))
+	}
+	switch value.Type() {
+	case tristate.TriStateType:
+		switch v := src.(type) {
+		case int64:
+			if v == 1 {
+				value.Set(tristate.TriStateTrueValue)
+			} else if v == 0 {
+				value.Set(tristate.TriStateFalseValue)
+			} else {
+				value.Set(tristate.TriStateNoneValue)
+			}
+			return nil
+		case bool:
+			if v {
+				value.Set(tristate.TriStateTrueValue)
+			} else {
+				value.Set(tristate.TriStateFalseValue)
+			}
+			return nil
+		}
+	case gotypes.TimeType:
+		if tm, ok := src.(time.Time); ok {
+			value.Set(reflect.ValueOf(tm))
+			return nil
+		}
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		switch v := src.(type) {
+		case bool:
+			value.SetBool(v)
+			return nil
+		case int64:
+			value.SetBool(v != 0)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := src.(type) {
+		case int64:
+			value.SetInt(v)
+			return nil
+		case uint64:
+			value.SetInt(int64(v))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v := src.(type) {
+		case int64:
+			value.SetUint(uint64(v))
+			return nil
+		case uint64:
+			value.SetUint(v)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := src.(type) {
+		case float64:
+			value.SetFloat(v)
+			return nil
+		case float32:
+			value.SetFloat(float64(v))
+			return nil
+		case int64:
+			value.SetFloat(float64(v))
+			return nil
+		}
+	case reflect.Ptr:
+		if value.Type().Implements(gotypes.ISerializableType) {
+			break
+		}
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return setValueBySQLValue(value.Elem(), src)
+	}
+	return setValueBySQLString(value, GetStringValue(src))
+}
+
+// rowScan2Struct scans a row directly into the struct destValue, falls back to rowScan2StringMap
+// if the struct is not supported by direct scanning. The values of ordering fields are kept for
+// keyset pagination
+func (tq *SQuery) rowScan2Struct(row IRowScanner, destValue reflect.Value) error {
+	queryFields := tq.QueryFields()
+	columns := make([]string, len(queryFields))
+	for i, f := range queryFields {
+		columns[i] = f.Name()
+	}
+	plan := getStructScanPlan(destValue.Type(), columns)
+	if plan == nil {
+		result, err := rowScan2StringMap(columns, row)
+		if err != nil {
+			return err
+		}
+		tq.lastRow = result
+		return mapString2Struct(result, destValue)
+	}
+	var raws []*string
+	if len(tq.orderBy) > 0 {
+		raws = make([]*string, len(columns))
+		for i := range tq.orderBy {
+			for j := range columns {
+				if columns[j] == tq.orderBy[i].field.Name() && raws[j] == nil {
+					raws[j] = new(string)
+				}
+			}
+		}
+	}
+	err := plan.scan(row, destValue, raws)
+	if err != nil {
+		return err
+	}
+	if raws != nil {
+		lastRow := make(map[string]string, len(tq.orderBy))
+		for i := range raws {
+			if raws[i] != nil {
+				lastRow[columns[i]] = *raws[i]
+			}
+		}
+		tq.lastRow = lastRow
+	}
+	return nil
+}
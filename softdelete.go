@@ -0,0 +1,87 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// softDeleteColumns returns the soft-delete flag column and the deleted_at timestamp column of a table, either may be nil
+func softDeleteColumns(spec ITableSpec) (IColumnSpec, IColumnSpec) {
+	var flagCol, deletedAtCol IColumnSpec
+	for _, col := range spec.Columns() {
+		if !col.IsSoftDelete() {
+			continue
+		}
+		if col.IsDateTime() {
+			if deletedAtCol == nil {
+				deletedAtCol = col
+			}
+		} else if flagCol == nil {
+			flagCol = col
+		}
+	}
+	return flagCol, deletedAtCol
+}
+
+// softDeleteCondition returns the condition that excludes the soft-deleted rows of a table, nil if the table is not soft-deletable
+func softDeleteCondition(tbl *STable) ICondition {
+	flagCol, deletedAtCol := softDeleteColumns(tbl.spec)
+	if flagCol != nil {
+		return IsFalse(tbl.Field(flagCol.Name()))
+	}
+	if deletedAtCol != nil {
+		return IsNull(tbl.Field(deletedAtCol.Name()))
+	}
+	return nil
+}
+
+// IsSoftDeletable returns whether the table has a column tagged with soft_delete
+func (ts *STableSpec) IsSoftDeletable() bool {
+	flagCol, deletedAtCol := softDeleteColumns(ts)
+	return flagCol != nil || deletedAtCol != nil
+}
+
+// Unscoped of SQuery removes the condition that excludes the soft-deleted rows,
+// so that the query returns all rows including the soft-deleted
+func (tq *SQuery) Unscoped() *SQuery {
+	tq.softDelete = nil
+	return tq
+}
+
+// SoftDelete marks a record soft-deleted, it sets the soft-delete flag column to true and
+// the deleted_at timestamp column to current time
+func (ts *STableSpec) SoftDelete(dt interface{}) error {
+	return ts.SoftDeleteContext(context.Background(), dt)
+}
+
+// SoftDeleteContext is the context-aware variant of SoftDelete
+func (ts *STableSpec) SoftDeleteContext(ctx context.Context, dt interface{}) error {
+	flagCol, deletedAtCol := softDeleteColumns(ts)
+	if flagCol == nil && deletedAtCol == nil {
+		return errors.Wrapf(ErrNotSoftDeletable, "table %s", ts.Name())
+	}
+	fields := make(map[string]interface{})
+	if flagCol != nil {
+		fields[flagCol.Name()] = true
+	}
+	if deletedAtCol != nil {
+		fields[deletedAtCol.Name()] = time.Now().UTC()
+	}
+	return ts.UpdateFieldsContext(ctx, dt, fields)
+}
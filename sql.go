@@ -15,6 +15,7 @@
 package sqlchemy
 
 import (
+	"context"
 	"database/sql"
 	"strings"
 
@@ -30,6 +31,34 @@ type SDatabase struct {
 	db      *sql.DB
 	name    DBName
 	backend IBackend
+
+	// tx is the transaction state if this is a transactional handle returned by Begin
+	tx *sTxState
+
+	// interceptors are notified of every statement executed by the database
+	interceptors []IQueryInterceptor
+
+	// retryPolicy is the policy of retrying on deadlocks, nil if disabled
+	retryPolicy *SRetryPolicy
+
+	// replicas are the read replicas, nil if reads go to the primary
+	replicas *sReplicaSet
+}
+
+// iSqlConn is the common interface of *sql.DB and *sql.Tx
+type iSqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// conn returns the transaction for a transactional handle, otherwise the *sql.DB
+func (db *SDatabase) conn() iSqlConn {
+	if db.tx != nil {
+		return db.tx.tx
+	}
+	return db.db
 }
 
 // DefaultDB is the name for the default database instance
@@ -106,6 +135,9 @@ func CloseDB() {
 	for n, db := range _db_tbl {
 		names = append(names, n)
 		db.db.Close()
+		for _, replica := range db.Replicas() {
+			replica.Close()
+		}
 	}
 	for _, n := range names {
 		delete(_db_tbl, n)
@@ -146,7 +178,40 @@ func Exec(sql string, args ...interface{}) (sql.Result, error) {
 
 // Exec execute a raw SQL query for a db instance
 func (db *SDatabase) Exec(sql string, args ...interface{}) (sql.Result, error) {
-	return db.db.Exec(sql, args...)
+	return db.ExecContext(context.Background(), sql, args...)
+}
+
+// ExecContext execute a raw SQL query for a db instance with context
+func (db *SDatabase) ExecContext(ctx context.Context, sqlstr string, args ...interface{}) (sql.Result, error) {
+	if db.tx != nil || db.retryPolicy == nil {
+		return db.execContext(ctx, sqlstr, args...)
+	}
+	var result sql.Result
+	err := db.withRetry(ctx, QUERY_OP_EXEC, func() error {
+		var err error
+		result, err = db.execContext(ctx, sqlstr, args...)
+		return err
+	})
+	return result, err
+}
+
+func (db *SDatabase) execContext(ctx context.Context, sqlstr string, args ...interface{}) (sql.Result, error) {
+	ctx, done := db.intercept(ctx, QUERY_OP_EXEC, sqlstr, args, db.tx != nil)
+	result, err := db.conn().ExecContext(ctx, db.backend.ReplacePlaceholders(sqlstr), args...)
+	err = db.wrapError(ctx, err)
+	done(result, err)
+	if db.tx == nil {
+		db.markWrite()
+	}
+	return result, err
+}
+
+// wrapContextError converts the error caused by a canceled or expired context into ErrContextCanceled
+func wrapContextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return &sContextError{ctxErr: ctx.Err(), err: err}
+	}
+	return err
 }
 
 type SSqlResult struct {
@@ -155,38 +220,85 @@ type SSqlResult struct {
 }
 
 func (db *SDatabase) TxBatchExec(sqlstr string, varsList [][]interface{}) ([]SSqlResult, error) {
-	tx, err := db.db.Begin()
+	return db.TxBatchExecContext(context.Background(), sqlstr, varsList)
+}
+
+func (db *SDatabase) TxBatchExecContext(ctx context.Context, sqlstr string, varsList [][]interface{}) ([]SSqlResult, error) {
+	if db.tx != nil {
+		// already in a transaction, which is committed or rollbacked by the caller
+		return db.batchExec(ctx, db.tx.tx, sqlstr, varsList)
+	}
+	if db.retryPolicy == nil {
+		return db.txBatchExec(ctx, sqlstr, varsList)
+	}
+
+	var results []SSqlResult
+	err := db.withRetry(ctx, "TxBatchExec", func() error {
+		var err error
+		results, err = db.txBatchExec(ctx, sqlstr, varsList)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "Begin transaction")
+		return nil, err
+	}
+	return results, nil
+}
+
+func (db *SDatabase) txBatchExec(ctx context.Context, sqlstr string, varsList [][]interface{}) ([]SSqlResult, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(db.wrapError(ctx, err), "Begin transaction")
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(sqlstr)
+	results, err := db.batchExec(ctx, tx, sqlstr, varsList)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Prepare sql %s", SQLPrintf(sqlstr, varsList[0]))
+		return nil, err
+	}
+	for i := range results {
+		if db.isRetryable(results[i].Error) {
+			// a deadlock aborts the whole transaction, rollback and retry the batch
+			return nil, results[i].Error
+		}
+	}
+
+	err = tx.Commit()
+	db.markWrite()
+	if err != nil {
+		return nil, errors.Wrap(db.wrapError(ctx, err), "Commit transaction")
+	}
+
+	return results, nil
+}
+
+func (db *SDatabase) batchExec(ctx context.Context, conn iSqlConn, sqlstr string, varsList [][]interface{}) ([]SSqlResult, error) {
+	stmt, err := conn.PrepareContext(ctx, db.backend.ReplacePlaceholders(sqlstr))
+	if err != nil {
+		return nil, errors.Wrapf(db.wrapError(ctx, err), "Prepare sql %s", db.SQLPrintf(sqlstr, varsList[0]))
 	}
 	defer stmt.Close()
 
 	results := make([]SSqlResult, len(varsList))
 	for i := range varsList {
 		vars := varsList[i]
-		result, err := stmt.Exec(vars...)
+		execCtx, done := db.intercept(ctx, QUERY_OP_EXEC, sqlstr, vars, true)
+		result, err := stmt.ExecContext(execCtx, vars...)
+		err = db.wrapError(ctx, err)
+		done(result, err)
 		results[i] = SSqlResult{
 			Result: result,
 			Error:  err,
 		}
 	}
-
-	err = tx.Commit()
-	if err != nil {
-		return nil, errors.Wrap(err, "Commit transaction")
-	}
-
 	return results, nil
 }
 
 func (db *SDatabase) TxExec(sqlstr string, vars ...interface{}) (sql.Result, error) {
-	results, err := db.TxBatchExec(sqlstr, [][]interface{}{
+	return db.TxExecContext(context.Background(), sqlstr, vars...)
+}
+
+func (db *SDatabase) TxExecContext(ctx context.Context, sqlstr string, vars ...interface{}) (sql.Result, error) {
+	results, err := db.TxBatchExecContext(ctx, sqlstr, [][]interface{}{
 		vars,
 	})
 	if err != nil {
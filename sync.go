@@ -15,6 +15,7 @@
 package sqlchemy
 
 import (
+	stderrors "errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -133,7 +134,7 @@ func diffIndexes2(exists []STableIndex, defs []STableIndex) (diff []STableIndex)
 	for i := 0; i < len(exists); i++ {
 		findDef := false
 		for j := 0; j < len(defs); j++ {
-			if defs[j].IsIdentical(exists[i].columns...) {
+			if defs[j].IsEquivalent(exists[i]) {
 				findDef = true
 				break
 			}
@@ -149,6 +150,27 @@ func diffIndexes(exists []STableIndex, defs []STableIndex) (added []STableIndex,
 	return diffIndexes2(defs, exists), diffIndexes2(exists, defs)
 }
 
+func diffConstraints2(exists []STableConstraint, defs []STableConstraint) []STableConstraint {
+	diff := make([]STableConstraint, 0)
+	for i := range exists {
+		findDef := false
+		for j := range defs {
+			if defs[j].IsIdentical(exists[i]) {
+				findDef = true
+				break
+			}
+		}
+		if !findDef {
+			diff = append(diff, exists[i])
+		}
+	}
+	return diff
+}
+
+func diffConstraints(exists []STableConstraint, defs []STableConstraint) (added []STableConstraint, removed []STableConstraint) {
+	return diffConstraints2(defs, exists), diffConstraints2(exists, defs)
+}
+
 // DropForeignKeySQL returns the SQL statements to do droping foreignkey for a TableSpec
 func (ts *STableSpec) DropForeignKeySQL() []string {
 	ret := make([]string, 0)
@@ -166,7 +188,7 @@ func (ts *STableSpec) DropForeignKeySQL() []string {
 	if db.backend.IsSupportIndexAndContraints() {
 		_, constraints, err := ts.fetchIndexesAndConstraints()
 		if err != nil {
-			if errors.Cause(err) != ErrTableNotExists {
+			if !stderrors.Is(err, ErrTableNotExists) {
 				log.Errorf("fetchIndexesAndConstraints fail %s", err)
 			}
 			return nil
@@ -215,10 +237,16 @@ type STableChanges struct {
 	RemoveIndexes []STableIndex
 	AddIndexes    []STableIndex
 
+	// foreign key constraints
+	RemoveConstraints []STableConstraint
+	AddConstraints    []STableConstraint
+
 	// Columns
 	RemoveColumns  []IColumnSpec
 	UpdatedColumns []SUpdateColumnSpec
 	AddColumns     []IColumnSpec
+	// DropColumns are the removed columns really dropped, see SSyncOptions.AllowDrop
+	DropColumns []IColumnSpec
 
 	OldColumns []IColumnSpec
 }
@@ -226,54 +254,239 @@ type STableChanges struct {
 // SyncSQL returns SQL statements that make table in database consistent with TableSpec definitions
 // by comparing table definition derived from TableSpec and that in database
 func (ts *STableSpec) SyncSQL() []string {
+	return ts.SyncSQLWithOptions(SSyncOptions{})
+}
+
+// SyncSQLWithOptions returns the SQL statements of SyncSQL, dropping and narrowing
+// columns as allowed by opts
+func (ts *STableSpec) SyncSQLWithOptions(opts SSyncOptions) []string {
 	if !ts.Exists() {
 		log.Debugf("table %s not created yet", ts.name)
 		return ts.CreateSQLs()
 	}
 
+	changes, _, err := ts.tableChanges()
+	if err != nil {
+		if !stderrors.Is(err, ErrTableNotExists) {
+			log.Errorf("tableChanges fail %s", err)
+		}
+		return nil
+	}
+	narrowed, _ := applySyncPolicy(changes, opts)
+	err = ts.checkTruncation(narrowed)
+	if err != nil {
+		log.Errorf("checkTruncation fail %s", err)
+		return nil
+	}
+
+	return ts.Database().backend.CommitTableChangeSQL(ts, *changes)
+}
+
+// tableChanges compares the table in database with the TableSpec definitions,
+// the existing constraints are returned as well
+func (ts *STableSpec) tableChanges() (*STableChanges, []STableConstraint, error) {
 	var addIndexes, removeIndexes []STableIndex
+	var addConstraints, removeConstraints []STableConstraint
+	var constraints []STableConstraint
 
 	if ts.Database().backend.IsSupportIndexAndContraints() {
-		indexes, _, err := ts.fetchIndexesAndConstraints()
+		var indexes []STableIndex
+		var err error
+		indexes, constraints, err = ts.fetchIndexesAndConstraints()
 		if err != nil {
-			if errors.Cause(err) != ErrTableNotExists {
-				log.Errorf("fetchIndexesAndConstraints fail %s", err)
-			}
-			return nil
+			return nil, nil, errors.Wrap(err, "fetchIndexesAndConstraints")
 		}
-		addIndexes, removeIndexes = diffIndexes(indexes, ts._indexes)
+		addIndexes, removeIndexes = diffIndexes(indexes, ts.Indexes())
+		addConstraints, removeConstraints = diffConstraints(constraints, ts.ForeignKeys())
 	}
 
 	cols, err := ts.Database().backend.FetchTableColumnSpecs(ts)
 	if err != nil {
-		log.Errorf("fetchColumnDefs fail: %s", err)
-		return nil
+		return nil, nil, errors.Wrap(err, "fetchColumnDefs")
 	}
 
 	remove, update, add := DiffCols(ts.name, cols, ts.Columns())
 
-	return ts.Database().backend.CommitTableChangeSQL(ts, STableChanges{
-		RemoveIndexes:  removeIndexes,
-		AddIndexes:     addIndexes,
-		RemoveColumns:  remove,
-		UpdatedColumns: update,
-		AddColumns:     add,
-		OldColumns:     cols,
-	})
+	return &STableChanges{
+		RemoveIndexes:     removeIndexes,
+		AddIndexes:        addIndexes,
+		RemoveConstraints: removeConstraints,
+		AddConstraints:    addConstraints,
+		RemoveColumns:     remove,
+		UpdatedColumns:    update,
+		AddColumns:        add,
+		OldColumns:        cols,
+	}, constraints, nil
+}
+
+// TSyncStrategy is the way to apply the changes of a table
+type TSyncStrategy string
+
+const (
+	// SYNC_STRATEGY_DEFAULT alters the table by the SQLs of SyncSQL, which may block writes
+	SYNC_STRATEGY_DEFAULT = TSyncStrategy("")
+	// SYNC_STRATEGY_ONLINE alters the table without blocking writes, supported by MySQL
+	SYNC_STRATEGY_ONLINE = TSyncStrategy("online")
+)
+
+const (
+	// SYNC_STAGE_ALTER is the stage of altering a table in place
+	SYNC_STAGE_ALTER = "alter"
+	// SYNC_STAGE_COPY is the stage of copying rows to a shadow table
+	SYNC_STAGE_COPY = "copy"
+	// SYNC_STAGE_SWAP is the stage of swapping a shadow table with the table
+	SYNC_STAGE_SWAP = "swap"
+	// SYNC_STAGE_DROP reports a column dropped by a sync
+	SYNC_STAGE_DROP = "drop"
+	// SYNC_STAGE_NARROW reports a column narrowed by a sync
+	SYNC_STAGE_NARROW = "narrow"
+)
+
+// SSyncProgress reports the progress of a sync
+type SSyncProgress struct {
+	Table string
+	Stage string
+	// SQL is the statement being executed
+	SQL string
+	// Column is the column dropped or narrowed
+	Column string
+	// CopiedRows is the number of rows copied to the shadow table
+	CopiedRows int64
+	// TotalRows is the estimated number of rows of the table
+	TotalRows int64
+}
+
+// SSyncOptions are the options of STableSpec.SyncWithOptions
+type SSyncOptions struct {
+	Strategy TSyncStrategy
+	// ChunkSize is the number of rows copied at a time to a shadow table, 1000 by default
+	ChunkSize int
+	// Progress is called on the progress of a sync, which is logged if nil
+	Progress func(p SSyncProgress)
+	// AllowDrop drops the columns removed from the table spec, which are kept by default
+	AllowDrop bool
+	// AllowNarrowing applies the column changes that may truncate data, e.g. shortening
+	// a varchar, if no non-null data would be truncated. They are skipped by default
+	AllowNarrowing bool
+}
+
+// GetChunkSize returns the chunk size of copying rows
+func (opts SSyncOptions) GetChunkSize() int {
+	if opts.ChunkSize <= 0 {
+		return 1000
+	}
+	return opts.ChunkSize
+}
+
+// Report reports the progress of a sync
+func (opts SSyncOptions) Report(p SSyncProgress) {
+	if opts.Progress != nil {
+		opts.Progress(p)
+		return
+	}
+	switch p.Stage {
+	case SYNC_STAGE_COPY:
+		log.Infof("sync %s: copied %d/%d rows", p.Table, p.CopiedRows, p.TotalRows)
+	case SYNC_STAGE_DROP, SYNC_STAGE_NARROW:
+		log.Warningf("sync %s: %s column %s", p.Table, p.Stage, p.Column)
+	default:
+		log.Infof("sync %s: %s %s", p.Table, p.Stage, p.SQL)
+	}
+}
+
+func (db *SDatabase) execSyncSQLs(sqls []string) error {
+	for _, sql := range sqls {
+		log.Infof(sql)
+		_, err := db.Exec(sql)
+		if err != nil {
+			log.Errorf("exec sql error %s: %s", sql, err)
+			return err
+		}
+	}
+	return nil
 }
 
 // Sync executes the SQLs to synchronize the DB definion of s SQL database
 // by applying the SQL statements generated by SyncSQL()
 func (ts *STableSpec) Sync() error {
-	sqls := ts.SyncSQL()
-	if sqls != nil {
-		for _, sql := range sqls {
-			log.Infof(sql)
-			_, err := ts.Database().Exec(sql)
-			if err != nil {
-				log.Errorf("exec sql error %s: %s", sql, err)
-				return err
-			}
+	return ts.SyncWithOptions(SSyncOptions{})
+}
+
+// SyncWithOptions synchronizes the DB definition of the table, applying the changes
+// with the strategy of opts
+func (ts *STableSpec) SyncWithOptions(opts SSyncOptions) error {
+	if !ts.Exists() {
+		return ts.Database().execSyncSQLs(ts.CreateSQLs())
+	}
+	changes, _, err := ts.tableChanges()
+	if err != nil {
+		if stderrors.Is(err, ErrTableNotExists) {
+			return nil
+		}
+		return errors.Wrap(err, "tableChanges")
+	}
+	narrowed, _ := applySyncPolicy(changes, opts)
+	err = ts.checkTruncation(narrowed)
+	if err != nil {
+		return errors.Wrap(err, "checkTruncation")
+	}
+	err = ts.Database().backend.CommitTableChanges(ts, *changes, opts)
+	if err != nil {
+		return err
+	}
+	for _, col := range changes.DropColumns {
+		opts.Report(SSyncProgress{Table: ts.Name(), Stage: SYNC_STAGE_DROP, Column: col.Name()})
+	}
+	for _, cols := range narrowed {
+		opts.Report(SSyncProgress{Table: ts.Name(), Stage: SYNC_STAGE_NARROW, Column: cols.NewCol.Name()})
+	}
+	return nil
+}
+
+// applySyncPolicy moves the removed columns to DropColumns if dropping is allowed,
+// and keeps the narrowing changes of columns only if narrowing is allowed. The
+// narrowing changes kept and those skipped are returned
+func applySyncPolicy(changes *STableChanges, opts SSyncOptions) ([]SUpdateColumnSpec, []SUpdateColumnSpec) {
+	if opts.AllowDrop {
+		changes.DropColumns = changes.RemoveColumns
+		changes.RemoveColumns = nil
+	}
+	narrowed := make([]SUpdateColumnSpec, 0)
+	skipped := make([]SUpdateColumnSpec, 0)
+	updated := make([]SUpdateColumnSpec, 0, len(changes.UpdatedColumns))
+	for _, cols := range changes.UpdatedColumns {
+		narrowing, reason := IsNarrowingChange(cols.OldCol, cols.NewCol)
+		switch {
+		case !narrowing:
+			updated = append(updated, cols)
+		case opts.AllowNarrowing:
+			updated = append(updated, cols)
+			narrowed = append(narrowed, cols)
+		default:
+			log.Warningf("skip narrowing column %s: %s", cols.OldCol.Name(), reason)
+			skipped = append(skipped, cols)
+		}
+	}
+	changes.UpdatedColumns = updated
+	return narrowed, skipped
+}
+
+// checkTruncation returns ErrDataTruncated if any non-null value would be
+// truncated by the narrowing changes
+func (ts *STableSpec) checkTruncation(narrowed []SUpdateColumnSpec) error {
+	tbl := ts.Instance()
+	for _, cols := range narrowed {
+		field := &STableField{table: tbl, spec: cols.OldCol}
+		cond := truncateCondition(field, cols.OldCol, cols.NewCol)
+		if cond == nil {
+			continue
+		}
+		cnt, err := DoQuery(tbl, field).Filter(cond).CountWithError()
+		if err != nil {
+			return errors.Wrapf(err, "count truncated rows of %s", cols.OldCol.Name())
+		}
+		if cnt > 0 {
+			return errors.Wrapf(ErrDataTruncated, "%d rows of column %s of %s", cnt, cols.OldCol.Name(), ts.Name())
 		}
 	}
 	return nil
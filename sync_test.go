@@ -168,3 +168,27 @@ func TestSync(t *testing.T) {
 		t.Errorf("Got: %s", sqls)
 	}
 }
+
+func TestIntegerTypeBits(t *testing.T) {
+	cases := []struct {
+		colType  string
+		bits     int
+		unsigned bool
+	}{
+		{"TINYINT(1)", 8, false},
+		{"SMALLINT(5) UNSIGNED", 16, true},
+		{"INT(11)", 32, false},
+		{"INTEGER", 32, false},
+		{"BIGINT(20) UNSIGNED", 64, true},
+		{"UInt32", 32, true},
+		{"Nullable(Int64)", 64, false},
+		{"VARCHAR(64)", 0, false},
+		{"DOUBLE", 0, false},
+	}
+	for _, c := range cases {
+		bits, unsigned := integerTypeBits(c.colType)
+		if bits != c.bits || unsigned != c.unsigned {
+			t.Errorf("%s: want %d %v got %d %v", c.colType, c.bits, c.unsigned, bits, unsigned)
+		}
+	}
+}
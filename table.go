@@ -72,6 +72,12 @@ type ITableSpec interface {
 	// AddIndex adds index to table
 	AddIndex(unique bool, cols ...string) bool
 
+	// ForeignKeys returns the foreign key constraints declared of the table
+	ForeignKeys() []STableConstraint
+
+	// AddForeignKey declares a foreign key constraint of the table
+	AddForeignKey(cols []string, refTable string, refCols []string, onDelete, onUpdate string) bool
+
 	// SyncSQL returns SQL strings to synchronize the data and model definition of the table
 	SyncSQL() []string
 
@@ -265,11 +271,10 @@ func (ts *STableSpec) CloneWithSyncColumnOrder(name string, autoIncOffset int64,
 		}
 	}
 	nts := &STableSpec{
-		structType:  ts.structType,
-		name:        name,
-		_columns:    newCols,
-		_contraints: ts._contraints,
-		sDBReferer:  ts.sDBReferer,
+		structType: ts.structType,
+		name:       name,
+		_columns:   newCols,
+		sDBReferer: ts.sDBReferer,
 
 		syncedIndex:   false,
 		syncIndexLock: &sync.Mutex{},
@@ -279,6 +284,11 @@ func (ts *STableSpec) CloneWithSyncColumnOrder(name string, autoIncOffset int64,
 		newIndexes[i] = ts._indexes[i].clone(nts)
 	}
 	nts._indexes = newIndexes
+	newConstraints := make([]STableConstraint, len(ts._contraints))
+	for i := range ts._contraints {
+		newConstraints[i] = ts._contraints[i].clone(nts)
+	}
+	nts._contraints = newConstraints
 	return nts, nil
 }
 
@@ -308,7 +318,7 @@ func (ts *STableSpec) PrimaryColumns() []IColumnSpec {
 
 // Indexes implementation of STableSpec for ITableSpec
 func (ts *STableSpec) Indexes() []STableIndex {
-	return ts._indexes
+	return ts.backendIndexes()
 }
 
 // DataType implementation of STableSpec for ITableSpec
@@ -0,0 +1,174 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+// sTxState is the transaction state shared by a transaction and its nested savepoints
+type sTxState struct {
+	tx *sql.Tx
+
+	// sequence number to generate unique savepoint names
+	savepointSeq int
+}
+
+// STx is a transactional handle of a database returned by SDatabase.Begin
+// table specs and queries bound to the handle by InTx run inside the transaction
+type STx struct {
+	db *SDatabase
+
+	// savepoint is the name of the savepoint of a nested transaction, empty for the outmost transaction
+	savepoint string
+
+	done bool
+}
+
+// Begin starts a transaction on the database and returns a transactional handle,
+// if the database is already a transactional handle, a savepoint is created as a nested transaction
+func (db *SDatabase) Begin() (*STx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+// BeginTx is the context-aware variant of Begin, opts is ignored for nested transactions
+func (db *SDatabase) BeginTx(ctx context.Context, opts *sql.TxOptions) (*STx, error) {
+	if db.tx != nil {
+		return db.beginSavepoint(ctx)
+	}
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, errors.Wrap(db.wrapError(ctx, err), "BeginTx")
+	}
+	txdb := *db
+	txdb.tx = &sTxState{tx: tx}
+	return &STx{db: &txdb}, nil
+}
+
+func (db *SDatabase) beginSavepoint(ctx context.Context) (*STx, error) {
+	if !db.backend.CanSupportSavepoint() {
+		return nil, errors.Wrapf(ErrNotSupported, "savepoint of %s", db.backend.Name())
+	}
+	db.tx.savepointSeq++
+	name := fmt.Sprintf("sp_%d", db.tx.savepointSeq)
+	_, err := db.tx.tx.ExecContext(ctx, "SAVEPOINT "+name)
+	if err != nil {
+		return nil, errors.Wrapf(db.wrapError(ctx, err), "SAVEPOINT %s", name)
+	}
+	return &STx{db: db, savepoint: name}, nil
+}
+
+// RunInTx runs fn inside a transaction, the transaction is committed if fn returns nil, otherwise rollbacked.
+// If a retry policy is set, the whole transaction is retried on deadlocks, so fn should be free of
+// side effects other than the statements of the transaction
+func (db *SDatabase) RunInTx(ctx context.Context, fn func(tx *STx) error) error {
+	if db.tx != nil || db.retryPolicy == nil {
+		return db.runInTx(ctx, fn)
+	}
+	return db.withRetry(ctx, "RunInTx", func() error {
+		return db.runInTx(ctx, fn)
+	})
+}
+
+func (db *SDatabase) runInTx(ctx context.Context, fn func(tx *STx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "BeginTx")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	err = fn(tx)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Errorf("rollback transaction fail %s", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsInTx returns whether the database is a transactional handle
+func (db *SDatabase) IsInTx() bool {
+	return db.tx != nil
+}
+
+// Database returns the transactional database handle
+func (tx *STx) Database() *SDatabase {
+	return tx.db
+}
+
+// Begin starts a nested transaction with a savepoint
+func (tx *STx) Begin() (*STx, error) {
+	return tx.db.Begin()
+}
+
+// Commit commits the transaction, or releases the savepoint of a nested transaction
+func (tx *STx) Commit() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	if len(tx.savepoint) > 0 {
+		_, err := tx.db.tx.tx.Exec("RELEASE SAVEPOINT " + tx.savepoint)
+		if err != nil {
+			return errors.Wrapf(err, "RELEASE SAVEPOINT %s", tx.savepoint)
+		}
+		return nil
+	}
+	err := tx.db.tx.tx.Commit()
+	tx.db.markWrite()
+	return tx.db.wrapError(context.Background(), err)
+}
+
+// Rollback aborts the transaction, or rollbacks to the savepoint of a nested transaction
+func (tx *STx) Rollback() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	if len(tx.savepoint) > 0 {
+		_, err := tx.db.tx.tx.Exec("ROLLBACK TO SAVEPOINT " + tx.savepoint)
+		if err != nil {
+			return errors.Wrapf(err, "ROLLBACK TO SAVEPOINT %s", tx.savepoint)
+		}
+		return nil
+	}
+	return tx.db.tx.tx.Rollback()
+}
+
+// InTx returns a copy of the table spec whose operations run inside the transaction
+func (ts *STableSpec) InTx(tx *STx) *STableSpec {
+	nts := *ts
+	nts.sDBReferer = sDBReferer{
+		dbName:    ts.dbName,
+		_db_cache: tx.db,
+	}
+	return &nts
+}
+
+// InTx makes the query run inside the transaction
+func (tq *SQuery) InTx(tx *STx) *SQuery {
+	tq.db = tx.db
+	return tq
+}
@@ -15,6 +15,7 @@
 package sqlchemy
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -113,12 +114,14 @@ type SUpdateSQLResult struct {
 	Vars      []interface{}
 	setters   []SUpdateDiff
 	primaries []sPrimaryKeyValue
+	// versions are the optimistic lock columns and the versions loaded
+	versions []sPrimaryKeyValue
 }
 
 func (us *SUpdateSession) SaveUpdateSql(dt interface{}) (*SUpdateSQLResult, error) {
-	beforeUpdateFunc := reflect.ValueOf(dt).MethodByName("BeforeUpdate")
-	if beforeUpdateFunc.IsValid() && !beforeUpdateFunc.IsNil() {
-		beforeUpdateFunc.Call([]reflect.Value{})
+	err := callHook(reflect.ValueOf(dt), hookBeforeUpdate)
+	if err != nil {
+		return nil, err
 	}
 
 	// dataType := reflect.TypeOf(dt).Elem()
@@ -127,6 +130,7 @@ func (us *SUpdateSession) SaveUpdateSql(dt interface{}) (*SUpdateSQLResult, erro
 	fields := reflectutils.FetchStructFieldValueSet(dataValue)
 
 	versionFields := make([]string, 0)
+	versions := make([]sPrimaryKeyValue, 0)
 	updatedFields := make([]string, 0)
 	primaries := make([]sPrimaryKeyValue, 0)
 	setters := make([]SUpdateDiff, 0)
@@ -159,6 +163,12 @@ func (us *SUpdateSession) SaveUpdateSql(dt interface{}) (*SUpdateSQLResult, erro
 		}
 		if c.IsAutoVersion() {
 			versionFields = append(versionFields, k)
+			if c.IsOptimisticLock() {
+				versions = append(versions, sPrimaryKeyValue{
+					key:   k,
+					value: c.ConvertFromValue(of),
+				})
+			}
 			continue
 		}
 		if c.IsUpdatedAt() {
@@ -222,6 +232,10 @@ func (us *SUpdateSession) SaveUpdateSql(dt interface{}) (*SUpdateSQLResult, erro
 		conditions = append(conditions, fmt.Sprintf("%s%s%s = ?", qChar, pkv.key, qChar))
 		vars = append(vars, pkv.value)
 	}
+	for _, ver := range versions {
+		conditions = append(conditions, fmt.Sprintf("%s%s%s = ?", qChar, ver.key, qChar))
+		vars = append(vars, ver.value)
+	}
 
 	updateSql := TemplateEval(us.tableSpec.Database().backend.UpdateSQLTemplate(), struct {
 		Table      string
@@ -234,7 +248,7 @@ func (us *SUpdateSession) SaveUpdateSql(dt interface{}) (*SUpdateSQLResult, erro
 	})
 
 	if DEBUG_SQLCHEMY {
-		log.Infof("Update: %s", _sqlDebug(updateSql, vars))
+		log.Infof("Update: %s", _sqlDebug(us.tableSpec.Database(), updateSql, vars))
 	}
 
 	return &SUpdateSQLResult{
@@ -242,25 +256,31 @@ func (us *SUpdateSession) SaveUpdateSql(dt interface{}) (*SUpdateSQLResult, erro
 		Vars:      vars,
 		setters:   setters,
 		primaries: primaries,
+		versions:  versions,
 	}, nil
 }
 
-func (us *SUpdateSession) saveUpdate(dt interface{}) (UpdateDiffs, error) {
+func (us *SUpdateSession) saveUpdate(ctx context.Context, dt interface{}) (UpdateDiffs, error) {
 	sqlResult, err := us.SaveUpdateSql(dt)
 	if err != nil {
 		return nil, errors.Wrap(err, "saveUpateSql")
 	}
 
-	err = us.tableSpec.execUpdateSql(dt, sqlResult)
+	err = us.tableSpec.execUpdateSql(ctx, dt, sqlResult)
 	if err != nil {
 		return nil, errors.Wrap(err, "execUpdateSql")
 	}
 
-	return updateDiffList2Map(sqlResult.setters), nil
+	diffs := updateDiffList2Map(sqlResult.setters)
+	err = callHook(reflect.ValueOf(dt), hookAfterUpdate, diffs)
+	if err != nil {
+		return nil, err
+	}
+	return diffs, nil
 }
 
-func (ts *STableSpec) execUpdateSql(dt interface{}, result *SUpdateSQLResult) error {
-	results, err := ts.Database().TxExec(result.Sql, result.Vars...)
+func (ts *STableSpec) execUpdateSql(ctx context.Context, dt interface{}, result *SUpdateSQLResult) error {
+	results, err := ts.Database().TxExecContext(ctx, result.Sql, result.Vars...)
 	if err != nil {
 		return errors.Wrap(err, "TxExec")
 	}
@@ -273,12 +293,15 @@ func (ts *STableSpec) execUpdateSql(dt interface{}, result *SUpdateSQLResult) er
 		if aCnt > 1 {
 			return errors.Wrapf(ErrUnexpectRowCount, "affected rows %d != 1", aCnt)
 		}
+		if aCnt == 0 && len(result.versions) > 0 {
+			return errors.Wrapf(ErrConcurrentModification, "%s version %v", ts.name, result.versions[0].value)
+		}
 	}
-	q := ts.Query()
+	q := ts.Query().Unscoped().UsePrimary()
 	for _, pkv := range result.primaries {
 		q = q.Equals(pkv.key, pkv.value)
 	}
-	err = q.First(dt)
+	err = q.FirstContext(ctx, dt)
 	if err != nil {
 		return errors.Wrapf(err, "query after update failed %s", q.DebugString())
 	}
@@ -289,6 +312,11 @@ func (ts *STableSpec) execUpdateSql(dt interface{}, result *SUpdateSQLResult) er
 // dt is the point to the struct storing the record
 // doUpdate provides method to update the field of the record
 func (ts *STableSpec) Update(dt interface{}, doUpdate func() error) (UpdateDiffs, error) {
+	return ts.UpdateContext(context.Background(), dt, doUpdate)
+}
+
+// UpdateContext is the context-aware variant of Update
+func (ts *STableSpec) UpdateContext(ctx context.Context, dt interface{}, doUpdate func() error) (UpdateDiffs, error) {
 	if !ts.Database().backend.CanUpdate() {
 		return nil, errors.ErrNotSupported
 	}
@@ -300,7 +328,7 @@ func (ts *STableSpec) Update(dt interface{}, doUpdate func() error) (UpdateDiffs
 	if err != nil {
 		return nil, errors.Wrap(err, "")
 	}
-	uds, err := session.saveUpdate(dt)
+	uds, err := session.saveUpdate(ctx, dt)
 	if err != nil && errors.Cause(err) == ErrNoDataToUpdate {
 		return nil, nil
 	} else if err == nil {
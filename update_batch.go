@@ -15,51 +15,155 @@
 package sqlchemy
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
 )
 
-func (ts *STableSpec) UpdateBatch(data map[string]interface{}, filter map[string]interface{}) error {
-	if len(data) <= 0 {
-		return nil
+type sUpdateSetter struct {
+	col   IColumnSpec
+	value interface{}
+	field IQueryField
+}
+
+// SUpdateQuery is a builder of UPDATE statement whose filters are arbitrary ICondition
+// and whose SET values can be expressions, e.g. col = col + 1
+type SUpdateQuery struct {
+	table   *STable
+	setters []sUpdateSetter
+	where   ICondition
+	err     error
+}
+
+// UpdateQuery returns an UPDATE statement builder of the table, the conditions
+// and the expressions are built with the fields returned by Field of the builder,
+// soft-delete scope is not applied
+func (ts *STableSpec) UpdateQuery() *SUpdateQuery {
+	return &SUpdateQuery{table: ts.mutationInstance()}
+}
+
+// Field returns the field of the target table with the given name
+func (uq *SUpdateQuery) Field(name string) IQueryField {
+	return uq.table.Field(name)
+}
+
+func (uq *SUpdateQuery) column(name string) IColumnSpec {
+	col := uq.table.spec.ColumnSpec(name)
+	if col == nil && uq.err == nil {
+		uq.err = errors.Wrapf(ErrUnknownColumn, "column %s of table %s", name, uq.table.spec.Name())
 	}
+	return col
+}
 
-	qChar := ts.Database().backend.QuoteChar()
+// Set sets the column to a value
+func (uq *SUpdateQuery) Set(name string, value interface{}) *SUpdateQuery {
+	if col := uq.column(name); col != nil {
+		uq.setters = append(uq.setters, sUpdateSetter{col: col, value: value})
+	}
+	return uq
+}
 
-	params := make([]interface{}, 0, len(data))
-	setter := make([]string, 0, len(data))
-	for k, v := range data {
-		col := ts.ColumnSpec(k)
-		if col == nil {
-			log.Warningf("UpdateBatch: column %s not found", k)
-			continue
+// SetField sets the column to an expression, e.g. ADD("", uq.Field("cnt"), NewConstField(1))
+func (uq *SUpdateQuery) SetField(name string, field IQueryField) *SUpdateQuery {
+	if col := uq.column(name); col != nil {
+		uq.setters = append(uq.setters, sUpdateSetter{col: col, field: field})
+	}
+	return uq
+}
+
+// Filter adds a condition to the WHERE clause of the UPDATE statement
+func (uq *SUpdateQuery) Filter(cond ICondition) *SUpdateQuery {
+	if uq.where == nil {
+		uq.where = cond
+	} else {
+		uq.where = AND(uq.where, cond)
+	}
+	return uq
+}
+
+// String returns the SQL of the UPDATE statement
+func (uq *SUpdateQuery) String() string {
+	qChar := uq.table.spec.Database().backend.QuoteChar()
+	colsets := make([]string, 0, len(uq.setters))
+	for _, setter := range uq.setters {
+		if setter.field != nil {
+			colsets = append(colsets, fmt.Sprintf("%s%s%s = %s", qChar, setter.col.Name(), qChar, setter.field.Expression()))
+		} else {
+			colsets = append(colsets, fmt.Sprintf("%s%s%s = ?", qChar, setter.col.Name(), qChar))
 		}
-		setter = append(setter, fmt.Sprintf("%s%s%s = ?", qChar, k, qChar))
-		params = append(params, col.ConvertFromValue(v))
 	}
-	conds, condparams := ts.getSQLFilters(filter, qChar)
-	params = append(params, condparams...)
+	return TemplateEval(uq.table.spec.Database().backend.UpdateSQLTemplate(), struct {
+		Table      string
+		Columns    string
+		Conditions string
+	}{
+		Table:      uq.table.spec.Name(),
+		Columns:    strings.Join(colsets, ", "),
+		Conditions: mutationWhereClause(uq.where),
+	})
+}
 
-	buf := strings.Builder{}
+// Variables returns the variables of the UPDATE statement
+func (uq *SUpdateQuery) Variables() []interface{} {
+	vars := make([]interface{}, 0)
+	for _, setter := range uq.setters {
+		if setter.field != nil {
+			vars = append(vars, setter.field.Variables()...)
+		} else {
+			vars = append(vars, setter.col.ConvertFromValue(setter.value))
+		}
+	}
+	if uq.where != nil {
+		vars = append(vars, uq.where.Variables()...)
+	}
+	return vars
+}
 
-	buf.WriteString("UPDATE ")
-	buf.WriteString(qChar)
-	buf.WriteString(ts.Name())
-	buf.WriteString(qChar)
-	buf.WriteString(" SET ")
-	buf.WriteString(strings.Join(setter, ", "))
+// Exec executes the UPDATE statement and returns the number of affected rows
+func (uq *SUpdateQuery) Exec() (int64, error) {
+	return uq.ExecContext(context.Background())
+}
 
-	if len(conds) > 0 {
-		buf.WriteString(" WHERE ")
-		buf.WriteString(strings.Join(conds, " AND "))
+// ExecContext is the context-aware variant of Exec
+func (uq *SUpdateQuery) ExecContext(ctx context.Context) (int64, error) {
+	if uq.err != nil {
+		return 0, uq.err
+	}
+	if len(uq.setters) == 0 {
+		return 0, ErrNoDataToUpdate
 	}
+	return execMutation(ctx, uq.table.spec.Database(), uq.String(), uq.Variables())
+}
 
-	if DEBUG_SQLCHEMY {
-		log.Infof("UpdateBATCH: %s %s", buf.String(), params)
+func (ts *STableSpec) UpdateBatch(data map[string]interface{}, filter map[string]interface{}) error {
+	if len(data) <= 0 {
+		return nil
+	}
+
+	uq := ts.UpdateQuery()
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if ts.ColumnSpec(k) == nil {
+			log.Warningf("UpdateBatch: column %s not found", k)
+			continue
+		}
+		uq.Set(k, data[k])
+	}
+	if len(uq.setters) == 0 {
+		return nil
+	}
+	if cond := filterConditions(uq.table, filter); cond != nil {
+		uq.Filter(cond)
 	}
 
-	_, err := ts.Database().Exec(buf.String(), params...)
+	_, err := uq.Exec()
 	return err
 }
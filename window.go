@@ -0,0 +1,244 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlchemy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WindowFrameBound is the bound of a window frame, e.g. UNBOUNDED PRECEDING, CURRENT ROW
+type WindowFrameBound string
+
+const (
+	// WINDOW_UNBOUNDED_PRECEDING represents the first row of the partition
+	WINDOW_UNBOUNDED_PRECEDING WindowFrameBound = "UNBOUNDED PRECEDING"
+
+	// WINDOW_CURRENT_ROW represents the current row
+	WINDOW_CURRENT_ROW WindowFrameBound = "CURRENT ROW"
+
+	// WINDOW_UNBOUNDED_FOLLOWING represents the last row of the partition
+	WINDOW_UNBOUNDED_FOLLOWING WindowFrameBound = "UNBOUNDED FOLLOWING"
+)
+
+// WindowPreceding returns the frame bound of n rows (or values) before the current row
+func WindowPreceding(n int) WindowFrameBound {
+	return WindowFrameBound(fmt.Sprintf("%d PRECEDING", n))
+}
+
+// WindowFollowing returns the frame bound of n rows (or values) after the current row
+func WindowFollowing(n int) WindowFrameBound {
+	return WindowFrameBound(fmt.Sprintf("%d FOLLOWING", n))
+}
+
+// SWindow is the definition of a window in OVER clause, e.g.
+//
+//	OVER (PARTITION BY ... ORDER BY ... ROWS BETWEEN ... AND ...)
+type SWindow struct {
+	partitionBy []IQueryField
+	orderBy     []sQueryOrder
+
+	frameUnit  string
+	frameStart WindowFrameBound
+	frameEnd   WindowFrameBound
+}
+
+// NewWindow returns an empty window, namely OVER ()
+func NewWindow() *SWindow {
+	return &SWindow{}
+}
+
+// Copy returns a copy of the window
+func (w *SWindow) Copy() *SWindow {
+	nw := *w
+	nw.partitionBy = append([]IQueryField{}, w.partitionBy...)
+	nw.orderBy = append([]sQueryOrder{}, w.orderBy...)
+	return &nw
+}
+
+// PartitionBy of SWindow adds PARTITION BY fields to the window
+func (w *SWindow) PartitionBy(fields ...IQueryField) *SWindow {
+	w.partitionBy = append(w.partitionBy, fields...)
+	return w
+}
+
+// Asc of SWindow orders the rows in the window in ascending order of specified fields
+func (w *SWindow) Asc(fields ...IQueryField) *SWindow {
+	for i := range fields {
+		w.orderBy = append(w.orderBy, sQueryOrder{field: fields[i], order: SQL_ORDER_ASC})
+	}
+	return w
+}
+
+// Desc of SWindow orders the rows in the window in descending order of specified fields
+func (w *SWindow) Desc(fields ...IQueryField) *SWindow {
+	for i := range fields {
+		w.orderBy = append(w.orderBy, sQueryOrder{field: fields[i], order: SQL_ORDER_DESC})
+	}
+	return w
+}
+
+// Rows of SWindow sets a ROWS frame, e.g. ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+func (w *SWindow) Rows(start, end WindowFrameBound) *SWindow {
+	w.frameUnit = "ROWS"
+	w.frameStart = start
+	w.frameEnd = end
+	return w
+}
+
+// Range of SWindow sets a RANGE frame, e.g. RANGE BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+func (w *SWindow) Range(start, end WindowFrameBound) *SWindow {
+	w.frameUnit = "RANGE"
+	w.frameStart = start
+	w.frameEnd = end
+	return w
+}
+
+// HasFrame returns whether the frame of the window is specified
+func (w *SWindow) HasFrame() bool {
+	return len(w.frameUnit) > 0
+}
+
+// String returns the window specification within OVER ( ... )
+func (w *SWindow) String() string {
+	parts := make([]string, 0, 3)
+	if len(w.partitionBy) > 0 {
+		exprs := make([]string, len(w.partitionBy))
+		for i := range w.partitionBy {
+			exprs[i] = w.partitionBy[i].Expression()
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(exprs, ", "))
+	}
+	if len(w.orderBy) > 0 {
+		exprs := make([]string, len(w.orderBy))
+		for i := range w.orderBy {
+			exprs[i] = fmt.Sprintf("%s %s", w.orderBy[i].field.Expression(), w.orderBy[i].order)
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(exprs, ", "))
+	}
+	if w.HasFrame() {
+		parts = append(parts, fmt.Sprintf("%s BETWEEN %s AND %s", w.frameUnit, w.frameStart, w.frameEnd))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (w *SWindow) fields() []IQueryField {
+	fields := make([]IQueryField, 0, len(w.partitionBy)+len(w.orderBy))
+	fields = append(fields, w.partitionBy...)
+	for i := range w.orderBy {
+		fields = append(fields, w.orderBy[i].field)
+	}
+	return fields
+}
+
+func (w *SWindow) variables() []interface{} {
+	vars := make([]interface{}, 0)
+	for _, f := range w.fields() {
+		vars = append(vars, f.Variables()...)
+	}
+	return vars
+}
+
+type sWindowFunction struct {
+	sExprFunction
+	window *SWindow
+}
+
+func (ff *sWindowFunction) expression() string {
+	return fmt.Sprintf("%s OVER (%s)", ff.sExprFunction.expression(), ff.window.String())
+}
+
+func (ff *sWindowFunction) variables() []interface{} {
+	vars := ff.sExprFunction.variables()
+	return append(vars, ff.window.variables()...)
+}
+
+func (ff *sWindowFunction) database() *SDatabase {
+	db := ff.sExprFunction.database()
+	if db != nil {
+		return db
+	}
+	for _, f := range ff.window.fields() {
+		db := f.database()
+		if db != nil {
+			return db
+		}
+	}
+	return nil
+}
+
+// NewWindowFunctionField returns an instance of query field by calling a SQL window function
+// over the window, e.g. ROW_NUMBER() OVER (PARTITION BY ... ORDER BY ...)
+func NewWindowFunctionField(name string, funcexp string, window *SWindow, fields ...IQueryField) IQueryField {
+	if window == nil {
+		window = NewWindow()
+	}
+	funcBase := &sWindowFunction{
+		sExprFunction: sExprFunction{
+			fields:   fields,
+			function: funcexp,
+		},
+		window: window,
+	}
+	// a window function is computed after GROUP BY, so it must not be wrapped by an aggregate function
+	return NewFunction(funcBase, name, true)
+}
+
+func getWindowBackend(window *SWindow, fields ...IQueryField) IBackend {
+	if window != nil {
+		fields = append(fields, window.fields()...)
+	}
+	return getFieldBackend(fields...)
+}
+
+// ROW_NUMBER represents the SQL window function ROW_NUMBER
+func ROW_NUMBER(name string, window *SWindow) IQueryField {
+	return getWindowBackend(window).ROW_NUMBER(name, window)
+}
+
+// RANK represents the SQL window function RANK
+func RANK(name string, window *SWindow) IQueryField {
+	return getWindowBackend(window).RANK(name, window)
+}
+
+// DENSE_RANK represents the SQL window function DENSE_RANK
+func DENSE_RANK(name string, window *SWindow) IQueryField {
+	return getWindowBackend(window).DENSE_RANK(name, window)
+}
+
+// LAG represents the SQL window function LAG
+func LAG(name string, field IQueryField, offset int, window *SWindow) IQueryField {
+	return getWindowBackend(window, field).LAG(name, field, offset, window)
+}
+
+// LEAD represents the SQL window function LEAD
+func LEAD(name string, field IQueryField, offset int, window *SWindow) IQueryField {
+	return getWindowBackend(window, field).LEAD(name, field, offset, window)
+}
+
+// FIRST_VALUE represents the SQL window function FIRST_VALUE
+func FIRST_VALUE(name string, field IQueryField, window *SWindow) IQueryField {
+	return getWindowBackend(window, field).FIRST_VALUE(name, field, window)
+}
+
+// SUM_OVER represents the SQL function SUM over a window, e.g. running sum
+func SUM_OVER(name string, field IQueryField, window *SWindow) IQueryField {
+	return getWindowBackend(window, field).SUM_OVER(name, field, window)
+}
+
+// AVG_OVER represents the SQL function AVG over a window, e.g. moving average
+func AVG_OVER(name string, field IQueryField, window *SWindow) IQueryField {
+	return getWindowBackend(window, field).AVG_OVER(name, field, window)
+}